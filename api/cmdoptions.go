@@ -19,6 +19,7 @@ var Options struct {
 	Cleared    bool
 	Uncleared  bool
 	Pending    bool
+	Real       bool
 	Dcformat   bool
 	Strict     bool
 	Pedantic   bool
@@ -34,11 +35,53 @@ var Options struct {
 	Quarterly  bool
 	Yearly     bool
 	Dow        bool
+	Market     bool
+	Exchange   string
+	Lotpolicy  string
+	Forecast   *time.Time
+	Stmtbal    string
+	Stmtdate   *time.Time
+	Importacc  string
+	Rules      string
 	Verbose    bool
+	Outformat  string
+	Collapse   bool
+	Addr       string
+	Watch      bool
+	Firstuse   bool
+	Bytag      string
 	Outfd      *os.File
 	Loglevel   string
 }
 
+// FilterState return true if posting's state, cleared, pending or
+// uncleared, is selected by -cleared, -pending and -uncleared options.
+// If none of them are supplied, postings in all states are selected.
+func FilterState(state string) bool {
+	if !(Options.Cleared || Options.Pending || Options.Uncleared) {
+		return true
+	}
+	switch state {
+	case "cleared":
+		return Options.Cleared
+	case "pending":
+		return Options.Pending
+	}
+	return Options.Uncleared
+}
+
+// FilterReal return false for virtual postings with -real option.
+func FilterReal(virtual bool) bool {
+	return Options.Real == false || virtual == false
+}
+
+// FilterPostings return true if postings are filtered by their state or
+// by -real option, reporters shall see only the filtered postings.
+func FilterPostings() bool {
+	return Options.Cleared || Options.Pending || Options.Uncleared ||
+		Options.Real
+}
+
 func FilterPeriod(date time.Time, nobegin bool) bool {
 	begin, end := Options.Begindt, Options.Enddt
 	if nobegin == false && begin != nil && date.Before(*begin) {
@@ -0,0 +1,153 @@
+package api
+
+import "fmt"
+import "strings"
+import "math/big"
+
+// maxprecision for decimals that don't terminate, like 1/3.
+const maxprecision = 16
+
+// Decimal is an exact decimal number, used for commodity amounts. Decimal
+// values are immutable and zero value is 0.
+type Decimal struct {
+	rat *big.Rat
+}
+
+// NewDecimal return decimal for integer x.
+func NewDecimal(x int64) Decimal {
+	return Decimal{rat: new(big.Rat).SetInt64(x)}
+}
+
+// ParseDecimal parse decimal number, like `-1234.5678`.
+func ParseDecimal(s string) (Decimal, error) {
+	rat, ok := new(big.Rat).SetString(s)
+	if ok == false {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Decimal{rat: rat}, nil
+}
+
+func (d Decimal) r() *big.Rat {
+	if d.rat == nil {
+		return new(big.Rat)
+	}
+	return d.rat
+}
+
+// Add return d + y.
+func (d Decimal) Add(y Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Add(d.r(), y.r())}
+}
+
+// Sub return d - y.
+func (d Decimal) Sub(y Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Sub(d.r(), y.r())}
+}
+
+// Mul return d * y.
+func (d Decimal) Mul(y Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Mul(d.r(), y.r())}
+}
+
+// Quo return d / y, y shall not be zero.
+func (d Decimal) Quo(y Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Quo(d.r(), y.r())}
+}
+
+// Neg return -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{rat: new(big.Rat).Neg(d.r())}
+}
+
+// Abs return |d|.
+func (d Decimal) Abs() Decimal {
+	return Decimal{rat: new(big.Rat).Abs(d.r())}
+}
+
+// Cmp return -1, 0, +1 if d is less than, equal to, greater than y.
+func (d Decimal) Cmp(y Decimal) int {
+	return d.r().Cmp(y.r())
+}
+
+// Sign return -1, 0, +1 if d is negative, zero, positive.
+func (d Decimal) Sign() int {
+	return d.r().Sign()
+}
+
+// IsZero return true if d is 0.
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Precision return the number of digits after decimal point needed to
+// represent d exactly, maxprecision if d does not terminate.
+func (d Decimal) Precision() int {
+	denom := new(big.Int).Set(d.r().Denom())
+	two, five := big.NewInt(2), big.NewInt(5)
+	m, rem := new(big.Int), new(big.Int)
+	twos, fives := 0, 0
+	for ; ; twos++ {
+		if m.QuoRem(denom, two, rem); rem.Sign() != 0 {
+			break
+		}
+		denom.Set(m)
+	}
+	for ; ; fives++ {
+		if m.QuoRem(denom, five, rem); rem.Sign() != 0 {
+			break
+		}
+		denom.Set(m)
+	}
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return maxprecision
+	}
+	return Maxints(twos, fives)
+}
+
+// Round d to precision digits after decimal point, halves are rounded
+// away from zero.
+func (d Decimal) Round(precision int) Decimal {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)
+	num := new(big.Int).Abs(d.r().Num())
+	num.Mul(num, scale)
+	q, rem := new(big.Int).QuoRem(num, d.r().Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(d.r().Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if d.Sign() < 0 {
+		q.Neg(q)
+	}
+	return Decimal{rat: new(big.Rat).SetFrac(q, scale)}
+}
+
+// Scaled return d, rounded to precision digits after decimal point, in
+// units of 10^-precision. Return false if it does not fit in int64.
+func (d Decimal) Scaled(precision int) (int64, bool) {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)
+	r := d.Round(precision).r()
+	num := new(big.Int).Mul(r.Num(), scale)
+	num.Quo(num, r.Denom())
+	return num.Int64(), num.IsInt64()
+}
+
+// Format d with precision digits after decimal point, halves are rounded
+// away from zero.
+func (d Decimal) Format(precision int) string {
+	return d.r().FloatString(precision)
+}
+
+// String return d with as many digits after decimal point as needed, upto
+// maxprecision.
+func (d Decimal) String() string {
+	precision := d.Precision()
+	s := d.Format(precision)
+	if precision == maxprecision {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// MarshalJSON encode d as JSON number.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
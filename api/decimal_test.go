@@ -0,0 +1,70 @@
+package api
+
+import "testing"
+
+func TestDecimal(t *testing.T) {
+	parse := func(s string) Decimal {
+		d, err := ParseDecimal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	// 0.1 + 0.2 is exactly 0.3
+	if x := parse("0.1").Add(parse("0.2")); x.Cmp(parse("0.3")) != 0 {
+		t.Errorf("expected %v, got %v", "0.3", x)
+	}
+	if x := parse("90071992547409.93").Add(parse("0.01")); x.String() !=
+		"90071992547409.94" {
+		t.Errorf("expected %v, got %v", "90071992547409.94", x)
+	}
+	third := NewDecimal(100).Quo(NewDecimal(3))
+	if x := third.Mul(NewDecimal(3)); x.Cmp(NewDecimal(100)) != 0 {
+		t.Errorf("expected %v, got %v", 100, x)
+	}
+	var zero Decimal
+	if zero.IsZero() == false || zero.String() != "0" {
+		t.Errorf("unexpected zero value %v", zero)
+	}
+	if _, err := ParseDecimal("1.2.3"); err == nil {
+		t.Errorf("expected error")
+	}
+
+	testcases := [][]interface{}{
+		// decimal, precision, Precision(), Round(), Format(), String()
+		{"1.005", 2, 3, "1.01", "1.01", "1.005"},
+		{"-1.005", 2, 3, "-1.01", "-1.01", "-1.005"},
+		{"-0.004", 2, 3, "0", "-0.00", "-0.004"},
+		{"12.50", 0, 1, "13", "13", "12.5"},
+		{"100", 2, 0, "100", "100.00", "100"},
+	}
+	for _, tcase := range testcases {
+		d := parse(tcase[0].(string))
+		precision := tcase[1].(int)
+		if x := d.Precision(); x != tcase[2].(int) {
+			t.Errorf("%v expected %v, got %v", d, tcase[2], x)
+		}
+		if x := d.Round(precision).String(); x != tcase[3].(string) {
+			t.Errorf("%v expected %v, got %v", d, tcase[3], x)
+		}
+		if x := d.Format(precision); x != tcase[4].(string) {
+			t.Errorf("%v expected %v, got %v", d, tcase[4], x)
+		}
+		if x := d.String(); x != tcase[5].(string) {
+			t.Errorf("%v expected %v, got %v", d, tcase[5], x)
+		}
+	}
+	if x, ok := parse("-1.005").Scaled(2); ok == false || x != -101 {
+		t.Errorf("expected %v, got %v", -101, x)
+	} else if x, ok := parse("12.5").Scaled(3); ok == false || x != 12500 {
+		t.Errorf("expected %v, got %v", 12500, x)
+	} else if _, ok := parse("1e17").Scaled(2); ok {
+		t.Errorf("expected overflow")
+	}
+	if x := third.Precision(); x != maxprecision {
+		t.Errorf("expected %v, got %v", maxprecision, x)
+	} else if x := third.String(); x != "33.3333333333333333" {
+		t.Errorf("expected %v, got %v", "33.3333333333333333", x)
+	}
+}
@@ -3,6 +3,7 @@ package api
 import "regexp"
 import "strings"
 import "fmt"
+import "time"
 
 import "github.com/prataprc/goparsec"
 
@@ -44,6 +45,7 @@ func MakeFilterexpr(args []string) string {
 
 // Grammar
 //
+// yterm        -> PREDICATE:VALUE | %KEY[=REGEX] | REGEX | STRING
 // yregex       -> yterm+
 // yparanexpr   -> "(" YFilterExpr ")"
 // yfvalue      -> ynot | yparanexpr | yregex
@@ -204,18 +206,31 @@ func init() {
 type Filterexpr struct {
 	op       string
 	operands []*Filterexpr
-	// for match op
+	// for match op and predicates
 	pattern string
 	regc    *regexp.Regexp
+	key     string // metadata key for `%`, posting state for `status:`
+	// for `amt:` predicate
+	cmp    string
+	amount Decimal
+	signed bool
+	// for `date:` predicate, zero if open.
+	begin time.Time
+	end   time.Time
 }
 
 func newFilterexpr(op string, operands []*Filterexpr) *Filterexpr {
 	return &Filterexpr{op: op, operands: operands}
 }
 
+// newMatchexpr for pattern, refer newPredicate for predicate terms,
+// everything else match account name.
 func newMatchexpr(pattern string) (*Filterexpr, error) {
 	var err error
 
+	if fe, err := newPredicate(pattern); err != nil || fe != nil {
+		return fe, err
+	}
 	fe := &Filterexpr{op: "match", pattern: pattern}
 	if pattern != "" {
 		if fe.regc, err = regexp.Compile(pattern); err != nil {
@@ -225,26 +240,61 @@ func newMatchexpr(pattern string) (*Filterexpr, error) {
 	return fe, nil
 }
 
+// Match account name with expression, predicate terms are treated as
+// matching.
 func (fe *Filterexpr) Match(name string) bool {
+	return fe.match(name, nil, nil)
+}
+
+// MatchPosting match posting, and its transaction, with expression.
+func (fe *Filterexpr) MatchPosting(trans Transactor, p Poster) bool {
+	return fe.match(p.Account().Name(), trans, p)
+}
+
+// Predicates return true if expression has predicate terms, that can
+// only be matched with postings.
+func (fe *Filterexpr) Predicates() bool {
+	switch fe.op {
+	case "match", "and", "or", "not":
+	default:
+		return true
+	}
+	for _, operand := range fe.operands {
+		if operand.Predicates() {
+			return true
+		}
+	}
+	return false
+}
+
+func (fe *Filterexpr) match(name string, trans Transactor, p Poster) bool {
 	switch fe.op {
 	case "match":
 		return fe.regc.MatchString(name)
 	case "and":
 		op1, op2 := fe.operands[0], fe.operands[1]
-		return op1.Match(name) && op2.Match(name)
+		return op1.match(name, trans, p) && op2.match(name, trans, p)
 	case "or":
 		op1, op2 := fe.operands[0], fe.operands[1]
-		return op1.Match(name) || op2.Match(name)
+		return op1.match(name, trans, p) || op2.match(name, trans, p)
 	case "not":
-		return !fe.operands[0].Match(name)
+		return !fe.operands[0].match(name, trans, p)
+	}
+	if p == nil {
+		return true
 	}
-	panic("impossible situation")
+	return fe.matchpredicate(trans, p)
 }
 
 func (fe *Filterexpr) String() string {
 	switch fe.op {
 	case "match":
 		return fmt.Sprintf(`"re:%v"`, fe.pattern)
+	case "meta":
+		if fe.pattern == "" {
+			return fmt.Sprintf(`"%%%v"`, fe.key)
+		}
+		return fmt.Sprintf(`"%%%v=%v"`, fe.key, fe.pattern)
 	case "and":
 		op1, op2 := fe.operands[0], fe.operands[1]
 		return fmt.Sprintf(`(%v and %v)`, op1, op2)
@@ -255,5 +305,5 @@ func (fe *Filterexpr) String() string {
 		op := fe.operands[0]
 		return fmt.Sprintf(`(not %v)`, op)
 	}
-	panic("impossible situation")
+	return fmt.Sprintf(`"%v:%v"`, fe.op, fe.pattern)
 }
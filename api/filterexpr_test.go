@@ -3,6 +3,7 @@ package api
 import "fmt"
 import "reflect"
 import "testing"
+import "time"
 import "github.com/prataprc/goparsec"
 
 var _ = fmt.Sprintf("dummy")
@@ -130,3 +131,215 @@ func TestFilterExpr(t *testing.T) {
 		}
 	}
 }
+
+type testaccount struct {
+	Accounter
+	name string
+}
+
+func (acc *testaccount) Name() string {
+	return acc.name
+}
+
+type testposting struct {
+	Poster
+	accname  string
+	tags     []string
+	metadata map[string]interface{}
+}
+
+func (p *testposting) Account() Accounter {
+	return &testaccount{name: p.accname}
+}
+
+func (p *testposting) Tags() []string {
+	return p.tags
+}
+
+func (p *testposting) Metadata(key string) interface{} {
+	return p.metadata[key]
+}
+
+func TestFilterTags(t *testing.T) {
+	postings := []*testposting{
+		&testposting{
+			accname: "Expenses:Food", tags: []string{"reimbursable"},
+			metadata: map[string]interface{}{"project": "alpha"},
+		},
+		&testposting{
+			accname: "Expenses:Travel", tags: []string{},
+			metadata: map[string]interface{}{"project": "beta"},
+		},
+		&testposting{
+			accname: "Assets:Cash", tags: []string{"reimbursable"},
+			metadata: map[string]interface{}{},
+		},
+	}
+	testcases := [][]interface{}{
+		{[]string{"tag:reimb"}, []int{0, 2}},
+		{[]string{"%project"}, []int{0, 1}},
+		{[]string{"%Project=beta"}, []int{1}},
+		{[]string{"Expenses", "and", "tag:reimbursable"}, []int{0}},
+		{[]string{"not", "%project=alpha"}, []int{1, 2}},
+	}
+	for _, tcase := range testcases {
+		arg := MakeFilterexpr(tcase[0].([]string))
+		node, _ := YFilterExpr(parsec.NewScanner([]byte(arg)))
+		fe, ok := node.(*Filterexpr)
+		if ok == false {
+			t.Fatalf("%v: %v", arg, node)
+		} else if fe.Predicates() == false {
+			t.Errorf("%v: expected predicate terms", arg)
+		}
+		matches := []int{}
+		for i, p := range postings {
+			if fe.MatchPosting(nil, p) {
+				matches = append(matches, i)
+			}
+		}
+		if reflect.DeepEqual(matches, tcase[1]) == false {
+			t.Errorf("%v: expected %v, got %v", arg, tcase[1], matches)
+		}
+	}
+}
+
+type testtrans struct {
+	Transactor
+	date time.Time
+	desc string
+	code string
+}
+
+func (trans *testtrans) Date() time.Time {
+	return trans.date
+}
+
+func (trans *testtrans) Description() string {
+	return trans.desc
+}
+
+func (trans *testtrans) Code() string {
+	return trans.code
+}
+
+type testcommodity struct {
+	Commoditiser
+	name   string
+	amount Decimal
+}
+
+func (comm *testcommodity) Name() string {
+	return comm.name
+}
+
+func (comm *testcommodity) Amount() Decimal {
+	return comm.amount
+}
+
+type testpredposting struct {
+	testposting
+	payee   string
+	state   string
+	virtual bool
+	comm    *testcommodity
+}
+
+func (p *testpredposting) Payee() string {
+	return p.payee
+}
+
+func (p *testpredposting) State() string {
+	return p.state
+}
+
+func (p *testpredposting) IsVirtual() bool {
+	return p.virtual
+}
+
+func (p *testpredposting) Commodity() Commoditiser {
+	return p.comm
+}
+
+func TestFilterPredicates(t *testing.T) {
+	date := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	trans := []*testtrans{
+		&testtrans{date: date("2024-01-15"), desc: "Whole Foods", code: "101"},
+		&testtrans{date: date("2024-03-31"), desc: "Airline", code: ""},
+		&testtrans{date: date("2024-04-01"), desc: "Salary", code: "202"},
+	}
+	postings := []*testpredposting{
+		&testpredposting{
+			testposting: testposting{accname: "Expenses:Food"},
+			payee:       "Grocery", state: "cleared",
+			comm: &testcommodity{name: "$", amount: NewDecimal(120)},
+		},
+		&testpredposting{
+			testposting: testposting{accname: "Expenses:Travel"},
+			payee:       "Airline", state: "pending", virtual: true,
+			comm: &testcommodity{name: "EUR", amount: NewDecimal(-80)},
+		},
+		&testpredposting{
+			testposting: testposting{accname: "Income:Salary"},
+			payee:       "Employer", state: "uncleared",
+			comm: &testcommodity{name: "$", amount: NewDecimal(-1000)},
+		},
+	}
+	testcases := [][]interface{}{
+		{[]string{"payee:^Gro"}, []int{0}},
+		{[]string{"desc:Foods", "or", "desc:Salary"}, []int{0, 2}},
+		{[]string{"code:."}, []int{0, 2}},
+		{[]string{"amt:>100"}, []int{0, 2}},
+		{[]string{"amt:<-100"}, []int{2}},
+		{[]string{"amt:80"}, []int{1}},
+		{[]string{"amt:>=-80"}, []int{0, 1}},
+		{[]string{"date:2024-01..2024-03"}, []int{0, 1}},
+		{[]string{"date:2024/04.."}, []int{2}},
+		{[]string{"date:..2024-01-15"}, []int{0}},
+		{[]string{"date:2024"}, []int{0, 1, 2}},
+		{[]string{"cur:$"}, []int{0, 2}},
+		{[]string{"status:*"}, []int{0}},
+		{[]string{"status:!"}, []int{1}},
+		{[]string{"status:"}, []int{2}},
+		{[]string{"virtual:"}, []int{1}},
+		{[]string{"Expenses", "and", "real:"}, []int{0}},
+		{[]string{"not", "(cur:$", "or", "status:!)"}, []int{}},
+	}
+	for _, tcase := range testcases {
+		arg := MakeFilterexpr(tcase[0].([]string))
+		node, _ := YFilterExpr(parsec.NewScanner([]byte(arg)))
+		fe, ok := node.(*Filterexpr)
+		if ok == false {
+			t.Fatalf("%v: %v", arg, node)
+		} else if fe.Predicates() == false {
+			t.Errorf("%v: expected predicate terms", arg)
+		}
+		matches := []int{}
+		for i, p := range postings {
+			if fe.MatchPosting(trans[i], p) {
+				matches = append(matches, i)
+			}
+		}
+		if reflect.DeepEqual(matches, tcase[1]) == false {
+			t.Errorf("%v: expected %v, got %v", arg, tcase[1], matches)
+		}
+	}
+
+	// account names are still matched as regular expressions.
+	node, _ := YFilterExpr(parsec.NewScanner([]byte("payee")))
+	if fe := node.(*Filterexpr); fe.Predicates() {
+		t.Errorf("unexpected predicate in %v", fe)
+	}
+
+	for _, arg := range []string{"amt:>x", "date:2024-13", "status:?"} {
+		node, _ := YFilterExpr(parsec.NewScanner([]byte(arg)))
+		if _, ok := node.(error); ok == false {
+			t.Errorf("%v: expected error, got %v", arg, node)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import "fmt"
+import "time"
+import "regexp"
+import "strings"
+
+// predicates that can prefix a filter term, like `payee:Grocery`.
+var predicates = []string{
+	"payee", "desc", "code", "amt", "date", "cur", "status", "tag",
+	"real", "virtual",
+}
+
+var reamount = regexp.MustCompile(`^(>=|<=|>|<|=)?([+-]?)([0-9.]+)$`)
+
+// newPredicate parse filter term as predicate, return nil if term is not
+// a predicate.
+//
+//	payee:REGEX     posting's payee.
+//	desc:REGEX      transaction's description, as in journal.
+//	code:REGEX      transaction's code.
+//	amt:[OP]N       posting's amount, OP can be <, <=, >, >=, =. If N is
+//	                unsigned, amount is compared in absolute value.
+//	date:B..E       transaction's date, B and E can be YYYY, YYYY-MM or
+//	                YYYY-MM-DD, both inclusive, either can be skipped.
+//	cur:NAME        posting's commodity, matched as is.
+//	status:S        posting's state, `*`, `!` or empty for uncleared.
+//	tag:REGEX       posting's or transaction's tag.
+//	real:           real postings.
+//	virtual:        virtual postings.
+//	%KEY[=REGEX]    posting's or transaction's metadata.
+func newPredicate(term string) (*Filterexpr, error) {
+	var err error
+
+	if strings.HasPrefix(term, "%") && len(term) > 1 {
+		fe := &Filterexpr{op: "meta", key: term[1:]}
+		if off := strings.Index(fe.key, "="); off >= 0 {
+			fe.key, fe.pattern = fe.key[:off], fe.key[off+1:]
+		}
+		fe.key = strings.ToLower(fe.key)
+		if err = fe.compile(); err != nil {
+			return nil, err
+		}
+		return fe, nil
+	}
+
+	off := strings.Index(term, ":")
+	if off < 0 || HasString(predicates, term[:off]) == false {
+		return nil, nil
+	}
+	fe := &Filterexpr{op: term[:off], pattern: term[off+1:]}
+	switch fe.op {
+	case "payee", "desc", "code", "tag":
+		err = fe.compile()
+	case "amt":
+		err = fe.parseamount()
+	case "date":
+		fe.begin, fe.end, err = parsedaterange(fe.pattern)
+	case "status":
+		fe.key, err = parsestatus(fe.pattern)
+	case "real", "virtual":
+		if fe.pattern != "" {
+			err = fmt.Errorf("%v: does not take a value", fe.op)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fe, nil
+}
+
+func (fe *Filterexpr) compile() (err error) {
+	if fe.pattern != "" {
+		fe.regc, err = regexp.Compile(fe.pattern)
+	}
+	return err
+}
+
+func (fe *Filterexpr) parseamount() (err error) {
+	matches := reamount.FindStringSubmatch(fe.pattern)
+	if matches == nil {
+		return fmt.Errorf("invalid amount %q", fe.pattern)
+	}
+	fe.cmp, fe.signed = matches[1], matches[2] != ""
+	if fe.cmp == "" {
+		fe.cmp = "="
+	}
+	if fe.amount, err = ParseDecimal(matches[3]); err != nil {
+		return err
+	} else if matches[2] == "-" {
+		fe.amount = fe.amount.Neg()
+	}
+	return nil
+}
+
+// parsedaterange like `2024-01..2024-03`, return begin and end, end being
+// the day after the inclusive end.
+func parsedaterange(s string) (begin, end time.Time, err error) {
+	parts := strings.SplitN(s, "..", 2)
+	if len(parts) == 1 {
+		parts = []string{s, s}
+	}
+	if parts[0] != "" {
+		if begin, _, err = parseperiod(parts[0]); err != nil {
+			return begin, end, err
+		}
+	}
+	if parts[1] != "" {
+		if _, end, err = parseperiod(parts[1]); err != nil {
+			return begin, end, err
+		}
+	}
+	return begin, end, nil
+}
+
+// parseperiod like `2024`, `2024-03` or `2024-03-15`, return the first
+// day of the period and the first day after the period.
+func parseperiod(s string) (time.Time, time.Time, error) {
+	s = strings.Replace(s, "/", "-", -1)
+	layouts := []string{"2006-1-2", "2006-1", "2006"}
+	periods := [][]int{{0, 0, 1}, {0, 1, 0}, {1, 0, 0}}
+	for i, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			period := periods[i]
+			return t, t.AddDate(period[0], period[1], period[2]), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func parsestatus(s string) (string, error) {
+	switch s {
+	case "*", "cleared":
+		return "cleared", nil
+	case "!", "pending":
+		return "pending", nil
+	case "", "uncleared":
+		return "uncleared", nil
+	}
+	return "", fmt.Errorf("invalid status %q", s)
+}
+
+func (fe *Filterexpr) matchpredicate(trans Transactor, p Poster) bool {
+	switch fe.op {
+	case "payee":
+		return fe.matchstring(p.Payee())
+	case "desc":
+		return fe.matchstring(trans.Description())
+	case "code":
+		return fe.matchstring(trans.Code())
+	case "amt":
+		amount := p.Commodity().Amount()
+		if fe.signed == false {
+			amount = amount.Abs()
+		}
+		cmp := amount.Cmp(fe.amount)
+		switch fe.cmp {
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		case ">=":
+			return cmp >= 0
+		}
+		return cmp == 0
+	case "date":
+		date := trans.Date()
+		if fe.begin.IsZero() == false && date.Before(fe.begin) {
+			return false
+		}
+		return fe.end.IsZero() || date.Before(fe.end)
+	case "cur":
+		return p.Commodity().Name() == fe.pattern
+	case "status":
+		return p.State() == fe.key
+	case "tag":
+		for _, tag := range p.Tags() {
+			if fe.matchstring(tag) {
+				return true
+			}
+		}
+		return false
+	case "meta":
+		value := p.Metadata(fe.key)
+		return value != nil && fe.matchstring(fmt.Sprintf("%v", value))
+	case "real":
+		return p.IsVirtual() == false
+	case "virtual":
+		return p.IsVirtual()
+	}
+	panic("impossible situation")
+}
+
+func (fe *Filterexpr) matchstring(s string) bool {
+	return fe.regc == nil || fe.regc.MatchString(s)
+}
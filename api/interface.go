@@ -15,6 +15,11 @@ type Datastorer interface {
 	// commodity instansiation.
 	GetCommodity(name string) Commoditiser
 
+	// GetPrice return the latest known price for one unit of commodity
+	// `this`, quoted in commodity `other`, on or before `when`. Return nil
+	// if no such price is available in the price database.
+	GetPrice(this, other string, when time.Time) Commoditiser
+
 	// Accountnames return list of all account names.
 	Accountnames() []string
 
@@ -72,6 +77,12 @@ type Transactor interface {
 	// organization that is paid money.
 	Payee() string
 
+	// Description of transaction as in journal, before payee rewrite.
+	Description() string
+
+	// Code of transaction, like check number, empty if unspecified.
+	Code() string
+
 	// GetPostings return list of all postings under this transaction.
 	GetPostings() []Poster
 
@@ -83,6 +94,13 @@ type Transactor interface {
 
 	// Printlines return the original lines from which transaction was parsed.
 	Printlines() []string
+
+	// Tags return list of tags, like `:reimbursable:`, on transaction.
+	Tags() []string
+
+	// Metadata return the value of metadata key, like `project: alpha`,
+	// nil if transaction is not tagged with key.
+	Metadata(key string) interface{}
 }
 
 // Poster encapsulates a single posting within a transaction.
@@ -111,6 +129,21 @@ type Poster interface {
 
 	// Account to which the commodity should be posted.
 	Account() Accounter
+
+	// IsVirtual return true if posting is to a virtual account, like
+	// `(Budget:Food)` or `[Assets:Savings]`.
+	IsVirtual() bool
+
+	// State of posting, cleared, pending or uncleared. If unspecified in
+	// the posting, shall return the transaction's state.
+	State() string
+
+	// Tags return list of tags on this posting and on its transaction.
+	Tags() []string
+
+	// Metadata return the value of metadata key, if unspecified in the
+	// posting, shall return the transaction's metadata.
+	Metadata(key string) interface{}
 }
 
 // Commoditiser encapsulates a commodity.
@@ -122,7 +155,7 @@ type Commoditiser interface {
 	Notes() []string
 
 	// Amount as in quantity, not necessarily as value.
-	Amount() float64
+	Amount() Decimal
 
 	// Currency is true if commodity is of type bool.
 	Currency() bool
@@ -141,7 +174,7 @@ type Commoditiser interface {
 	IsDebit() bool
 
 	// MakeSimilar create a new instance of commodity simlar to this commodity
-	MakeSimilar(amount float64) Commoditiser
+	MakeSimilar(amount Decimal) Commoditiser
 
 	// Directive return the commodity details as directive declaration.
 	Directive() string
@@ -205,22 +238,23 @@ type Reporter interface {
 }
 
 // Formatter implements are uniform tabularized {row,column} formatting across
-// all types under dblentry package.
+// all types under dblentry package. Cells are typed, as string, time.Time
+// for dates and Commoditiser for amounts, empty cells are "".
 type Formatter interface {
 	// FmtBalances used for `balance` reporting.
-	FmtBalances(Datastorer, Transactor, Poster, Accounter) [][]string
+	FmtBalances(Datastorer, Transactor, Poster, Accounter) [][]interface{}
 
 	// FmtDCBalances used for `balance` reporting, in debit-credit format.
-	FmtDCBalances(Datastorer, Transactor, Poster, Accounter) [][]string
+	FmtDCBalances(Datastorer, Transactor, Poster, Accounter) [][]interface{}
 
 	// FmtRegister used for `register` reporting.
-	FmtRegister(Datastorer, Transactor, Poster, Accounter) [][]string
+	FmtRegister(Datastorer, Transactor, Poster, Accounter) [][]interface{}
 
 	// FmtEquity used for `equity` reporting.
-	FmtEquity(Datastorer, Transactor, Poster, Accounter) [][]string
+	FmtEquity(Datastorer, Transactor, Poster, Accounter) [][]interface{}
 
 	// FmtPassbook used for `passbook` reporting.
-	FmtPassbook(Datastorer, Transactor, Poster, Accounter) [][]string
+	FmtPassbook(Datastorer, Transactor, Poster, Accounter) [][]interface{}
 }
 
 // Store maintains an index of key,vlue pairs, key being time.Time and value
@@ -3,8 +3,11 @@ package main
 import "fmt"
 import "time"
 import "regexp"
+import "strings"
+import "strconv"
 
 import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
 import "github.com/prataprc/goparsec"
 
 func yperiod(year, month, day int) parsec.Parser {
@@ -79,30 +82,30 @@ func yspec(year, month, day int) parsec.Parser {
 	pattmn := `([0-9]{1,2}|` + mnname + `)`
 	pattdt := `([0-9]{1,2})`
 
-	pattern := pattyr + delimit + pattmn + delimit + pattdt
+	pattern1 := pattyr + delimit + pattmn + delimit + pattdt
 	ydate1 := parsec.And( // 2004/10/1
 		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
 			t := nodes[0].(*parsec.Terminal)
-			regc, _ := regexp.Compile(pattern)
+			regc, _ := regexp.Compile(pattern1)
 			parts := regc.FindStringSubmatch(string(t.Value))
 			year = lookupyear[parts[1]]
 			month = lookupmonth[parts[2]]
 			day = lookupdate[parts[3]]
 			return [3]int{year, month, day}
 		},
-		parsec.Token(pattern, ""),
+		parsec.Token(pattern1, ""),
 	)
 
-	pattern = pattyr + delimit + pattmn
+	pattern2 := pattyr + delimit + pattmn
 	ydate2 := parsec.And( // 2004/10
 		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
 			t := nodes[0].(*parsec.Terminal)
-			regc, _ := regexp.Compile(pattern)
+			regc, _ := regexp.Compile(pattern2)
 			parts := regc.FindStringSubmatch(string(t.Value))
 			year, month = lookupyear[parts[1]], lookupmonth[parts[2]]
 			return [3]int{year, month, day}
 		},
-		parsec.Token(pattern, ""),
+		parsec.Token(pattern2, ""),
 	)
 	ydate3 := parsec.And( // 2004
 		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
@@ -193,6 +196,115 @@ func yspec(year, month, day int) parsec.Parser {
 	)
 }
 
+// setperiod parse period expression of a periodic transaction, like
+// "monthly from 2011/01 to 2012/01", using the period grammar. Dates
+// without year fall in `year`.
+func setperiod(pt *dblentry.Periodic, year int) error {
+	expr := strings.ToLower(pt.Periodexpr())
+	scanner := parsec.NewScanner([]byte(expr))
+	node, scanner := yperiod(year, 0, 0)(scanner)
+	if _, scanner = scanner.SkipWS(); scanner.Endof() == false {
+		return fmt.Errorf("invalid period %q", pt.Periodexpr())
+	}
+	nodes := node.([]parsec.ParsecNode)
+
+	interval, every := "", 1
+	if items, ok := nodes[0].([]parsec.ParsecNode); ok {
+		switch v := items[0].([]parsec.ParsecNode)[0].(type) {
+		case *parsec.Terminal:
+			interval, every = lookupinterval[v.Name], 1
+			if v.Name == "BIWEEKLY" || v.Name == "BIMONTHLY" {
+				every = 2
+			}
+		case []parsec.ParsecNode: // every N days|weeks|months|...
+			interval = lookupinterval[v[2].(*parsec.Terminal).Name]
+			every, _ = strconv.Atoi(v[1].(*parsec.Terminal).Value)
+		}
+	}
+	if interval == "" {
+		return fmt.Errorf("period %q has no interval", pt.Periodexpr())
+	}
+
+	var begin, end time.Time
+	// in SPEC or SPEC
+	for _, item := range nodes[1:3] {
+		if items, ok := item.([]parsec.ParsecNode); ok {
+			from, till, err := periodspan(items[0])
+			if err != nil {
+				return err
+			}
+			begin, end = from, till
+		}
+	}
+	// from SPEC
+	if items, ok := nodes[3].([]parsec.ParsecNode); ok {
+		from, _, err := periodspan(items[0])
+		if err != nil {
+			return err
+		}
+		begin = from
+	}
+	// to SPEC
+	if items, ok := nodes[4].([]parsec.ParsecNode); ok {
+		till, _, err := periodspan(items[0])
+		if err != nil {
+			return err
+		}
+		end = till
+	}
+	return pt.SetPeriod(interval, every, begin, end)
+}
+
+// periodspan return the span of days covered by date spec, a spec with
+// missing day covers the whole month, with missing month covers the
+// whole year.
+func periodspan(node parsec.ParsecNode) (from, till time.Time, err error) {
+	if err, ok := node.(error); ok {
+		return from, till, err
+	}
+	ymd := node.([3]int)
+	year, month, day := ymd[0], ymd[1], ymd[2]
+	switch {
+	case month == 0:
+		from = time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
+		till = from.AddDate(1, 0, 0)
+	case day == 0:
+		from = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+		till = from.AddDate(0, 1, 0)
+	default:
+		from = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+		till = from.AddDate(0, 0, 1)
+	}
+	if month < 0 || month > 12 {
+		return from, till, fmt.Errorf("invalid month %v", month)
+	} else if day == 0 {
+		return from, till, nil
+	} else if api.ValidateDate(from, year, month, day, 0, 0, 0) == false {
+		return from, till, fmt.Errorf("invalid date %v/%v/%v", year, month, day)
+	}
+	return from, till, nil
+}
+
+var lookupinterval = map[string]string{
+	"EVERYDAY":     dblentry.IntervalDay,
+	"EVERYWEEK":    dblentry.IntervalWeek,
+	"EVERYMONTH":   dblentry.IntervalMonth,
+	"EVERYQUARTER": dblentry.IntervalQuarter,
+	"EVERYYEAR":    dblentry.IntervalYear,
+	"DAYS":         dblentry.IntervalDay,
+	"WEEKS":        dblentry.IntervalWeek,
+	"MONTHS":       dblentry.IntervalMonth,
+	"QUARTERS":     dblentry.IntervalQuarter,
+	"YEARS":        dblentry.IntervalYear,
+	"DAILY":        dblentry.IntervalDay,
+	"WEEKLY":       dblentry.IntervalWeek,
+	"BIWEEKLY":     dblentry.IntervalWeek,
+	"MONTHLY":      dblentry.IntervalMonth,
+	"BIMONTHLY":    dblentry.IntervalMonth,
+	"QUARTERLY":    dblentry.IntervalQuarter,
+	"YEARLY":       dblentry.IntervalYear,
+}
+
 var lookupyear = map[string]int{}
 var lookupmonth = map[string]int{
 	"Jan": 1, "Feb": 2, "Mar": 3, "Apr": 4, "May": 5, "Jun": 6,
@@ -224,17 +336,13 @@ func init() {
 		lookupyear[fmt.Sprintf("%04v", i)] = i
 	}
 	// month lookup
-	for i := 0; i < 12; i++ {
-		lookupyear[fmt.Sprintf("%v", i)] = i
-	}
-	for i := 0; i < 12; i++ {
-		lookupyear[fmt.Sprintf("%02v", i)] = i
+	for i := 1; i <= 12; i++ {
+		lookupmonth[fmt.Sprintf("%v", i)] = i
+		lookupmonth[fmt.Sprintf("%02v", i)] = i
 	}
 	// date lookup
-	for i := 0; i < 31; i++ {
-		lookupyear[fmt.Sprintf("%v", i)] = i
-	}
-	for i := 0; i < 31; i++ {
-		lookupyear[fmt.Sprintf("%02v", i)] = i
+	for i := 1; i <= 31; i++ {
+		lookupdate[fmt.Sprintf("%v", i)] = i
+		lookupdate[fmt.Sprintf("%02v", i)] = i
 	}
 }
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+import "github.com/prataprc/goparsec"
+
+func TestYspec(t *testing.T) {
+	testcases := [][2]interface{}{
+		{"2011/02/05", [3]int{2011, 2, 5}},
+		{"2011-2-5", [3]int{2011, 2, 5}},
+		{"2011.12.31", [3]int{2011, 12, 31}},
+		{"2011/02", [3]int{2011, 2, 0}},
+		{"2011/9", [3]int{2011, 9, 0}},
+		{"2011/feb", [3]int{2011, 2, 0}},
+		{"2011", [3]int{2011, 0, 0}},
+		{"feb", [3]int{2010, 2, 0}},
+	}
+	for _, tcase := range testcases {
+		scanner := parsec.NewScanner([]byte(tcase[0].(string)))
+		node, _ := yspec(2010, 0, 0)(scanner)
+		if ymd, ok := node.([3]int); ok == false {
+			t.Errorf("for %q expected %v, got %v", tcase[0], tcase[1], node)
+		} else if ymd != tcase[1].([3]int) {
+			t.Errorf("for %q expected %v, got %v", tcase[0], tcase[1], ymd)
+		}
+	}
+}
+
+func TestLookupmonth(t *testing.T) {
+	for _, s := range []string{"0", "00", "13"} {
+		if _, ok := lookupmonth[s]; ok {
+			t.Errorf("unexpected month %q", s)
+		}
+	}
+	for _, s := range []string{"0", "00", "32"} {
+		if _, ok := lookupdate[s]; ok {
+			t.Errorf("unexpected date %q", s)
+		}
+	}
+	if month := lookupmonth["02"]; month != 2 {
+		t.Errorf("expected %v, got %v", 2, month)
+	}
+	if date := lookupdate["9"]; date != 9 {
+		t.Errorf("expected %v, got %v", 9, date)
+	}
+}
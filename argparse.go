@@ -12,7 +12,8 @@ import "github.com/tn47/goledger/api"
 import "github.com/tn47/goledger/dblentry"
 
 func argparse() ([]string, error) {
-	var journals, outfile, finyear, begindt, enddt string
+	var journals, outfile, finyear, begindt, enddt, forecast string
+	var stmtdate string
 
 	f := flag.NewFlagSet("ledger", flag.ExitOnError)
 	f.Usage = func() {
@@ -41,14 +42,16 @@ func argparse() ([]string, error) {
 		"Don't accumulate postings on sub-leger to parent ledger.")
 	f.BoolVar(&api.Options.Subtotal, "subtotal", false,
 		"all transactions to be collapsed into a single, transaction")
-	f.BoolVar(&api.Options.Cleared, "cleared", true,
+	f.BoolVar(&api.Options.Cleared, "cleared", false,
 		"Display only cleared postings.")
-	f.BoolVar(&api.Options.Uncleared, "uncleared", true,
+	f.BoolVar(&api.Options.Uncleared, "uncleared", false,
 		"Display only uncleared postings.")
-	f.BoolVar(&api.Options.Pending, "pending", true,
+	f.BoolVar(&api.Options.Pending, "pending", false,
 		"Display only pending postings.")
-	f.BoolVar(&api.Options.Dcformat, "dc", false,
+	f.BoolVar(&api.Options.Real, "real", false,
 		"Display only real postings.")
+	f.BoolVar(&api.Options.Dcformat, "dc", false,
+		"Display debit and credit in separate columns.")
 	f.BoolVar(&api.Options.Strict, "strict", false,
 		"Accounts, tags or commodities not previously declared "+
 			"will cause warnings.")
@@ -67,6 +70,8 @@ func argparse() ([]string, error) {
 		"for register, passbook commands list details")
 	f.BoolVar(&api.Options.Bypayee, "bypayee", false,
 		"Group postings by common payee names")
+	f.StringVar(&api.Options.Bytag, "by-tag", "",
+		"Group balance by values of tag or metadata KEY")
 	f.BoolVar(&api.Options.Daily, "daily", false,
 		"Group postings by day")
 	f.BoolVar(&api.Options.Weekly, "weekly", false,
@@ -79,8 +84,34 @@ func argparse() ([]string, error) {
 		"Group postings by yearly")
 	f.BoolVar(&api.Options.Dow, "dow", false,
 		"Group postings by day of the week")
+	f.BoolVar(&api.Options.Market, "market", false,
+		"Report amounts in their market value, as on the end date")
+	f.StringVar(&api.Options.Exchange, "exchange", "",
+		"Report amounts in market value of commodity, implies -market")
+	f.StringVar(&api.Options.Lotpolicy, "lots", dblentry.LotFifo,
+		"Policy to consume lots on sale, fifo or lifo")
+	f.StringVar(&forecast, "forecast", "",
+		"Forecast periodic transactions till date")
+	f.StringVar(&api.Options.Stmtbal, "statement-balance", "",
+		"Statement balance to reconcile with")
+	f.StringVar(&stmtdate, "date", "",
+		"Statement date to reconcile with")
+	f.StringVar(&api.Options.Importacc, "account", "",
+		"Account to import bank statement into")
+	f.StringVar(&api.Options.Rules, "rules", "",
+		"Rules file to map statement columns to transactions")
 	f.BoolVar(&api.Options.Verbose, "v", false,
 		"verbose reporting / listing")
+	f.StringVar(&api.Options.Outformat, "format", "text",
+		"Output format for reports: text, csv, tsv, json, html or markdown")
+	f.BoolVar(&api.Options.Collapse, "collapse", false,
+		"Collapse sub-accounts under <details>, for html and markdown")
+	f.StringVar(&api.Options.Addr, "addr", "localhost:8080",
+		"Address to listen for serve command")
+	f.BoolVar(&api.Options.Watch, "watch", false,
+		"Re-run the report when journals are modified")
+	f.BoolVar(&api.Options.Firstuse, "format-from-first-use", false,
+		"Format commodities as first used, ignoring declared formats")
 
 	f.StringVar(&api.Options.Loglevel, "log", "info",
 		"Console log level")
@@ -97,6 +128,26 @@ func argparse() ([]string, error) {
 	}
 	log.SetLogger(nil, logsetts)
 
+	switch api.Options.Lotpolicy {
+	case dblentry.LotFifo, dblentry.LotLifo:
+	default:
+		err := fmt.Errorf("invalid lot policy %q", api.Options.Lotpolicy)
+		log.Errorf("%v\n", err)
+		return nil, err
+	}
+
+	switch api.Options.Outformat {
+	case "text", "csv", "tsv", "json", "html", "markdown":
+	default:
+		err := fmt.Errorf("invalid output format %q", api.Options.Outformat)
+		log.Errorf("%v\n", err)
+		return nil, err
+	}
+
+	if api.Options.Exchange != "" {
+		api.Options.Market = true
+	}
+
 	api.Options.Journals = gatherjournals(journals)
 	api.Options.Outfd = argOutfd(outfile)
 
@@ -143,6 +194,30 @@ func argparse() ([]string, error) {
 		}
 		api.Options.Enddt = &tm
 	}
+
+	if forecast != "" {
+		scanner := parsec.NewScanner([]byte(forecast))
+		node, _ := dblentry.Ydate(time.Now().Year())(scanner)
+		tm, ok := node.(time.Time)
+		if ok == false {
+			err := fmt.Errorf("invalid date %q: %v\n", forecast, node)
+			log.Errorf("%v\n", err)
+			return nil, err
+		}
+		api.Options.Forecast = &tm
+	}
+
+	if stmtdate != "" {
+		scanner := parsec.NewScanner([]byte(stmtdate))
+		node, _ := dblentry.Ydate(time.Now().Year())(scanner)
+		tm, ok := node.(time.Time)
+		if ok == false {
+			err := fmt.Errorf("invalid date %q: %v\n", stmtdate, node)
+			log.Errorf("%v\n", err)
+			return nil, err
+		}
+		api.Options.Stmtdate = &tm
+	}
 	return f.Args(), nil
 }
 
@@ -0,0 +1,69 @@
+package main
+
+import "sync"
+
+import "github.com/tn47/goledger/dblentry"
+
+// jcache remember journals as parsed, so that reloading journals, with
+// -watch and serve, shall parse only the modified journals. Nil, for
+// commands that load journals only once.
+var jcache *journalcache
+
+// journalcache of parsed journal entries, one entry for each journal file.
+type journalcache struct {
+	mu       sync.Mutex
+	journals map[string]*cachedjournal // journal file -> parsed entries
+}
+
+// cachedjournal is valid for journal file with the same content, and for
+// the same parse state at the beginning of the journal.
+type cachedjournal struct {
+	hash    uint64 // api.Crc64 of journal's content.
+	state   uint64 // datastore's Parsestate() before parsing the journal.
+	entries []*cachedentry
+	err     error // from iterating the blocks.
+	eof     bool
+}
+
+// cachedentry is a block from journal, as parsed.
+type cachedentry struct {
+	begin  int // lineno of block's first line.
+	end    int // lineno after parsing the block.
+	block  []string
+	parsed *dblentry.Parsed
+	// for include directive, datastore's Parsestate() after the included
+	// journal, entries following the include are parsed again if this
+	// state has changed.
+	state uint64
+}
+
+func newjournalcache() *journalcache {
+	return &journalcache{journals: map[string]*cachedjournal{}}
+}
+
+// get parsed entries for journal file, return nil if journal is not
+// cached or if the cached entries are stale.
+func (jc *journalcache) get(
+	journalfile string, hash, state uint64) *cachedjournal {
+
+	if jc == nil {
+		return nil
+	}
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+	cached, ok := jc.journals[journalfile]
+	if ok && cached.hash == hash && cached.state == state {
+		return cached
+	}
+	return nil
+}
+
+// put parsed entries for journal file, replacing the stale entries.
+func (jc *journalcache) put(journalfile string, cached *cachedjournal) {
+	if jc == nil {
+		return
+	}
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+	jc.journals[journalfile] = cached
+}
@@ -24,6 +24,9 @@ type Account struct {
 	payees   []string
 	types    []string
 	comments []string
+	checks   []*Expression
+	asserts  []*Expression
+	evals    []*Expression
 }
 
 // NewAccount create a new instance of Account{}.
@@ -74,6 +77,23 @@ func (acc *Account) addComments(comments ...string) *Account {
 	return acc
 }
 
+// addExpressions from check, assert and eval sub-directives.
+func (acc *Account) addExpressions(db *Datastore, d *Directive) error {
+	texts := []string{d.acccheck, d.accassert, d.acceval}
+	lists := []*[]*Expression{&acc.checks, &acc.asserts, &acc.evals}
+	for i, text := range texts {
+		if text == "" {
+			continue
+		}
+		expr, err := NewExpression(db, text)
+		if err != nil {
+			return err
+		}
+		*lists[i] = append(*lists[i], expr)
+	}
+	return nil
+}
+
 func (acc *Account) isUnknown() bool {
 	if acc.name == "Unknown" || strings.HasSuffix(acc.name, ":Unknown") {
 		return true
@@ -139,63 +159,61 @@ func (acc *Account) String() string {
 
 func (acc *Account) FmtBalances(
 	db api.Datastorer, trans api.Transactor, p api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
 	if len(acc.Balances()) == 0 {
 		return nil
 	}
 
-	rows := make([][]string, 0) // Date, Accountname, Balance
+	rows := make([][]interface{}, 0) // Date, Accountname, Balance
 	for _, balance := range acc.Balances() {
-		if balance.Amount() != 0 || acc.HasPosting() == false {
-			rows = append(rows, []string{"", "", balance.String()})
+		if balance.Amount().IsZero() == false || acc.HasPosting() == false {
+			rows = append(rows, []interface{}{"", "", fmtamount(balance)})
 		}
 	}
 	if len(rows) > 0 { // last row to include date and account name.
 		lastrow := rows[len(rows)-1]
-		date := trans.Date().Format("2006/Jan/02")
-		lastrow[0], lastrow[1] = date, acc.Name()
+		lastrow[0], lastrow[1] = trans.Date(), acc.Name()
 	}
 	return rows
 }
 
 func (acc *Account) FmtDCBalances(
 	db api.Datastorer, trans api.Transactor, p api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
 	if len(acc.Balances()) == 0 {
 		return nil
 	}
 
-	rows := make([][]string, 0) // Date, Accountname, Dr, Cr, Balance
+	rows := make([][]interface{}, 0) // Date, Accountname, Dr, Cr, Balance
 	for _, bal := range acc.Balances() {
 		name := bal.Name()
-		dr, cr := acc.Debit(name), acc.Credit(name)
-		cols := []string{"", "", dr.String(), cr.String(), bal.String()}
-		rows = append(rows, cols)
+		dr, cr := fmtamount(acc.Debit(name)), fmtamount(acc.Credit(name))
+		rows = append(rows, []interface{}{"", "", dr, cr, fmtamount(bal)})
 	}
 
 	if len(rows) > 0 { // last row to include date and account name.
 		lastrow := rows[len(rows)-1]
-		date := trans.Date().Format("2006/Jan/02")
-		lastrow[0], lastrow[1] = date, acc.Name()
+		lastrow[0], lastrow[1] = trans.Date(), acc.Name()
 	}
 	return rows
 }
 
 func (acc *Account) FmtEquity(
 	db api.Datastorer, trans api.Transactor, p api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
 	if len(acc.Balances()) == 0 {
 		return nil
 	}
 
-	var rows [][]string
+	var rows [][]interface{}
 
 	for _, balance := range acc.Balances() {
-		if balance.Amount() != 0 {
-			rows = append(rows, []string{"", acc.Name(), balance.String()})
+		if balance.Amount().IsZero() == false {
+			cols := []interface{}{"", acc.Name(), fmtamount(balance)}
+			rows = append(rows, cols)
 		}
 	}
 	return rows
@@ -203,35 +221,47 @@ func (acc *Account) FmtEquity(
 
 func (acc *Account) FmtRegister(
 	db api.Datastorer, trans api.Transactor, p api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
 	panic("not supported")
 }
 
 func (acc *Account) FmtPassbook(
 	db api.Datastorer, trans api.Transactor, p api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
-	rows := make([][]string, 0)
+	rows := make([][]interface{}, 0)
 	for _, balance := range acc.Balances() {
-		if balance.Amount() != 0 {
-			rows = append(rows, []string{"", "", "", "", balance.String()})
+		if balance.Amount().IsZero() == false {
+			cols := []interface{}{"", "", "", "", fmtamount(balance)}
+			rows = append(rows, cols)
 		}
 	}
 
 	if len(rows) > 0 {
 		comm := p.Commodity()
 		cols := rows[len(rows)-1] // pick the last balance entry
-		cols[0], cols[1] = trans.Date().Format("2006/Jan/02"), p.Payee()
+		cols[0], cols[1] = trans.Date(), p.Payee()
 		if comm.IsDebit() {
-			cols[2] = comm.String()
+			cols[2] = fmtamount(comm)
 		} else {
-			cols[3] = comm.MakeSimilar(-comm.Amount()).String()
+			cols[3] = comm.MakeSimilar(comm.Amount().Neg())
 		}
 	}
 	return rows
 }
 
+// fmtamount cell for commodity, a copy as balances are updated in place,
+// empty if there is no commodity.
+func fmtamount(comm api.Commoditiser) interface{} {
+	if c, ok := comm.(*Commodity); ok && c != nil {
+		return c.makeSimilar(c.amount)
+	} else if ok || comm == nil {
+		return ""
+	}
+	return comm.MakeSimilar(comm.Amount())
+}
+
 func (acc *Account) Directive() string {
 	lines := []string{fmt.Sprintf("account %v", acc.name)}
 	for _, note := range acc.notes {
@@ -346,6 +376,26 @@ func (acc *Account) Secondpass(
 			return fmt.Errorf(fmsg, accname, p.balprice.String(), bal.String())
 		}
 	}
+	return acc.evalExpressions(db, trans, p)
+}
+
+// evalExpressions from account's check, assert and eval sub-directives
+// on every posting to this account.
+func (acc *Account) evalExpressions(
+	db *Datastore, trans *Transaction, p *Posting) error {
+
+	ctx := &exprctx{db: db, date: trans.Date(), trans: trans, p: p}
+	lineno := trans.lineno - len(trans.lines) + 1 // first line of trans.
+	kinds := []string{"check", "assert", "eval"}
+	lists := [][]*Expression{acc.checks, acc.asserts, acc.evals}
+	for i, exprs := range lists {
+		for _, expr := range exprs {
+			err := evalassertion(kinds[i], expr, ctx, trans.journalfile, lineno)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -355,6 +405,14 @@ func (acc *Account) Clone(ndb *Datastore) *Account {
 	return &nacc
 }
 
+// Tally posting's commodity into account's balance. Reports can use this
+// on account created by NewAccount(), to tally a subset of postings, like
+// postings matching a tag filter.
+func (acc *Account) Tally(p api.Poster) error {
+	acc.setPosting()
+	return acc.addBalance(p.Commodity().(*Commodity))
+}
+
 func (acc *Account) addBalance(commodity *Commodity) error {
 	if err := acc.de.AddBalance(commodity); err != nil {
 		return err
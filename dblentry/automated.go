@@ -0,0 +1,204 @@
+package dblentry
+
+import "fmt"
+import "strings"
+
+import "github.com/prataprc/goparsec"
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+
+// Automated transaction, postings from this entry are injected into every
+// transaction that match the predicate, applicable to transactions
+// following this entry in the journal.
+//
+//	= Expenses:Shared
+//	    Expenses:Shared       -0.5
+//	    Liabilities:Partner    0.5
+//
+// Predicate is a filter expression matched with posting, or with
+// transaction's payee if prefixed with `payee`. Template amounts
+// without commodity are multipliers on the matching posting's amount,
+// amounts with commodity are posted as is.
+type Automated struct {
+	journalfile string
+	lineno      int
+	onpayee     bool
+	predicate   *api.Filterexpr
+	postings    []*autoposting
+}
+
+type autoposting struct {
+	account    *Account
+	multiplier api.Decimal
+	commodity  *Commodity // fixed amount, nil if multiplier.
+}
+
+// NewAutomated create a new automated transaction.
+func NewAutomated() *Automated {
+	return &Automated{postings: []*autoposting{}}
+}
+
+// SetPosition of this automated transaction in journal file.
+func (auto *Automated) SetPosition(journalfile string, lineno int) {
+	auto.journalfile, auto.lineno = journalfile, lineno
+}
+
+//---- ledger parser
+
+// Yledger return a parser-combinator that can parse the first line of an
+// automated transaction.
+func (auto *Automated) Yledger(db *Datastore) parsec.Parser {
+	y := parsec.And(
+		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
+			expr := strings.Trim(nodes[1].(*parsec.Terminal).Value, " \t")
+			if strings.HasPrefix(expr, "payee ") {
+				auto.onpayee, expr = true, strings.TrimLeft(expr[6:], " \t")
+			}
+			scanner := parsec.NewScanner([]byte(expr))
+			node, scanner := api.YFilterExpr(scanner)
+			if err, ok := node.(error); ok {
+				return err
+			} else if node == nil || scanner.Endof() == false {
+				return fmt.Errorf("invalid predicate %q", expr)
+			}
+			auto.predicate = node.(*api.Filterexpr)
+			if auto.onpayee && auto.predicate.Predicates() {
+				fmsg := "predicates not allowed with payee %q"
+				return fmt.Errorf(fmsg, expr)
+			}
+
+			log.Debugf("automated.yledger predicate:%v\n", auto.predicate)
+			return auto
+		},
+		ytokEqual, ytokValue,
+	)
+	return y
+}
+
+// Yledgerblock parse template postings within the automated transaction.
+func (auto *Automated) Yledgerblock(
+	db *Datastore, block []string) (int, error) {
+
+	if len(block) == 0 {
+		return 0, fmt.Errorf("automated transaction without postings")
+	}
+
+	var index int
+	var line string
+
+	for index, line = range block {
+		account, comm := NewAccount(""), NewCommodity("")
+		y := parsec.And(
+			nil,
+			account.Ypostaccn(db),
+			parsec.Maybe(maybenode, comm.Yledger(db)),
+			parsec.Maybe(maybenode, ytokPostnote),
+		)
+		scanner := parsec.NewScanner([]byte(line))
+		node, scanner := y(scanner)
+		if node == nil {
+			return index, fmt.Errorf("unable to parse posting %q", line)
+		}
+		_, scanner = parsec.Token(`[ \t]*`, "WS")(scanner)
+		if scanner.Endof() == false {
+			cursor := scanner.GetCursor()
+			return index, fmt.Errorf("unable to parse posting: %v", cursor)
+		}
+
+		items := node.([]parsec.ParsecNode)
+		if err, ok := items[1].(error); ok {
+			return index, err
+		} else if _, ok := items[1].(*Commodity); ok == false {
+			fmsg := "automated posting %q expects an amount"
+			return index, fmt.Errorf(fmsg, account.name)
+		}
+		ap := &autoposting{account: account, multiplier: comm.amount}
+		if comm.name != "" {
+			ap.commodity = db.getCommodity(comm.name, comm)
+			ap.commodity = ap.commodity.makeSimilar(comm.amount)
+		}
+		auto.postings = append(auto.postings, ap)
+	}
+	return index, nil
+}
+
+//---- engine
+
+func (auto *Automated) Firstpass(db *Datastore) error {
+	db.automated = append(db.automated, auto)
+	return nil
+}
+
+// apply automated postings on transaction, for every posting that match
+// the predicate, or once if predicate on transaction's payee matches.
+// Postings injected by automated transactions are not matched again.
+func (auto *Automated) apply(db *Datastore, trans *Transaction) error {
+	postings := trans.postings
+	if auto.onpayee {
+		if len(postings) == 0 || auto.predicate.Match(trans.Payee()) == false {
+			return nil
+		}
+		p := postings[0]
+		return auto.inject(db, trans, p, auto.amountof(trans, p))
+	}
+	for _, p := range postings {
+		amount := auto.amountof(trans, p)
+		if auto.match(db, trans, p, amount) == false {
+			continue
+		}
+		if err := auto.inject(db, trans, p, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match predicate with posting, as it would be after firstpass, with
+// account name resolved and elided amount implied.
+func (auto *Automated) match(
+	db *Datastore, trans *Transaction, p *Posting, amount *Commodity) bool {
+
+	mp := *p
+	mp.account = NewAccount(db.applyroot(db.lookupAlias(p.account.name)))
+	if mp.commodity = amount; amount == nil {
+		mp.commodity = NewCommodity("")
+	}
+	return auto.predicate.MatchPosting(trans, &mp)
+}
+
+// amountof posting, if posting's amount is elided, it is implied from
+// other postings in the transaction.
+func (auto *Automated) amountof(trans *Transaction, p *Posting) *Commodity {
+	if p.commodity != nil {
+		return p.commodity
+	}
+	if unbcs, _ := trans.doBalance(trans.balancing(p)); len(unbcs) == 1 {
+		return unbcs[0].makeSimilar(unbcs[0].amount.Neg())
+	}
+	return nil
+}
+
+func (auto *Automated) inject(
+	db *Datastore, trans *Transaction, p *Posting, amount *Commodity) error {
+
+	for _, ap := range auto.postings {
+		posting := NewPosting(trans)
+		posting.account = ap.account
+		posting.virtual = ap.account.virtual
+		posting.balanced = ap.account.balanced
+		if ap.commodity != nil {
+			posting.commodity = ap.commodity.makeSimilar(ap.commodity.amount)
+		} else if amount != nil {
+			quantity := amount.amount.Mul(ap.multiplier)
+			posting.commodity = amount.makeSimilar(quantity)
+		} else {
+			fmsg := "automated transaction at %q:%v : amount for %q not known"
+			name := ap.account.name
+			return fmt.Errorf(fmsg, auto.journalfile, auto.lineno, name)
+		}
+		fmsg := "automated posting %v %v\n"
+		log.Debugf(fmsg, posting.account, posting.commodity)
+		trans.postings = append(trans.postings, posting)
+	}
+	return nil
+}
@@ -0,0 +1,431 @@
+package dblentry
+
+import "fmt"
+import "sort"
+import "time"
+import "regexp"
+import "strings"
+
+import "github.com/tn47/goledger/api"
+
+// beancount accounts shall be under one of these root accounts.
+var bcroots = map[string]bool{
+	"Assets": true, "Liabilities": true, "Equity": true, "Income": true,
+	"Expenses": true,
+}
+
+// beancount commodities are upper case names, currency symbols are
+// mapped to their names.
+var bcsymbols = map[string]string{
+	"$": "USD", "€": "EUR", "£": "GBP", "¥": "JPY", "₹": "INR",
+}
+
+var rebcaccount = regexp.MustCompile(`^[A-Z0-9][A-Za-z0-9-]*$`)
+var rebccommodity = regexp.MustCompile(`^[A-Z]([A-Z0-9'._-]{0,22}[A-Z0-9])?$`)
+var rebcmetakey = regexp.MustCompile(`^[a-z][A-Za-z0-9_-]*$`)
+var rebctag = regexp.MustCompile(`^[A-Za-z0-9_/.-]+$`)
+
+// bcentry is a dated beancount directive.
+type bcentry struct {
+	date  time.Time
+	lines []string
+}
+
+// bcexport book-keeps datastore's export to beancount.
+type bcexport struct {
+	db          *Datastore
+	accounts    map[string]time.Time // account -> date of first use.
+	commodities map[string]time.Time // commodity -> date of first use.
+	balances    map[string]*bcentry  // account/date -> balance assertion.
+	entries     []*bcentry
+}
+
+// Beancount export accounts, commodities, prices and transactions in
+// beancount syntax. Shall be called after secondpass. Fail on journal
+// constructs that don't have an equivalent in beancount.
+func (db *Datastore) Beancount() ([]string, error) {
+	if err := db.bccheck(); err != nil {
+		return nil, err
+	}
+
+	bc := &bcexport{
+		db:          db,
+		accounts:    map[string]time.Time{},
+		commodities: map[string]time.Time{},
+		balances:    map[string]*bcentry{},
+		entries:     []*bcentry{},
+	}
+	entries := []api.TimeEntry{}
+	for _, entry := range db.pricedb.Range(nil, nil, "both", entries) {
+		if err := bc.addprice(entry.Value().(*Price)); err != nil {
+			return nil, err
+		}
+	}
+	entries = []api.TimeEntry{}
+	for _, entry := range db.transdb.Range(nil, nil, "both", entries) {
+		trans := entry.Value().(*Transaction)
+		if trans.forecast {
+			continue
+		} else if db.periodtill != nil && !trans.date.Before(*db.periodtill) {
+			continue
+		}
+		if err := bc.addtransaction(trans); err != nil {
+			journalfile, lineno := trans.Position()
+			return nil, fmt.Errorf("%v:%v: %v", journalfile, lineno, err)
+		}
+	}
+	sort.SliceStable(bc.entries, func(i, j int) bool {
+		return bc.entries[i].date.Before(bc.entries[j].date)
+	})
+
+	booking := "FIFO"
+	if api.Options.Lotpolicy == LotLifo {
+		booking = "LIFO"
+	}
+	lines := []string{fmt.Sprintf("option \"booking_method\" %q", booking), ""}
+	lines = append(lines, bc.commoditylines()...)
+	lines = append(lines, bc.openlines()...)
+	for _, entry := range bc.entries {
+		lines = append(lines, entry.lines...)
+		lines = append(lines, "")
+	}
+	return lines, nil
+}
+
+// bccheck fail on directives that don't have an equivalent in beancount.
+func (db *Datastore) bccheck() error {
+	for _, pt := range db.periodics {
+		fmsg := "periodic transaction `~ %v` has no beancount equivalent"
+		return fmt.Errorf(fmsg, pt.periodexpr)
+	}
+	entries := []api.TimeEntry{}
+	for _, entry := range db.checkdb.Range(nil, nil, "both", entries) {
+		d := entry.Value().(*Directive)
+		fmsg := "%v:%v: `%v` directive has no beancount equivalent"
+		return fmt.Errorf(fmsg, d.journalfile, d.lineno, d.dtype)
+	}
+	for _, name := range db.Accountnames() {
+		acc := db.accntdb[name]
+		if len(acc.checks)+len(acc.asserts)+len(acc.evals) > 0 {
+			fmsg := "account %q with check/assert/eval has no beancount equivalent"
+			return fmt.Errorf(fmsg, name)
+		}
+	}
+	return nil
+}
+
+func (bc *bcexport) addprice(price *Price) error {
+	this, err := bc.commodity(price.this, price.when)
+	if err != nil {
+		return err
+	}
+	other, err := bc.amount(price.other, price.when)
+	if err != nil {
+		return err
+	}
+	date := price.when.Format("2006-01-02")
+	line := fmt.Sprintf("%v price %v %v", date, this, other)
+	bc.entries = append(bc.entries, &bcentry{price.when, []string{line}})
+	return nil
+}
+
+func (bc *bcexport) addtransaction(trans *Transaction) error {
+	if trans.edate.IsZero() == false {
+		return fmt.Errorf("effective date has no beancount equivalent")
+	}
+
+	flag := bcflag(trans.getState())
+	if flag == "" {
+		flag = "txn"
+	}
+	notes := []string{}
+	for _, note := range trans.notes {
+		notes = append(notes, strings.TrimLeft(note, "; \t"))
+	}
+	narration := strings.TrimSpace(strings.Join(notes, " "))
+	header := fmt.Sprintf(
+		"%v %v %v %v", trans.date.Format("2006-01-02"), flag,
+		bcstring(strings.TrimSpace(trans.Payee())), bcstring(narration),
+	)
+	for _, tag := range trans.tags {
+		if rebctag.MatchString(tag) == false {
+			return fmt.Errorf("tag %q has no beancount equivalent", tag)
+		}
+		header += " #" + tag
+	}
+	lines := []string{header}
+
+	metadata := map[string]interface{}{}
+	for key, value := range trans.metadata {
+		metadata[key] = value
+	}
+	if trans.code != "" {
+		metadata["code"] = trans.code
+	}
+	metalines, err := bcmetadata(metadata, "  ")
+	if err != nil {
+		return err
+	}
+	lines = append(lines, metalines...)
+
+	elided := map[string]bool{}
+	for _, p := range trans.postings {
+		if p.elided && elided[p.account.name] {
+			continue // split of elided posting by commodity.
+		}
+		line, err := bc.posting(trans, p)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+		if p.elided {
+			elided[p.account.name] = true
+		}
+		if len(p.tags) > 0 {
+			return fmt.Errorf("posting tags have no beancount equivalent")
+		}
+		metalines, err := bcmetadata(p.metadata, "    ")
+		if err != nil {
+			return err
+		}
+		lines = append(lines, metalines...)
+
+		if p.balprice != nil {
+			if err := bc.addbalance(trans, p); err != nil {
+				return err
+			}
+		}
+	}
+	bc.entries = append(bc.entries, &bcentry{trans.date, lines})
+	return nil
+}
+
+// addbalance assert account's balance on the next day, since beancount
+// asserts balance at the beginning of the day. Only the last assertion
+// for the day is applicable.
+func (bc *bcexport) addbalance(trans *Transaction, p *Posting) error {
+	date := trans.date.AddDate(0, 0, 1)
+	accname, err := bcaccount(p.account.name)
+	if err != nil {
+		return err
+	}
+	amount, err := bc.amount(p.balprice, trans.date)
+	if err != nil {
+		return err
+	}
+	datestr := date.Format("2006-01-02")
+	line := fmt.Sprintf("%v balance %v  %v", datestr, accname, amount)
+	key := accname + "/" + datestr + "/" + p.balprice.name
+	if entry, ok := bc.balances[key]; ok {
+		entry.lines = []string{line}
+		return nil
+	}
+	bc.balances[key] = &bcentry{date, []string{line}}
+	bc.entries = append(bc.entries, bc.balances[key])
+	return nil
+}
+
+func (bc *bcexport) posting(trans *Transaction, p *Posting) (string, error) {
+	if p.virtual {
+		return "", fmt.Errorf("virtual posting has no beancount equivalent")
+	}
+	accname, err := bcaccount(p.account.name)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := bc.accounts[accname]; ok == false {
+		bc.accounts[accname] = trans.date
+	}
+
+	line := "  " + accname
+	if flag := p.metadata["state"]; flag != nil && flag != trans.getState() {
+		line = "  " + bcflag(flag.(string)) + " " + accname
+	}
+	if p.elided {
+		return line, nil
+	}
+
+	amount, err := bc.amount(p.commodity, trans.date)
+	if err != nil {
+		return "", err
+	}
+	line += "  " + amount
+
+	var lotprice, costprice string
+	if p.lotprice != nil {
+		if lotprice, err = bc.amount(p.lotprice, trans.date); err != nil {
+			return "", err
+		}
+	}
+	if p.costprice != nil {
+		if costprice, err = bc.amount(p.costprice, trans.date); err != nil {
+			return "", err
+		}
+	}
+	switch {
+	case lotprice != "" && p.lotdate.IsZero() == false:
+		line += fmt.Sprintf(" {%v, %v}", lotprice, p.lotdate.Format("2006-01-02"))
+	case lotprice != "":
+		line += fmt.Sprintf(" {%v}", lotprice)
+	case costprice != "" && p.commodity.currency == false:
+		// acquired lots are held at cost, sales are booked from held lots.
+		if p.commodity.amount.Sign() >= 0 {
+			line, costprice = line+fmt.Sprintf(" {%v}", costprice), ""
+		} else {
+			line += " {}"
+		}
+	}
+	if costprice != "" {
+		line += " @ " + costprice
+	}
+	if p.note != "" {
+		line += " ; " + strings.TrimSpace(strings.TrimLeft(p.note, ";"))
+	}
+	return line, nil
+}
+
+// amount format commodity amount as `NUMBER CURRENCY`.
+func (bc *bcexport) amount(comm *Commodity, date time.Time) (string, error) {
+	name, err := bc.commodity(comm, date)
+	if err != nil {
+		return "", err
+	}
+	precision := comm.precision
+	if precision < 0 {
+		precision = 2
+	}
+	return fmt.Sprintf("%v %v", comm.amount.Format(precision), name), nil
+}
+
+func (bc *bcexport) commodity(comm *Commodity, date time.Time) (string, error) {
+	name, ok := bcsymbols[comm.name]
+	if ok == false {
+		name = comm.name
+	}
+	if rebccommodity.MatchString(name) == false {
+		fmsg := "commodity %q has no beancount equivalent"
+		return "", fmt.Errorf(fmsg, comm.name)
+	}
+	if first, ok := bc.commodities[comm.name]; ok == false || date.Before(first) {
+		bc.commodities[comm.name] = date
+	}
+	return name, nil
+}
+
+func (bc *bcexport) commoditylines() []string {
+	names := []string{}
+	for name := range bc.commodities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		bcname, ok := bcsymbols[name]
+		if ok == false {
+			bcname = name
+		}
+		date := bc.commodities[name].Format("2006-01-02")
+		lines = append(lines, fmt.Sprintf("%v commodity %v", date, bcname))
+		if comm, ok := bc.db.commodities[name]; ok {
+			for _, note := range comm.notes {
+				lines = append(lines, fmt.Sprintf("  note: %v", bcstring(note)))
+			}
+		}
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+func (bc *bcexport) openlines() []string {
+	names := []string{}
+	for name := range bc.accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	notes := map[string][]string{}
+	for name, acc := range bc.db.accntdb {
+		if bcname, err := bcaccount(name); err == nil {
+			notes[bcname] = append(notes[bcname], acc.notes...)
+		}
+	}
+
+	lines := []string{}
+	for _, name := range names {
+		date := bc.accounts[name].Format("2006-01-02")
+		lines = append(lines, fmt.Sprintf("%v open %v", date, name))
+		for _, note := range notes[name] {
+			lines = append(lines, fmt.Sprintf("  note: %v", bcstring(note)))
+		}
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// bcaccount convert account name to beancount, white space within
+// sub-account names are replaced with `-`.
+func bcaccount(name string) (string, error) {
+	parts := strings.Split(name, ":")
+	if bcroots[parts[0]] == false {
+		fmsg := "account %q shall be under Assets, Liabilities, Equity, " +
+			"Income or Expenses"
+		return "", fmt.Errorf(fmsg, name)
+	}
+	for i, part := range parts[1:] {
+		part = strings.Join(strings.Fields(part), "-")
+		if part != "" {
+			part = strings.ToUpper(part[:1]) + part[1:]
+		}
+		if rebcaccount.MatchString(part) == false {
+			fmsg := "account %q has no beancount equivalent"
+			return "", fmt.Errorf(fmsg, name)
+		}
+		parts[i+1] = part
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+func bcflag(state string) string {
+	switch state {
+	case PostCleared:
+		return "*"
+	case PostPending:
+		return "!"
+	}
+	return ""
+}
+
+// bcmetadata format metadata, other than payee and state, as key-value
+// lines.
+func bcmetadata(
+	metadata map[string]interface{}, indent string) ([]string, error) {
+
+	keys := []string{}
+	for key := range metadata {
+		if key == "payee" || key == "state" {
+			continue
+		} else if rebcmetakey.MatchString(key) == false {
+			fmsg := "metadata %q has no beancount equivalent"
+			return nil, fmt.Errorf(fmsg, key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, key := range keys {
+		value := bcstring(fmt.Sprintf("%v", metadata[key]))
+		lines = append(lines, fmt.Sprintf("%v%v: %v", indent, key, value))
+	}
+	return lines, nil
+}
+
+func bcstring(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
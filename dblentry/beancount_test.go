@@ -0,0 +1,25 @@
+package dblentry
+
+import "testing"
+
+func TestBcaccount(t *testing.T) {
+	testcases := [][]interface{}{
+		// format: account, beancount account, error
+		{"Assets:Checking", "Assets:Checking", false},
+		{"Equity:Opening balance", "Equity:Opening-balance", false},
+		{"Income:capital  gains", "Income:Capital-gains", false},
+		{"Expenses:Food:2011", "Expenses:Food:2011", false},
+		{"Expenses:Food & Drinks", "", true},
+		{"MyCompany:Expenses", "", true},
+		{"Assets", "Assets", false},
+	}
+
+	for _, tcase := range testcases {
+		name, err := bcaccount(tcase[0].(string))
+		if failed := err != nil; failed != tcase[2].(bool) {
+			t.Errorf("%q unexpected error %v", tcase[0], err)
+		} else if name != tcase[1].(string) {
+			t.Errorf("expected %q, got %q", tcase[1], name)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package dblentry
 
 import "fmt"
-import "strconv"
 import "strings"
 
 import "github.com/prataprc/goparsec"
@@ -13,12 +12,14 @@ type Commodity struct {
 	notes []string
 	// amount is more like quantity,
 	// or in pricing context it says the per unit price.
-	amount    float64
+	amount    api.Decimal
 	currency  bool
 	noname    bool
 	precision int
 	wspace    string
 	mark1k    bool
+	deccomma  bool // decimal mark is comma, like `1.234,56`.
+	declared  bool // format declared by commodity directive.
 	fixprice  bool
 	total     bool
 	nomarket  bool
@@ -48,7 +49,9 @@ func (comm *Commodity) isTotal() bool {
 }
 
 func (comm *Commodity) addNote(note string) {
-	comm.notes = append(comm.notes, note)
+	if note != "" {
+		comm.notes = append(comm.notes, note)
+	}
 }
 
 //---- api.Commoditiser methods.
@@ -61,7 +64,7 @@ func (comm *Commodity) Notes() []string {
 	return comm.notes
 }
 
-func (comm *Commodity) Amount() float64 {
+func (comm *Commodity) Amount() api.Decimal {
 	return comm.amount
 }
 
@@ -80,27 +83,27 @@ func (comm *Commodity) BalanceEqual(other api.Commoditiser) (bool, error) {
 	} else if comm.currency != other.Currency() {
 		return false, fmt.Errorf("mismatch in currency commodity")
 	}
-	return comm.amount == other.Amount(), nil
+	return comm.amount.Cmp(other.Amount()) == 0, nil
 }
 
 func (comm *Commodity) IsCredit() bool {
-	if comm.amount <= 0 {
+	if comm.amount.Sign() <= 0 {
 		return true
 	}
 	return false
 }
 
 func (comm *Commodity) IsDebit() bool {
-	if comm.amount >= 0 {
+	if comm.amount.Sign() >= 0 {
 		return true
 	}
 	return false
 }
-func (comm *Commodity) MakeSimilar(amount float64) api.Commoditiser {
+func (comm *Commodity) MakeSimilar(amount api.Decimal) api.Commoditiser {
 	return comm.makeSimilar(amount)
 }
 
-func (comm *Commodity) makeSimilar(amount float64) *Commodity {
+func (comm *Commodity) makeSimilar(amount api.Decimal) *Commodity {
 	newcomm := &Commodity{
 		name:      comm.name,
 		notes:     comm.notes,
@@ -110,21 +113,26 @@ func (comm *Commodity) makeSimilar(amount float64) *Commodity {
 		precision: comm.precision,
 		wspace:    comm.wspace,
 		mark1k:    comm.mark1k,
+		deccomma:  comm.deccomma,
+		declared:  comm.declared,
 		fixprice:  comm.fixprice,
 		total:     comm.total,
 		nomarket:  comm.nomarket,
 	}
 	return newcomm
 }
+
+// Precision return the number of digits after decimal point, amounts of
+// this commodity are rendered with.
+func (comm *Commodity) Precision() int {
+	return comm.precision
+}
+
 func (comm *Commodity) String() string {
 	if comm == nil {
 		return ""
 	}
-	amountstr := fmt.Sprintf("%v", comm.amount)
-	if comm.precision >= 0 {
-		fmsg := fmt.Sprintf("%%.%vf", comm.precision)
-		amountstr = fmt.Sprintf(fmsg, comm.amount)
-	}
+	amountstr := comm.formatamount()
 	name := comm.name
 	if comm.noname {
 		name = ""
@@ -140,7 +148,7 @@ func (comm *Commodity) Directive() string {
 	for _, note := range comm.notes {
 		lines = append(lines, fmt.Sprintf("    note  %v", note))
 	}
-	if comm.amount > 0 {
+	if comm.amount.Sign() > 0 {
 		lines = append(lines, fmt.Sprintf("    format %v", comm.String()))
 	}
 	if comm.nomarket {
@@ -149,26 +157,123 @@ func (comm *Commodity) Directive() string {
 	return strings.Join(lines, "\n")
 }
 
+// setformat to render commodity like format, declared by `commodity`
+// directive. Quotes keep their precision if it is more than the format's.
+func (comm *Commodity) setformat(format *Commodity, quote bool) {
+	comm.currency, comm.noname = format.currency, format.noname
+	comm.wspace = format.wspace
+	comm.mark1k, comm.deccomma = format.mark1k, format.deccomma
+	comm.declared = true
+	if quote == false || format.precision > comm.precision {
+		comm.precision = format.precision
+	}
+}
+
+// formatamount in commodity's precision. Thousands mark and decimal mark
+// are applied only for declared format.
+func (comm *Commodity) formatamount() string {
+	amount := comm.amount.String()
+	if comm.precision >= 0 {
+		amount = comm.amount.Format(comm.precision)
+	}
+	if comm.declared == false {
+		return amount
+	} else if comm.mark1k == false && comm.deccomma == false {
+		return amount
+	}
+	sign, fraction := "", ""
+	if strings.HasPrefix(amount, "-") {
+		sign, amount = "-", amount[1:]
+	}
+	if off := strings.Index(amount, "."); off >= 0 {
+		amount, fraction = amount[:off], amount[off+1:]
+	}
+	mark1k, decmark := ",", "."
+	if comm.deccomma {
+		mark1k, decmark = ".", ","
+	}
+	if comm.mark1k {
+		groups := []string{}
+		for ; len(amount) > 3; amount = amount[:len(amount)-3] {
+			groups = append([]string{amount[len(amount)-3:]}, groups...)
+		}
+		amount = strings.Join(append([]string{amount}, groups...), mark1k)
+	}
+	if fraction != "" {
+		amount += decmark + fraction
+	}
+	return sign + amount
+}
+
+// parseamount parse amount like `1,234.56`, or like `1.234,56` when
+// deccomma is true. If amount has both the marks, the last one is the
+// decimal mark. Otherwise comma is a thousands mark, unless deccomma.
+func parseamount(
+	s string, deccomma bool) (api.Decimal, int, bool, bool, error) {
+
+	dot, comma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	if dot >= 0 && comma >= 0 {
+		deccomma = comma > dot
+	} else if deccomma && dot >= 0 {
+		deccomma = strings.Count(s, ".") > 1 || len(s)-dot == 4
+	}
+	mark1k, decmark := ",", "."
+	if deccomma {
+		mark1k, decmark = ".", ","
+	}
+	hasmark1k := strings.Contains(s, mark1k)
+	s = strings.Replace(s, mark1k, "", -1)
+	precision := 0
+	if off := strings.Index(s, decmark); off >= 0 {
+		precision = len(s) - off - 1
+		s = s[:off] + "." + s[off+1:]
+	}
+	amount, err := api.ParseDecimal(s)
+	return amount, precision, hasmark1k, deccomma, err
+}
+
 //---- ledger parser
 
 // Yledger return a parser-combinator that can parse a commodity amount/name.
+// Amount can be a value expression within paranthesis, like `$(rent / 2)`,
+// evaluated while parsing.
 func (comm *Commodity) Yledger(db *Datastore) parsec.Parser {
-	parseprecision := func(amount string) int {
-		parts := strings.Split(amount, ".")
-		if len(parts) == 2 {
-			return len(parts[1])
-		}
-		return 0
-	}
+	yamountexpr := parsec.And(
+		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
+			expr, ok := nodes[1].(*Expression)
+			if ok == false {
+				return nodes[1]
+			}
+			ctx := &exprctx{db: db, date: db.currentDate(), parsing: true}
+			value, err := expr.eval(ctx)
+			if err != nil {
+				return err
+			}
+			amount, ok := value.(api.Decimal)
+			if ok == false {
+				return fmt.Errorf("amount expression %v is not a number", expr)
+			}
+			return amount
+		},
+		parsec.Atom("(", "OPENPARAN"), yexpression(db),
+		parsec.Atom(")", "CLOSEPARAN"),
+	)
 
 	y := parsec.And(
 		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
+			amount, expression := "", false
 			for _, node := range nodes {
+				switch v := node.(type) {
+				case error:
+					return v
+				case api.Decimal: // amount expression
+					comm.amount, expression = v, true
+					continue
+				}
 				t, ok := node.(*parsec.Terminal)
 				if ok == false {
 					continue
 				}
-				var err error
 				switch t.Name {
 				case "CURRENCY":
 					comm.name, comm.currency = string(t.Value), true
@@ -177,23 +282,43 @@ func (comm *Commodity) Yledger(db *Datastore) parsec.Parser {
 					comm.wspace = t.Value
 
 				case "AMOUNT":
-					comm.mark1k = strings.Contains(string(t.Value), ",")
-					amount := strings.Replace(string(t.Value), ",", "", -1)
-					comm.precision = parseprecision(amount)
-					comm.amount, err = strconv.ParseFloat(amount, 64)
-					if err != nil {
-						return err
-					}
+					amount = t.Value
 
 				case "COMMODITY":
 					comm.name, comm.currency = string(t.Value), false
 				}
 			}
+			if amount == "" && expression == false {
+				return comm
+			}
+			// decimal mark and precision of expressions are known only
+			// after parsing commodity name.
+			name, deccomma := comm.name, false
+			if name == "" {
+				name = db.getDefaultcomm()
+			}
+			registered, ok := db.commodities[name]
+			if ok {
+				deccomma = registered.deccomma
+			}
+			if expression && ok {
+				comm.precision = registered.precision
+				return comm
+			} else if expression {
+				comm.precision = comm.amount.Precision()
+				return comm
+			}
+			var err error
+			comm.amount, comm.precision, comm.mark1k, comm.deccomma, err =
+				parseamount(amount, deccomma)
+			if err != nil {
+				return err
+			}
 			return comm
 		},
 		parsec.Maybe(maybenode, comm.Ycurrencyname(db)),
 		parsec.Maybe(maybenode, ytokWhitespace),
-		ytokAmount,
+		parsec.OrdChoice(Vector2scalar, ytokAmount, yamountexpr),
 		parsec.Maybe(maybenode, comm.Ycommodityname(db)),
 	)
 	return y
@@ -328,13 +453,13 @@ func (comm *Commodity) Clone(ndb api.Datastorer) api.Commoditiser {
 }
 
 func (comm *Commodity) doInverse() {
-	comm.amount = -comm.amount
+	comm.amount = comm.amount.Neg()
 }
 
 func (comm *Commodity) doAdd(other *Commodity) error {
 	n1, c1, n2, c2 := comm.name, comm.currency, other.name, other.currency
 	if comm.name == other.name && comm.currency == other.currency {
-		comm.amount += other.amount
+		comm.amount = comm.amount.Add(other.amount)
 		return nil
 	}
 	return fmt.Errorf("can't <%v:%v> + <%v:%v>", n1, c1, n2, c2)
@@ -0,0 +1,57 @@
+package dblentry
+
+import "testing"
+
+import "github.com/tn47/goledger/api"
+
+func TestCommodityFormat(t *testing.T) {
+	testcases := [][]interface{}{
+		// amount, deccomma, value, precision, mark1k, deccomma
+		{"1234.5", false, "1234.5", 1, false, false},
+		{"1,234.56", false, "1234.56", 2, true, false},
+		{"1,234", false, "1234", 0, true, false},
+		{"1.234,56", false, "1234.56", 2, true, true},
+		{"1,5", false, "15", 0, true, false},
+		{"12,3456", false, "123456", 0, true, false},
+		{"12,5", true, "12.5", 1, false, true},
+		{"1.234", true, "1234", 0, true, true},
+		{"1234,56", true, "1234.56", 2, false, true},
+		{"1.5", true, "1.5", 1, false, false},
+	}
+	for _, tcase := range testcases {
+		s, deccomma := tcase[0].(string), tcase[1].(bool)
+		amount, precision, mark1k, deccomma, err := parseamount(s, deccomma)
+		if err != nil {
+			t.Fatal(err)
+		} else if x := amount.String(); x != tcase[2].(string) {
+			t.Errorf("%q expected %v, got %v", s, tcase[2], x)
+		} else if precision != tcase[3].(int) {
+			t.Errorf("%q expected %v, got %v", s, tcase[3], precision)
+		} else if mark1k != tcase[4].(bool) {
+			t.Errorf("%q expected %v, got %v", s, tcase[4], mark1k)
+		} else if deccomma != tcase[5].(bool) {
+			t.Errorf("%q expected %v, got %v", s, tcase[5], deccomma)
+		}
+	}
+
+	format := &Commodity{
+		name: "EUR", precision: 2, mark1k: true, deccomma: true,
+	}
+	comm := NewCommodity("EUR")
+	comm.amount, _ = api.ParseDecimal("-1234567.891")
+	comm.precision = 3
+	if x := comm.String(); x != "-1234567.891 EUR" {
+		t.Errorf("expected %q, got %q", "-1234567.891 EUR", x)
+	}
+	comm.setformat(format, false)
+	if x := comm.String(); x != "-1.234.567,89 EUR" {
+		t.Errorf("expected %q, got %q", "-1.234.567,89 EUR", x)
+	}
+	// quotes keep their precision.
+	format.currency, format.wspace, format.deccomma = true, " ", false
+	comm.precision = 3
+	comm.setformat(format, true)
+	if x := comm.String(); x != "EUR -1,234,567.891" {
+		t.Errorf("expected %q, got %q", "EUR -1,234,567.891", x)
+	}
+}
@@ -43,6 +43,10 @@ type Datastore struct {
 	de          *DoubleEntry
 	transdb     *DB
 	pricedb     *DB
+	priceidx    map[string][]*Price // commodity pair -> prices by date.
+	checkdb     *DB                 // assert and check directives.
+	lotledger   *Lotledger
+	verifying   bool // balances are verified, not reported.
 
 	// configuration
 	periodtill *time.Time
@@ -58,9 +62,12 @@ func NewDatastore(name string, reporter api.Reporter) *Datastore {
 		pass:        DBSTART,
 		transdb:     NewDB(fmt.Sprintf("%v-transactions", name)),
 		pricedb:     NewDB(fmt.Sprintf("%v-pricedb", name)),
+		priceidx:    map[string][]*Price{},
+		checkdb:     NewDB(fmt.Sprintf("%v-checkdb", name)),
 		accntdb:     map[string]*Account{},
 		commodities: map[string]*Commodity{},
 		de:          NewDoubleEntry("master"),
+		lotledger:   NewLotledger(),
 	}
 	db.initfirstpass()
 	db.defaultprices()
@@ -83,6 +90,12 @@ func (db *Datastore) getCommodity(name string, defcomm *Commodity) *Commodity {
 	if defaultcomm == "" && name != "" {
 		db.setDefaultcomm(name)
 	}
+	return db.addCommodity(name, defcomm)
+}
+
+// addCommodity register commodity by name, if not already registered.
+// Unlike getCommodity, default commodity is left as is.
+func (db *Datastore) addCommodity(name string, defcomm *Commodity) *Commodity {
 	if comm, ok := db.commodities[name]; ok {
 		return comm
 	}
@@ -108,10 +121,161 @@ func (db *Datastore) Hasjournal(data []byte) bool {
 	return ok
 }
 
+// Journals return journal files processed by firstpass, including the
+// included journals.
+func (db *Datastore) Journals() []string {
+	journals := []string{}
+	for _, journal := range db.journals {
+		if api.HasString(journals, journal) == false {
+			journals = append(journals, journal)
+		}
+	}
+	sort.Strings(journals)
+	return journals
+}
+
 func (db *Datastore) CurrentJournal() string {
 	return db.currjournal
 }
 
+// CurrentYear return the year, set by `year` directive or by the last
+// parsed transaction, for dates that don't specify one.
+func (db *Datastore) CurrentYear() int {
+	return db.getYear()
+}
+
+// Sales return list of all sales, in the order of consumption, that
+// consumed the acquired lots. Available after secondpass.
+func (db *Datastore) Sales() []*Sale {
+	return db.lotledger.sales
+}
+
+// Periodics return list of periodic transactions, in journal order.
+func (db *Datastore) Periodics() []*Periodic {
+	return db.periodics
+}
+
+// Forecast synthesise transactions from periodic transactions, occurring
+// after the last transaction and before `till`. Periodic transactions
+// with single posting are balanced against the bucket account. Shall be
+// called after all journals are processed.
+func (db *Datastore) Forecast(till time.Time) error {
+	from := db.lastTransdate()
+	if from.IsZero() {
+		from = time.Now()
+	}
+	year, month, day := from.Date()
+	from = time.Date(year, month, day+1, 0, 0, 0, 0, from.Location())
+
+	for _, pt := range db.periodics {
+		dates := pt.Occurrences(from, till)
+		single := len(pt.trans.postings) == 1
+		if len(dates) > 0 && single && db.getBalancingaccount() == "" {
+			fmsg := "periodic %q has a single posting and no bucket account"
+			return fmt.Errorf(fmsg, pt.periodexpr)
+		}
+		for _, date := range dates {
+			if err := db.Firstpass(pt.forecast(date)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyformats declared by `commodity` directives to every amount, so
+// that the declared format decide how a commodity is rendered, no matter
+// where the directive is, in the journals.
+func (db *Datastore) applyformats() {
+	apply := func(comm *Commodity, quote bool) {
+		if comm == nil {
+			return
+		} else if format := db.getFormat(comm.name); format != nil {
+			comm.setformat(format, quote)
+		}
+	}
+	applypostings := func(postings []*Posting) {
+		for _, p := range postings {
+			apply(p.commodity, false)
+			apply(p.lotprice, false)
+			apply(p.costprice, false)
+			apply(p.balprice, false)
+		}
+	}
+
+	for _, comm := range db.commodities {
+		apply(comm, false)
+	}
+	entries := []api.TimeEntry{}
+	for _, entry := range db.transdb.Range(nil, nil, "both", entries) {
+		applypostings(entry.Value().(*Transaction).postings)
+	}
+	entries = []api.TimeEntry{}
+	for _, entry := range db.pricedb.Range(nil, nil, "both", entries) {
+		price := entry.Value().(*Price)
+		apply(price.this, false)
+		apply(price.other, true)
+	}
+	for _, pt := range db.periodics {
+		applypostings(pt.trans.postings)
+	}
+	for _, auto := range db.automated {
+		for _, ap := range auto.postings {
+			apply(ap.commodity, false)
+		}
+	}
+}
+
+// Matchpayee return the payee declared by `payee` directive, whose uuid
+// matches `uuid` or whose alias matches `payee`. Return false if no such
+// payee is declared.
+func (db *Datastore) Matchpayee(payee, uuid string) (string, bool) {
+	if uuid != "" {
+		if name, ok := db.matchuuid(uuid); ok {
+			return name, true
+		}
+	}
+	return db.matchpayee(payee)
+}
+
+// Matchaccount return the account whose `payee` sub-directive matches
+// `payee`. Return false if no such account is declared.
+func (db *Datastore) Matchaccount(payee string) (string, bool) {
+	accname, ok := db.matchaccpayee(payee)
+	if ok == false {
+		return "", false
+	}
+	return db.Lookupaccount(accname), true
+}
+
+// Lookupaccount resolve account name using `capture` and `alias`
+// directives, similar to how posting's account name is resolved.
+func (db *Datastore) Lookupaccount(accname string) string {
+	if accname1, ok := db.matchcapture(accname); ok {
+		return accname1
+	}
+	return db.lookupAlias(accname)
+}
+
+// ParseCommodity parse text, like `$1,000.00` or `10 AAPL`, into commodity.
+func (db *Datastore) ParseCommodity(text string) (*Commodity, error) {
+	scanner := parsec.NewScanner([]byte(text))
+	node, scanner := NewCommodity("").Yledger(db)(scanner)
+	_, scanner = parsec.Token(`[ \t]*`, "WS")(scanner)
+	if comm, ok := node.(*Commodity); ok && scanner.Endof() {
+		return comm, nil
+	} else if err, ok := node.(error); ok {
+		return nil, err
+	}
+	return nil, fmt.Errorf("invalid commodity %q", text)
+}
+
+// DefaultCommodity return the name of the default commodity, used for
+// amounts that don't call out a commodity.
+func (db *Datastore) DefaultCommodity() string {
+	return db.getDefaultcomm()
+}
+
 func (db *Datastore) GetCommodity(name string) api.Commoditiser {
 	return db.getCommodity(name, nil)
 }
@@ -123,6 +287,9 @@ func (db *Datastore) Applytill(till time.Time) {
 
 // Firstpassok to track parsephase
 func (db *Datastore) Firstpassok() {
+	if api.Options.Firstuse == false {
+		db.applyformats()
+	}
 	db.pass = DBFIRSTPASS
 }
 
@@ -201,6 +368,20 @@ func (db *Datastore) Commoditynames() []string {
 	return cnames
 }
 
+func (db *Datastore) GetPrice(
+	this, other string, when time.Time) api.Commoditiser {
+
+	prices := db.priceidx[pricekey(this, other)]
+	n := sort.Search(len(prices), func(i int) bool {
+		return prices[i].when.After(when)
+	})
+	if n == 0 {
+		return nil
+	}
+	comm, _ := prices[n-1].quote(this, other)
+	return comm
+}
+
 func (db *Datastore) Balance(obj interface{}) (balance api.Commoditiser) {
 	db.assertfirstpass()
 	return db.de.Balance(obj)
@@ -261,11 +442,20 @@ func (db *Datastore) Firstpass(obj interface{}) (err error) {
 		db.transdb.Insert(trans.date, trans)
 
 	} else if price, ok := obj.(*Price); ok {
-		err = db.pricedb.Insert(price.when, price)
+		if err := price.Firstpass(db); err != nil {
+			return err
+		}
+		err = db.addPrice(price)
 
 	} else if directive, ok := obj.(*Directive); ok {
 		err = directive.Firstpass(db)
 
+	} else if auto, ok := obj.(*Automated); ok {
+		err = auto.Firstpass(db)
+
+	} else if pt, ok := obj.(*Periodic); ok {
+		err = pt.Firstpass(db)
+
 	} else if comment, ok := obj.(*Comment); ok {
 		err = comment.Firstpass(db)
 		db.addComment(comment.line)
@@ -274,16 +464,45 @@ func (db *Datastore) Firstpass(obj interface{}) (err error) {
 }
 
 func (db *Datastore) Secondpass() error {
+	if api.FilterPostings() && db.verifying == false {
+		// reported balances are partial, verify assertions on a clone
+		// with all postings.
+		vdb := db.Clone(db.reporter).(*Datastore)
+		vdb.verifying = true
+		if err := vdb.Secondpass(); err != nil {
+			return err
+		}
+	}
+
 	entries := []api.TimeEntry{}
+	checks := db.checkdb.Range(nil, nil, "both", []api.TimeEntry{})
+	if db.checking() == false {
+		checks = nil
+	}
 
 	for _, entry := range db.transdb.Range(nil, nil, "both", entries) {
 		trans := entry.Value().(*Transaction)
 		if db.periodtill == nil || trans.Date().Before(*db.periodtill) {
+			// directives placed after all transactions on or before a date.
+			for len(checks) > 0 && checks[0].Key().Before(trans.Date()) {
+				if err := checks[0].Value().(*Directive).Secondpass(db); err != nil {
+					return err
+				}
+				checks = checks[1:]
+			}
 			if err := trans.Secondpass(db); err != nil {
 				return err
 			}
 		}
 	}
+	for _, check := range checks {
+		if db.periodtill != nil && !check.Key().Before(*db.periodtill) {
+			break
+		}
+		if err := check.Value().(*Directive).Secondpass(db); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -302,6 +521,7 @@ func (db *Datastore) Clone(nreporter api.Reporter) api.Datastorer {
 	}
 
 	ndb.de = db.de.Clone()
+	ndb.lotledger = NewLotledger()
 
 	ndb.transdb = NewDB(fmt.Sprintf("%v-transactions", ndb.name))
 	entries := []api.TimeEntry{}
@@ -311,14 +531,52 @@ func (db *Datastore) Clone(nreporter api.Reporter) api.Datastorer {
 	}
 
 	ndb.pricedb = NewDB(fmt.Sprintf("%v-pricedb", ndb.name))
+	ndb.priceidx = map[string][]*Price{}
 	entries = []api.TimeEntry{}
 	for _, entry := range db.pricedb.Range(nil, nil, "both", entries) {
-		k, nprice := entry.Key(), entry.Value().(*Price).Clone(&ndb)
-		ndb.pricedb.Insert(k, nprice)
+		ndb.addPrice(entry.Value().(*Price).Clone(&ndb))
 	}
 	return &ndb
 }
 
+// addPrice to pricedb and index it by commodity pair, prices quoted on
+// the same date are kept in the order they are added.
+func (db *Datastore) addPrice(price *Price) error {
+	key := pricekey(price.this.name, price.other.name)
+	prices := db.priceidx[key]
+	n := sort.Search(len(prices), func(i int) bool {
+		return prices[i].when.After(price.when)
+	})
+	prices = append(prices, nil)
+	copy(prices[n+1:], prices[n:])
+	prices[n] = price
+	db.priceidx[key] = prices
+	return db.pricedb.Insert(price.when, price)
+}
+
+// pricekey for a pair of commodities, irrespective of their order.
+func pricekey(this, other string) string {
+	if this > other {
+		this, other = other, this
+	}
+	return this + "/" + other
+}
+
+// checking return true if balance assertions shall be evaluated in this
+// pass, refer Secondpass().
+func (db *Datastore) checking() bool {
+	return db.verifying || api.FilterPostings() == false
+}
+
+// reporting return true if posting shall be accounted and handed over
+// to the reporter in this pass.
+func (db *Datastore) reporting(p *Posting) bool {
+	if db.verifying {
+		return false
+	}
+	return api.FilterState(p.State()) && api.FilterReal(p.virtual)
+}
+
 func (db *Datastore) addBalance(commodity *Commodity) error {
 	return db.de.AddBalance(commodity)
 }
@@ -344,21 +602,33 @@ func (db *Datastore) declare(value interface{}) error {
 			account.addAlias(d.accalias)
 			account.addPayee(d.accpayee)
 			account.addComments(d.comments...)
+			if err := account.addExpressions(db, d); err != nil {
+				return err
+			}
 			if d.ndefault {
 				db.setBalancingaccount(account.name)
 			}
 			db.dclrdacc = append(db.dclrdacc, d.accname)
 
 		case "commodity":
-			scanner := parsec.NewScanner([]byte(d.commdfmt))
-			node, _ := NewCommodity("").Yledger(db)(scanner)
-			commodity := node.(*Commodity)
-			if commodity.name != "" && commodity.name != d.commdname {
-				x, y := commodity.name, d.commdname
-				return fmt.Errorf("name mismatching %q vs %q", x, y)
-			} else if commodity.name == "" {
-				commodity.name = d.commdname
-				commodity.noname = true
+			commodity, ok := db.commodities[d.commdname]
+			if d.commdfmt != "" {
+				scanner := parsec.NewScanner([]byte(d.commdfmt))
+				node, _ := NewCommodity("").Yledger(db)(scanner)
+				if commodity, ok = node.(*Commodity); ok == false {
+					return fmt.Errorf("invalid format %q", d.commdfmt)
+				}
+				if commodity.name != "" && commodity.name != d.commdname {
+					x, y := commodity.name, d.commdname
+					return fmt.Errorf("name mismatching %q vs %q", x, y)
+				} else if commodity.name == "" {
+					commodity.name = d.commdname
+					commodity.noname = true
+				}
+				db.setFormat(commodity.name, commodity)
+
+			} else if ok == false {
+				commodity = NewCommodity(d.commdname)
 			}
 
 			commodity.addNote(d.note)
@@ -397,66 +667,63 @@ func (db *Datastore) declare(value interface{}) error {
 
 func (db *Datastore) FmtBalances(
 	_ api.Datastorer, trans api.Transactor, p api.Poster,
-	acc api.Accounter) [][]string {
+	acc api.Accounter) [][]interface{} {
 
-	var rows [][]string
+	var rows [][]interface{}
 
 	if len(db.Balances()) == 0 {
-		return append(rows, []string{"", "", "-"})
+		return append(rows, []interface{}{"", "", "-"})
 	}
 
 	for _, balance := range db.Balances() {
-		rows = append(rows, []string{"", "", balance.String()})
+		rows = append(rows, []interface{}{"", "", fmtamount(balance)})
 	}
 	if len(rows) > 0 { // last row to include date.
 		lastrow := rows[len(rows)-1]
-		date := trans.Date().Format("2006/Jan/02")
-		lastrow[0] = date
+		lastrow[0] = trans.Date()
 	}
 	return rows
 }
 
 func (db *Datastore) FmtDCBalances(
 	_ api.Datastorer, trans api.Transactor, p api.Poster,
-	acc api.Accounter) [][]string {
+	acc api.Accounter) [][]interface{} {
 
-	var rows [][]string
+	var rows [][]interface{}
 
 	if len(db.Balances()) == 0 {
-		return append(rows, []string{"", "", "-", "-", "-"})
+		return append(rows, []interface{}{"", "", "-", "-", "-"})
 	}
 
 	for _, bal := range db.Balances() {
 		name := bal.Name()
-		dr, cr := db.Debit(name), db.Credit(name)
-		cols := []string{"", "", dr.String(), cr.String(), bal.String()}
-		rows = append(rows, cols)
+		dr, cr := fmtamount(db.Debit(name)), fmtamount(db.Credit(name))
+		rows = append(rows, []interface{}{"", "", dr, cr, fmtamount(bal)})
 	}
 	if len(rows) > 0 { // last row to include date.
 		lastrow := rows[len(rows)-1]
-		date := trans.Date().Format("2006/Jan/02")
-		lastrow[0] = date
+		lastrow[0] = trans.Date()
 	}
 	return rows
 }
 
 func (db *Datastore) FmtEquity(
 	_ api.Datastorer, trans api.Transactor, _ api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
 	panic("not supported")
 }
 
 func (db *Datastore) FmtPassbook(
 	_ api.Datastorer, trans api.Transactor, _ api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
 	panic("not supported")
 }
 
 func (db *Datastore) FmtRegister(
 	_ api.Datastorer, trans api.Transactor, p api.Poster,
-	acc api.Accounter) [][]string {
+	acc api.Accounter) [][]interface{} {
 
 	panic("not supported")
 }
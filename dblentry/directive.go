@@ -1,6 +1,7 @@
 package dblentry
 
 import "fmt"
+import "time"
 import "strings"
 import "strconv"
 
@@ -11,29 +12,37 @@ import "github.com/tn47/goledger/api"
 // Directive can handle all directives in ledger journal.
 type Directive struct {
 	dtype       string
-	year        int      // year
-	note        string   // account, commodity
-	comments    []string // account, commodity
-	ndefault    bool     // account, commodity
-	accname     string   // account, alias, apply
-	accalias    string   // account
-	accpayee    string   // account
-	acccheck    string   // account
-	accassert   string   // account
-	acceval     string   // account
-	acctypes    []string // account
-	aliasname   string   // alias
-	expression  string   // assert, check
-	capture     string   // capture pattern
-	commdname   string   // commodity
-	commdfmt    string   // commodity
-	commdnmrkt  bool     // commodity
-	commdcurrn  bool     // commodity
-	includefile string   // include
-	dpayee      string   // payee
-	dpayeealias []string // payee
-	dpayeeuuid  []string // payee
-	endargs     []string // end
+	year        int        // year
+	note        string     // account, commodity
+	comments    []string   // account, commodity
+	ndefault    bool       // account, commodity
+	accname     string     // account, alias, apply
+	accalias    string     // account
+	accpayee    string     // account
+	acccheck    string     // account
+	accassert   string     // account
+	acceval     string     // account
+	acctypes    []string   // account
+	aliasname   string     // alias
+	expression  string     // assert, check
+	capture     string     // capture pattern
+	commdname   string     // commodity
+	commdfmt    string     // commodity
+	commdnmrkt  bool       // commodity
+	commdcurrn  bool       // commodity
+	includefile string     // include
+	dpayee      string     // payee
+	dpayeealias []string   // payee
+	dpayeeuuid  []string   // payee
+	endargs     []string   // end
+	defname     string     // define
+	fixprice    *Commodity // fixed
+
+	// assert, check
+	journalfile string
+	lineno      int
+	date        time.Time
+	expr        *Expression
 }
 
 // NewDirective create a new Directive instance, one instance to be created
@@ -48,6 +57,11 @@ func (d *Directive) Type() string {
 	return d.dtype
 }
 
+// SetPosition of this directive in journal file.
+func (d *Directive) SetPosition(journalfile string, lineno int) {
+	d.journalfile, d.lineno = journalfile, lineno
+}
+
 func (d *Directive) Includefile() string {
 	if d.dtype == "include" {
 		return d.includefile
@@ -72,6 +86,7 @@ func (d *Directive) Yledger(db *Datastore) parsec.Parser {
 		d.ycomment(db),
 		d.ycommodity(db),
 		d.ydefine(db),
+		d.yendfixed(db),
 		d.yfixed(db),
 		d.yinclude(db),
 		d.ypayee(db),
@@ -187,7 +202,7 @@ func (d *Directive) Yledgerblock(db *Datastore, block []string) (int, error) {
 		return len(block), nil
 
 	case "apply", "alias", "assert", "bucket", "capture", "check", "comment",
-		"define", "fixed", "include", "test", "end", "year":
+		"define", "fixed", "endfixed", "include", "test", "end", "year":
 		return len(block), nil
 	}
 	panic(fmt.Errorf("unreachable code"))
@@ -305,19 +320,42 @@ func (d *Directive) ydefine(db *Datastore) parsec.Parser {
 	return parsec.And(
 		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
 			d.dtype = "define"
+			d.defname = nodes[1].(*parsec.Terminal).Value
+			d.expression = nodes[3].(*parsec.Terminal).Value
 			return d
 		},
-		ytokDirtDefine,
+		ytokDirtDefine, ytokDefname, ytokEqual, ytokExpr,
 	)
 }
 
 func (d *Directive) yfixed(db *Datastore) parsec.Parser {
+	commodity := NewCommodity("") // local scope.
+	price := NewCommodity("")     // local scope.
 	return parsec.And(
 		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
 			d.dtype = "fixed"
+			d.commdname = nodes[1].(*parsec.Terminal).Value
+			if err, ok := nodes[2].(error); ok {
+				return err
+			}
+			d.fixprice = nodes[2].(*Commodity)
 			return d
 		},
-		ytokDirtFixed,
+		ytokDirtFixed, commodity.Yname(db), price.Yledger(db),
+	)
+}
+
+func (d *Directive) yendfixed(db *Datastore) parsec.Parser {
+	commodity := NewCommodity("") // local scope.
+	return parsec.And(
+		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
+			d.dtype = "endfixed"
+			if t, ok := nodes[1].(*parsec.Terminal); ok {
+				d.commdname = t.Value
+			}
+			return d
+		},
+		ytokDirtEndfixed, parsec.Maybe(maybenode, commodity.Yname(db)),
 	)
 }
 
@@ -429,8 +467,8 @@ func (d *Directive) Firstpass(db *Datastore) error {
 		db.addAlias(d.aliasname, d.accname)
 		return nil
 
-	case "assert":
-		return fmt.Errorf("assert directive not-implemented")
+	case "assert", "check":
+		return d.firstpassassert(db)
 
 	case "bucket":
 		db.setBalancingaccount(d.accname)
@@ -440,20 +478,27 @@ func (d *Directive) Firstpass(db *Datastore) error {
 		db.addCapture(d.capture, d.accname)
 		return nil
 
-	case "check":
-		return fmt.Errorf("check directive not-implemented")
-
-	case "comment":
-		return fmt.Errorf("comment directive not-implemented")
+	case "comment", "test":
+		return nil // blocks are skipped while iterating the journal.
 
 	case "commodity":
 		return db.declare(d)
 
 	case "define":
-		return fmt.Errorf("define directive not-implemented")
+		return d.firstpassdefine(db)
 
 	case "fixed":
-		return fmt.Errorf("fixed directive not-implemented")
+		if d.fixprice.currency == false {
+			return fmt.Errorf("fixed price must be currency")
+		}
+		price := db.getCommodity(d.fixprice.name, d.fixprice)
+		price = price.makeSimilar(d.fixprice.amount)
+		price.setFixprice()
+		db.setFixedprice(d.commdname, price)
+		return nil
+
+	case "endfixed":
+		return db.clearFixedprice(d.commdname)
 
 	case "include":
 		return nil
@@ -461,9 +506,6 @@ func (d *Directive) Firstpass(db *Datastore) error {
 	case "payee":
 		return db.declare(d)
 
-	case "test":
-		return fmt.Errorf("test directive not-implemented")
-
 	case "end":
 		return db.clearRootaccount()
 
@@ -474,6 +516,38 @@ func (d *Directive) Firstpass(db *Datastore) error {
 }
 
 func (d *Directive) Secondpass(db *Datastore) error {
+	switch d.dtype {
+	case "assert", "check":
+		ctx := &exprctx{db: db, date: d.date}
+		return evalassertion(d.dtype, d.expr, ctx, d.journalfile, d.lineno)
+	}
+	return nil
+}
+
+// firstpassassert parse the expression, and queue the directive to be
+// evaluated after all transactions dated on or before the last transaction
+// parsed so far.
+func (d *Directive) firstpassassert(db *Datastore) (err error) {
+	if d.expr, err = NewExpression(db, d.expression); err != nil {
+		return err
+	}
+	d.date = db.lastTransdate()
+	return db.checkdb.Insert(d.date, d)
+}
+
+// firstpassdefine evaluate the expression, with names defined so far, and
+// bind its value to the name. Account balances are not known yet.
+func (d *Directive) firstpassdefine(db *Datastore) error {
+	expr, err := NewExpression(db, d.expression)
+	if err != nil {
+		return err
+	}
+	ctx := &exprctx{db: db, date: db.currentDate(), parsing: true}
+	value, err := expr.eval(ctx)
+	if err != nil {
+		return err
+	}
+	db.define(d.defname, value)
 	return nil
 }
 
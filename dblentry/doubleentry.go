@@ -116,13 +116,13 @@ func (de *DoubleEntry) AddBalance(commodity api.Commoditiser) error {
 		}
 	} else {
 		if credit, ok := de.credits[comm.name]; ok {
-			err := credit.ApplyAmount(comm.MakeSimilar(-comm.amount))
+			err := credit.ApplyAmount(comm.MakeSimilar(comm.amount.Neg()))
 			if err != nil {
 				return err
 			}
 			de.credits[comm.name] = credit
 		} else {
-			de.credits[comm.name] = comm.makeSimilar(-comm.amount) // negated
+			de.credits[comm.name] = comm.makeSimilar(comm.amount.Neg())
 		}
 	}
 	return nil
@@ -144,7 +144,8 @@ func (de *DoubleEntry) Clone() *DoubleEntry {
 
 func (de *DoubleEntry) IsBalanced() bool {
 	for _, balance := range de.Balances() {
-		if amnt := balance.Amount(); amnt < -0.01 || amnt > 0.01 {
+		comm := balance.(*Commodity)
+		if comm.amount.Round(comm.precision).IsZero() == false {
 			return false
 		}
 	}
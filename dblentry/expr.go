@@ -0,0 +1,595 @@
+package dblentry
+
+import "fmt"
+import "time"
+import "regexp"
+import "strings"
+
+import "github.com/prataprc/goparsec"
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+
+// Grammar for value expressions, used by assert, check and eval.
+//
+// yprimary   -> NUMBER | STRING | /REGEX/ | [DATE] | true | false |
+//               IDENT "(" args ")" | IDENT | "(" yexpr ")"
+// yunary     -> ("-" | "not" | "!") yunary | yprimary
+// ymul       -> yunary (("*" | "/") yunary)*
+// yadd       -> ymul (("+" | "-") ymul)*
+// ycompare   -> yadd (("==" | "!=" | "<=" | ">=" | "<" | ">" | "=~") yadd)?
+// yand       -> ycompare (("and" | "&&") ycompare)*
+// yor        -> yand (("or" | "||") yand)*
+// yexpr      -> yor
+//
+// Numbers are exact decimals. Identifiers, evaluated against a posting:
+//   amount    posting's amount, as a number
+//   commodity posting's commodity name
+//   account   posting's account name
+//   payee     posting's payee
+//   date      date of transaction, or the date of assert/check directive
+//   total     account's balance, in posting's commodity, after the posting
+//   NAME      value bound by define directive
+//
+// Functions:
+//   balance(ACCOUNT [, COMMODITY]) account's balance as a number
+//   tag(NAME)                      value of tag or metadata, else false
+//   abs(NUMBER)                    absolute value
+
+// Expression node, evaluated at second pass.
+type Expression struct {
+	op       string
+	value    interface{} // for literals
+	name     string      // for identifiers and functions
+	operands []*Expression
+	text     string // source text, for root of the expression.
+}
+
+// exprctx context for evaluating expression.
+type exprctx struct {
+	db    *Datastore
+	date  time.Time
+	trans *Transaction
+	p     *Posting
+	// parsing is true for expressions evaluated before postings are
+	// applied, account balances are not known then.
+	parsing bool
+}
+
+// NewExpression parse text into an expression tree, entire text should
+// be consumed.
+func NewExpression(db *Datastore, text string) (*Expression, error) {
+	text = strings.Trim(text, " \t")
+	if strings.HasPrefix(text, "{") && strings.HasSuffix(text, "}") {
+		text = text[1 : len(text)-1]
+	}
+	scanner := parsec.NewScanner([]byte(text))
+	node, scanner := yexpression(db)(scanner)
+	if node == nil {
+		return nil, fmt.Errorf("invalid expression %q", text)
+	} else if err, ok := node.(error); ok {
+		return nil, err
+	}
+	if _, scanner = scanner.SkipWS(); scanner.Endof() == false {
+		cursor := scanner.GetCursor()
+		fmsg := "invalid expression %q at %q"
+		return nil, fmt.Errorf(fmsg, text, text[cursor:])
+	}
+	expr := node.(*Expression)
+	expr.text = text
+	return expr, nil
+}
+
+func newExpression(op string, operands ...*Expression) *Expression {
+	return &Expression{op: op, operands: operands}
+}
+
+//---- ledger parser
+
+func yexpression(db *Datastore) parsec.Parser {
+	var yexpr, yunary parsec.Parser
+
+	// fold left associative binary operators.
+	foldbinary := func(nodes []parsec.ParsecNode) parsec.ParsecNode {
+		op1, ok := nodes[0].(*Expression)
+		if ok == false {
+			return nodes[0]
+		}
+		for _, nd := range nodes[1].([]parsec.ParsecNode) {
+			ns := nd.([]parsec.ParsecNode)
+			op2, ok := ns[1].(*Expression)
+			if ok == false {
+				return ns[1]
+			}
+			operator := strings.ToLower(ns[0].(*parsec.Terminal).Value)
+			op1 = newExpression(operator, op1, op2)
+		}
+		return op1
+	}
+
+	ynumber := parsec.Token(`[0-9]+(\.[0-9]+)?`, "NUMBER")
+	yregex := parsec.Token(`/(\\.|[^/])*/`, "REGEX")
+	ydate := parsec.Token(`\[[^\]]+\]`, "DATE")
+	ybool := parsec.Token(`(true|false)\b`, "BOOL")
+	yident := parsec.Token(`[a-zA-Z_][a-zA-Z0-9_]*`, "IDENT")
+	yargs := parsec.Kleene(nil, &yexpr, parsec.Atom(",", "COMMA"))
+	ycall := parsec.And(
+		nil, yident, parsec.Atom("(", "OPENPARAN"), yargs,
+		parsec.Atom(")", "CLOSEPARAN"),
+	)
+	yparan := parsec.And(
+		nil, parsec.Atom("(", "OPENPARAN"), &yexpr,
+		parsec.Atom(")", "CLOSEPARAN"),
+	)
+
+	yprimary := parsec.OrdChoice(
+		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
+			switch v := nodes[0].(type) {
+			case string:
+				expr := newExpression("literal")
+				expr.value = v[1 : len(v)-1]
+				return expr
+
+			case []parsec.ParsecNode: // function call or paranthesis
+				if _, ok := v[1].(*parsec.Terminal); ok == false {
+					return v[1]
+				}
+				expr := newExpression("call")
+				expr.name = v[0].(*parsec.Terminal).Value
+				for _, arg := range v[2].([]parsec.ParsecNode) {
+					operand, ok := arg.(*Expression)
+					if ok == false {
+						return arg
+					}
+					expr.operands = append(expr.operands, operand)
+				}
+				return expr
+
+			case *parsec.Terminal:
+				return yterminal(db, v)
+			}
+			panic("unreachable code")
+		},
+		ynumber, parsec.String(), yregex, ydate, ybool, ycall, yparan,
+		yident,
+	)
+
+	yunary = parsec.OrdChoice(
+		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
+			switch v := nodes[0].(type) {
+			case *Expression, error:
+				return v
+			case []parsec.ParsecNode:
+				operand, ok := v[1].(*Expression)
+				if ok == false {
+					return v[1]
+				}
+				if v[0].(*parsec.Terminal).Value == "-" {
+					return newExpression("neg", operand)
+				}
+				return newExpression("not", operand)
+			}
+			panic("unreachable code")
+		},
+		parsec.And(nil, parsec.Token(`(-|not\b|!)`, "UNARY"), &yunary),
+		yprimary,
+	)
+
+	ymul := parsec.And(
+		foldbinary, &yunary,
+		parsec.Kleene(
+			nil, parsec.And(nil, parsec.Token(`[*/]`, "MUL"), &yunary),
+		),
+	)
+	yadd := parsec.And(
+		foldbinary, ymul,
+		parsec.Kleene(
+			nil, parsec.And(nil, parsec.Token(`[+-]`, "ADD"), ymul),
+		),
+	)
+	ycompare := parsec.And(
+		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
+			op1, ok := nodes[0].(*Expression)
+			if ok == false {
+				return nodes[0]
+			}
+			ns, ok := nodes[1].([]parsec.ParsecNode)
+			if ok == false { // MaybeNone
+				return op1
+			}
+			op2, ok := ns[1].(*Expression)
+			if ok == false {
+				return ns[1]
+			}
+			return newExpression(ns[0].(*parsec.Terminal).Value, op1, op2)
+		},
+		yadd,
+		parsec.Maybe(
+			maybenode,
+			parsec.And(nil, parsec.Token(`(==|!=|<=|>=|=~|<|>)`, "CMP"), yadd),
+		),
+	)
+	yand := parsec.And(
+		foldbinary, ycompare,
+		parsec.Kleene(
+			nil, parsec.And(nil, parsec.Token(`(and\b|&&)`, "AND"), ycompare),
+		),
+	)
+	yexpr = parsec.And(
+		foldbinary, yand,
+		parsec.Kleene(
+			nil, parsec.And(nil, parsec.Token(`(or\b|\|\|)`, "OR"), yand),
+		),
+	)
+	return yexpr
+}
+
+func yterminal(db *Datastore, t *parsec.Terminal) parsec.ParsecNode {
+	expr := newExpression("literal")
+	switch t.Name {
+	case "NUMBER":
+		number, err := api.ParseDecimal(t.Value)
+		if err != nil {
+			return err
+		}
+		expr.value = number
+
+	case "REGEX":
+		regc, err := regexp.Compile(t.Value[1 : len(t.Value)-1])
+		if err != nil {
+			return err
+		}
+		expr.value = regc
+
+	case "DATE":
+		datestr := t.Value[1 : len(t.Value)-1]
+		node, _ := Ydate(db.getYear())(parsec.NewScanner([]byte(datestr)))
+		tm, ok := node.(time.Time)
+		if ok == false {
+			return fmt.Errorf("invalid date %q", datestr)
+		}
+		expr.value = tm
+
+	case "BOOL":
+		expr.value = t.Value == "true"
+
+	case "IDENT":
+		switch t.Value {
+		case "and", "or", "not":
+			return nil
+		}
+		expr.op, expr.name = "ident", t.Value
+	}
+	return expr
+}
+
+//---- engine
+
+// eval expression under the context, returned value can be one of
+// api.Decimal, string, bool, time.Time or *regexp.Regexp.
+func (expr *Expression) eval(ctx *exprctx) (interface{}, error) {
+	switch expr.op {
+	case "literal":
+		return expr.value, nil
+	case "ident":
+		return expr.evalident(ctx)
+	case "call":
+		return expr.evalcall(ctx)
+	}
+
+	values := []interface{}{}
+	for _, operand := range expr.operands {
+		value, err := operand.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	switch expr.op {
+	case "neg":
+		x, ok := values[0].(api.Decimal)
+		if ok == false {
+			return nil, fmt.Errorf("cannot negate %v", values[0])
+		}
+		return x.Neg(), nil
+	case "not":
+		return !exprtruth(values[0]), nil
+	case "and", "&&":
+		return exprtruth(values[0]) && exprtruth(values[1]), nil
+	case "or", "||":
+		return exprtruth(values[0]) || exprtruth(values[1]), nil
+	case "+", "-", "*", "/":
+		return exprarith(expr.op, values[0], values[1])
+	case "=~":
+		return exprmatch(values[0], values[1])
+	}
+	return exprcompare(expr.op, values[0], values[1])
+}
+
+func (expr *Expression) evalident(ctx *exprctx) (interface{}, error) {
+	if expr.name == "date" {
+		return ctx.date, nil
+	} else if ctx.p == nil {
+		switch expr.name {
+		case "amount", "commodity", "account", "payee", "total":
+			fmsg := "%q is not available without posting"
+			return nil, fmt.Errorf(fmsg, expr.name)
+		}
+		if value, ok := ctx.db.lookupDefine(expr.name); ok {
+			return value, nil
+		}
+		return nil, fmt.Errorf("unknown identifier %q", expr.name)
+	}
+
+	p := ctx.p
+	switch expr.name {
+	case "amount":
+		return p.commodity.amount, nil
+	case "commodity":
+		return p.commodity.name, nil
+	case "account":
+		return p.account.name, nil
+	case "payee":
+		return p.Payee(), nil
+	case "total":
+		return ctx.balance(p.account.name, p.commodity.name), nil
+	}
+	if value, ok := ctx.db.lookupDefine(expr.name); ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("unknown identifier %q", expr.name)
+}
+
+func (expr *Expression) evalcall(ctx *exprctx) (interface{}, error) {
+	args := []interface{}{}
+	for _, operand := range expr.operands {
+		arg, err := operand.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	switch expr.name {
+	case "balance":
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("balance() expects 1 or 2 arguments")
+		} else if ctx.parsing {
+			return nil, fmt.Errorf("balance() not allowed while parsing")
+		}
+		strs := []string{}
+		for _, arg := range args {
+			s, ok := arg.(string)
+			if ok == false {
+				return nil, fmt.Errorf("balance() expects string, got %v", arg)
+			}
+			strs = append(strs, s)
+		}
+		if len(strs) == 1 {
+			strs = append(strs, ctx.commodity(strs[0]))
+		}
+		return ctx.balance(strs[0], strs[1]), nil
+
+	case "tag":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("tag() expects 1 argument")
+		}
+		name, ok := args[0].(string)
+		if ok == false {
+			return nil, fmt.Errorf("tag() expects string, got %v", args[0])
+		}
+		return ctx.tag(name), nil
+
+	case "abs":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("abs() expects 1 argument")
+		}
+		x, ok := args[0].(api.Decimal)
+		if ok == false {
+			return nil, fmt.Errorf("abs() expects number, got %v", args[0])
+		}
+		return x.Abs(), nil
+	}
+	return nil, fmt.Errorf("unknown function %q", expr.name)
+}
+
+func (expr *Expression) String() string {
+	switch expr.op {
+	case "literal":
+		return fmt.Sprintf("%v", expr.value)
+	case "ident":
+		return expr.name
+	case "call":
+		args := []string{}
+		for _, operand := range expr.operands {
+			args = append(args, operand.String())
+		}
+		return fmt.Sprintf("%v(%v)", expr.name, strings.Join(args, ", "))
+	case "neg":
+		return fmt.Sprintf("-%v", expr.operands[0])
+	case "not":
+		return fmt.Sprintf("(not %v)", expr.operands[0])
+	}
+	op1, op2 := expr.operands[0], expr.operands[1]
+	return fmt.Sprintf("(%v %v %v)", op1, expr.op, op2)
+}
+
+//---- evaluation context
+
+// balance of account in commodity, accounts that are not yet known have
+// zero balance.
+func (ctx *exprctx) balance(accname, commname string) api.Decimal {
+	if acc, ok := ctx.db.accntdb[accname]; ok {
+		if bal, ok := acc.de.balances[commname]; ok {
+			return bal.amount
+		}
+	}
+	return api.NewDecimal(0)
+}
+
+// commodity return the only commodity held by the account, else the
+// default commodity.
+func (ctx *exprctx) commodity(accname string) string {
+	if acc, ok := ctx.db.accntdb[accname]; ok && len(acc.de.balances) == 1 {
+		for name := range acc.de.balances {
+			return name
+		}
+	}
+	return ctx.db.getDefaultcomm()
+}
+
+func (ctx *exprctx) tag(name string) interface{} {
+	name = strings.ToLower(name)
+	if ctx.p != nil {
+		if value := ctx.p.getMetadata(name); value != nil {
+			return value
+		}
+		for _, tag := range ctx.p.tags {
+			if strings.ToLower(tag) == name {
+				return true
+			}
+		}
+	}
+	if ctx.trans != nil {
+		for _, tag := range ctx.trans.tags {
+			if strings.ToLower(tag) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//---- local functions
+
+func exprtruth(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case api.Decimal:
+		return v.IsZero() == false
+	case string:
+		return v != ""
+	case time.Time:
+		return v.IsZero() == false
+	}
+	return value != nil
+}
+
+func exprarith(op string, x, y interface{}) (interface{}, error) {
+	a, ok1 := x.(api.Decimal)
+	b, ok2 := y.(api.Decimal)
+	if ok1 == false || ok2 == false {
+		return nil, fmt.Errorf("cannot %v %v %v", x, op, y)
+	}
+	switch op {
+	case "+":
+		return a.Add(b), nil
+	case "-":
+		return a.Sub(b), nil
+	case "*":
+		return a.Mul(b), nil
+	}
+	if b.IsZero() {
+		return nil, fmt.Errorf("divide by zero %v / %v", a, b)
+	}
+	return a.Quo(b), nil
+}
+
+func exprmatch(x, y interface{}) (interface{}, error) {
+	s, ok := x.(string)
+	if ok == false {
+		return nil, fmt.Errorf("cannot match %v", x)
+	}
+	switch re := y.(type) {
+	case *regexp.Regexp:
+		return re.MatchString(s), nil
+	case string:
+		regc, err := regexp.Compile(re)
+		if err != nil {
+			return nil, err
+		}
+		return regc.MatchString(s), nil
+	}
+	return nil, fmt.Errorf("cannot match %v with %v", x, y)
+}
+
+func exprcompare(op string, x, y interface{}) (interface{}, error) {
+	var cmp int
+	switch a := x.(type) {
+	case api.Decimal:
+		b, ok := y.(api.Decimal)
+		if ok == false {
+			return nil, fmt.Errorf("cannot compare %v %v %v", x, op, y)
+		}
+		cmp = a.Cmp(b) // numbers are exact, compared as is.
+
+	case string:
+		b, ok := y.(string)
+		if ok == false {
+			return nil, fmt.Errorf("cannot compare %v %v %v", x, op, y)
+		}
+		cmp = strings.Compare(a, b)
+
+	case time.Time:
+		b, ok := y.(time.Time)
+		if ok == false {
+			return nil, fmt.Errorf("cannot compare %v %v %v", x, op, y)
+		} else if a.Before(b) {
+			cmp = -1
+		} else if a.After(b) {
+			cmp = 1
+		}
+
+	case bool:
+		b, ok := y.(bool)
+		if ok == false || (op != "==" && op != "!=") {
+			return nil, fmt.Errorf("cannot compare %v %v %v", x, op, y)
+		} else if a != b {
+			cmp = 1
+		}
+
+	default:
+		return nil, fmt.Errorf("cannot compare %v %v %v", x, op, y)
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+// evalassertion evaluate `check`, `assert` or `eval` expression. Failed
+// check is logged as warning, failed assert is returned as error. Errors
+// are located at journalfile:lineno.
+func evalassertion(
+	kind string, expr *Expression, ctx *exprctx,
+	journalfile string, lineno int) error {
+
+	value, err := expr.eval(ctx)
+	if err == nil && (kind == "eval" || exprtruth(value)) {
+		return nil
+	} else if err == nil {
+		err = fmt.Errorf("%v %v failed at %q:%v", kind, expr, journalfile, lineno)
+	} else {
+		fmsg := "%v %v at %q:%v : %v"
+		err = fmt.Errorf(fmsg, kind, expr, journalfile, lineno, err)
+	}
+
+	if kind == "check" {
+		log.Warnf("%v\n", err)
+		return nil
+	}
+	return err
+}
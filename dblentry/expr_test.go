@@ -0,0 +1,63 @@
+package dblentry
+
+import "testing"
+
+import "github.com/tn47/goledger/api"
+
+func TestExpression(t *testing.T) {
+	db := NewDatastore("testing", nil)
+	db.define("rent", api.NewDecimal(1200))
+	testcases := [][]interface{}{
+		{"1 + 2 * 3", "(1 + (2 * 3))", "7"},
+		{"(1 + 2) * 3", "((1 + 2) * 3)", "9"},
+		{"10 - 2 - 3", "((10 - 2) - 3)", "5"},
+		{"-2 * 3 < 0", "((-2 * 3) < 0)", true},
+		{"{1 == 1.0}", "(1 == 1)", true},
+		{"not true || false", "((not true) || false)", false},
+		{"1 < 2 and 2 < 1", "((1 < 2) and (2 < 1))", false},
+		{`"Assets:Bank" =~ /^Assets/`, "(Assets:Bank =~ ^Assets)", true},
+		{"[2011/01/02] > [2011/01/01]", "", true},
+		{"abs(-4) == 4", "(abs(-4) == 4)", true},
+		{`balance("Assets:Bank") == 0`, "(balance(Assets:Bank) == 0)", true},
+		{"0.1 + 0.2 == 0.3", "((0.1 + 0.2) == 0.3)", true},
+		{"0.3 - 0.1 - 0.2", "((0.3 - 0.1) - 0.2)", "0"},
+		{"1.0000000001 > 1", "(1.0000000001 > 1)", true},
+		{"10 / 4", "(10 / 4)", "2.5"},
+		{"rent / 2", "(rent / 2)", "600"},
+	}
+	for _, tcase := range testcases {
+		text := tcase[0].(string)
+		expr, err := NewExpression(db, text)
+		if err != nil {
+			t.Fatalf("%q: %v", text, err)
+		}
+		if ref := tcase[1].(string); ref != "" && expr.String() != ref {
+			t.Errorf("%q: expected %q, got %q", text, ref, expr.String())
+		}
+		value, err := expr.eval(&exprctx{db: db})
+		if d, ok := value.(api.Decimal); ok { // numbers are exact decimals
+			value = d.String()
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", text, err)
+		} else if value != tcase[2] {
+			t.Errorf("%q: expected %v, got %v", text, tcase[2], value)
+		}
+	}
+
+	// invalid expressions
+	for _, text := range []string{"1 +", "(1 + 2", "1 2", "[xyz]"} {
+		if _, err := NewExpression(db, text); err == nil {
+			t.Errorf("%q: expected error", text)
+		}
+	}
+	// evaluation errors
+	for _, text := range []string{`-"abc"`, "unknown", `1 + "x"`} {
+		expr, err := NewExpression(db, text)
+		if err != nil {
+			t.Fatalf("%q: %v", text, err)
+		} else if _, err := expr.eval(&exprctx{db: db}); err == nil {
+			t.Errorf("%q: expected evaluation error", text)
+		}
+	}
+}
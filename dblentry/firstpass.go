@@ -11,6 +11,7 @@ type firstpass struct {
 	comments    []string
 
 	currdate     time.Time
+	transdate    time.Time // date of last transaction parsed.
 	rootaccount  string
 	blncingaccnt string
 	aliases      map[string]string // alias, account-alias
@@ -19,6 +20,11 @@ type firstpass struct {
 	captures     map[string]string
 	recaptures   map[string]*regexp.Regexp
 	dpayees      map[string]*Payee
+	automated    []*Automated
+	periodics    []*Periodic
+	defines      map[string]interface{} // define name -> value
+	fixedprices  map[string]*Commodity  // commodity -> fixed price
+	formats      map[string]*Commodity  // commodity -> declared format
 }
 
 func (fp *firstpass) initfirstpass() {
@@ -29,6 +35,12 @@ func (fp *firstpass) initfirstpass() {
 	fp.repayees = map[string]*regexp.Regexp{}
 	fp.captures = map[string]string{}
 	fp.recaptures = map[string]*regexp.Regexp{}
+	fp.dpayees = map[string]*Payee{}
+	fp.automated = []*Automated{}
+	fp.periodics = []*Periodic{}
+	fp.defines = map[string]interface{}{}
+	fp.fixedprices = map[string]*Commodity{}
+	fp.formats = map[string]*Commodity{}
 }
 
 //---- local accessors
@@ -58,13 +70,59 @@ func (fp *firstpass) getYear() int {
 }
 
 func (fp *firstpass) setCurrentDate(date time.Time) {
-	fp.currdate = date
+	fp.currdate, fp.transdate = date, date
 }
 
 func (fp *firstpass) currentDate() time.Time {
 	return fp.currdate
 }
 
+func (fp *firstpass) lastTransdate() time.Time {
+	return fp.transdate
+}
+
+func (fp *firstpass) define(name string, value interface{}) {
+	if _, ok := fp.defines[name]; ok {
+		log.Warnf("%q redefined\n", name)
+	}
+	fp.defines[name] = value
+}
+
+func (fp *firstpass) lookupDefine(name string) (interface{}, bool) {
+	value, ok := fp.defines[name]
+	return value, ok
+}
+
+func (fp *firstpass) setFixedprice(name string, price *Commodity) {
+	fp.fixedprices[name] = price
+}
+
+// clearFixedprice for commodity, if name is empty clear all fixed prices.
+func (fp *firstpass) clearFixedprice(name string) error {
+	if len(fp.fixedprices) == 0 {
+		return fmt.Errorf("dangling `endfixed` directive")
+	} else if name == "" {
+		fp.fixedprices = map[string]*Commodity{}
+		return nil
+	} else if _, ok := fp.fixedprices[name]; ok == false {
+		return fmt.Errorf("commodity %q is not fixed", name)
+	}
+	delete(fp.fixedprices, name)
+	return nil
+}
+
+func (fp *firstpass) getFixedprice(name string) *Commodity {
+	return fp.fixedprices[name]
+}
+
+func (fp *firstpass) setFormat(name string, format *Commodity) {
+	fp.formats[name] = format
+}
+
+func (fp *firstpass) getFormat(name string) *Commodity {
+	return fp.formats[name]
+}
+
 func (fp *firstpass) setrootaccount(name string) error {
 	if fp.rootaccount != "" {
 		fmsg := "previous `apply` directive(%v) not closed"
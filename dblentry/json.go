@@ -0,0 +1,261 @@
+package dblentry
+
+import "sort"
+import "strings"
+import "encoding/json"
+
+import "github.com/tn47/goledger/api"
+
+// jsonjournal is the schema for exporting journals as JSON, dates are
+// formatted as `YYYY-MM-DD`.
+type jsonjournal struct {
+	Accounts     []*jsonaccount     `json:"accounts"`
+	Commodities  []*jsoncommodity   `json:"commodities"`
+	Payees       []*jsonpayee       `json:"payees"`
+	Prices       []*jsonprice       `json:"prices"`
+	Transactions []*jsontransaction `json:"transactions"`
+}
+
+type jsonaccount struct {
+	Name     string   `json:"name"`
+	Declared bool     `json:"declared"`
+	Notes    []string `json:"notes"`
+	Aliases  []string `json:"aliases"`
+	Payees   []string `json:"payees"`
+	Types    []string `json:"types"`
+}
+
+type jsoncommodity struct {
+	Name      string   `json:"name"`
+	Declared  bool     `json:"declared"`
+	Default   bool     `json:"default"`
+	Currency  bool     `json:"currency"`
+	Precision int      `json:"precision"`
+	Nomarket  bool     `json:"nomarket"`
+	Notes     []string `json:"notes"`
+}
+
+type jsonpayee struct {
+	Name     string   `json:"name"`
+	Declared bool     `json:"declared"`
+	Aliases  []string `json:"aliases"`
+	Uuids    []string `json:"uuids"`
+}
+
+type jsonprice struct {
+	Date      string      `json:"date"`
+	Commodity string      `json:"commodity"`
+	Price     *jsonamount `json:"price"`
+}
+
+type jsonamount struct {
+	Commodity string      `json:"commodity"`
+	Quantity  api.Decimal `json:"quantity"`
+	Text      string      `json:"text"` // formatted as per commodity.
+}
+
+type jsontransaction struct {
+	Journal  string                 `json:"journal"`
+	Lineno   int                    `json:"lineno"`
+	Date     string                 `json:"date"`
+	Edate    string                 `json:"edate,omitempty"`
+	State    string                 `json:"state"`
+	Code     string                 `json:"code"`
+	Payee    string                 `json:"payee"`
+	Notes    []string               `json:"notes"`
+	Tags     []string               `json:"tags"`
+	Metadata map[string]interface{} `json:"metadata"`
+	Postings []*jsonposting         `json:"postings"`
+}
+
+type jsonposting struct {
+	Account   string                 `json:"account"`
+	Virtual   bool                   `json:"virtual"`
+	Balanced  bool                   `json:"balanced"`
+	State     string                 `json:"state"`
+	Amount    *jsonamount            `json:"amount"`
+	Elided    bool                   `json:"elided"`
+	Lotprice  *jsonamount            `json:"lotprice,omitempty"`
+	Lotdate   string                 `json:"lotdate,omitempty"`
+	Costprice *jsonamount            `json:"costprice,omitempty"`
+	Balance   *jsonamount            `json:"balance,omitempty"`
+	Note      string                 `json:"note"`
+	Tags      []string               `json:"tags"`
+	Metadata  map[string]interface{} `json:"metadata"`
+}
+
+// JSON export accounts, commodities, payees, prices and transactions as
+// JSON document. Accounts, postings and amounts are resolved as in
+// Ledger(). Shall be called after secondpass.
+func (db *Datastore) JSON() ([]byte, error) {
+	journal := &jsonjournal{
+		Accounts:     db.jsonaccounts(),
+		Commodities:  db.jsoncommodities(),
+		Payees:       []*jsonpayee{},
+		Prices:       []*jsonprice{},
+		Transactions: []*jsontransaction{},
+	}
+
+	entries := []api.TimeEntry{}
+	for _, entry := range db.pricedb.Range(nil, nil, "both", entries) {
+		price := entry.Value().(*Price)
+		if price.quoted == false {
+			continue // implied by posting's cost.
+		}
+		journal.Prices = append(journal.Prices, &jsonprice{
+			Date:      price.when.Format("2006-01-02"),
+			Commodity: price.this.name,
+			Price:     jsonamountof(price.other),
+		})
+	}
+
+	payees := map[string]*jsonpayee{}
+	for _, name := range db.dclrdpayee {
+		payee := db.dpayees[name]
+		payees[name] = &jsonpayee{
+			Name: name, Declared: true,
+			Aliases: jsonstrings(payee.aliases),
+			Uuids:   jsonstrings(payee.uuids),
+		}
+	}
+
+	entries = []api.TimeEntry{}
+	for _, entry := range db.transdb.Range(nil, nil, "both", entries) {
+		trans := entry.Value().(*Transaction)
+		if trans.forecast {
+			continue
+		} else if db.periodtill != nil && !trans.date.Before(*db.periodtill) {
+			continue
+		}
+		jtrans := jsontransactionof(trans)
+		journal.Transactions = append(journal.Transactions, jtrans)
+		if _, ok := payees[jtrans.Payee]; ok == false {
+			payees[jtrans.Payee] = &jsonpayee{
+				Name: jtrans.Payee, Aliases: []string{}, Uuids: []string{},
+			}
+		}
+	}
+	for _, payee := range payees {
+		journal.Payees = append(journal.Payees, payee)
+	}
+	sort.Slice(journal.Payees, func(i, j int) bool {
+		return journal.Payees[i].Name < journal.Payees[j].Name
+	})
+
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (db *Datastore) jsonaccounts() []*jsonaccount {
+	accounts := []*jsonaccount{}
+	for _, name := range db.Accountnames() {
+		acc := db.accntdb[name]
+		accounts = append(accounts, &jsonaccount{
+			Name:     name,
+			Declared: api.HasString(db.dclrdacc, name),
+			Notes:    jsonstrings(acc.notes),
+			Aliases:  jsonstrings(acc.aliases),
+			Payees:   jsonstrings(acc.payees),
+			Types:    jsonstrings(acc.types),
+		})
+	}
+	return accounts
+}
+
+func (db *Datastore) jsoncommodities() []*jsoncommodity {
+	commodities := []*jsoncommodity{}
+	for _, name := range db.Commoditynames() {
+		if name == "" {
+			continue
+		}
+		comm := db.commodities[name]
+		commodities = append(commodities, &jsoncommodity{
+			Name:      name,
+			Declared:  api.HasString(db.dclrdcomm, name),
+			Default:   name == db.getDefaultcomm(),
+			Currency:  comm.currency,
+			Precision: comm.precision,
+			Nomarket:  comm.nomarket,
+			Notes:     jsonstrings(comm.notes),
+		})
+	}
+	return commodities
+}
+
+func jsontransactionof(trans *Transaction) *jsontransaction {
+	journalfile, lineno := trans.Position()
+	jtrans := &jsontransaction{
+		Journal:  journalfile,
+		Lineno:   lineno,
+		Date:     trans.date.Format("2006-01-02"),
+		State:    trans.getState(),
+		Code:     trans.code,
+		Payee:    strings.TrimSpace(trans.Payee()),
+		Notes:    []string{},
+		Tags:     jsonstrings(trans.tags),
+		Metadata: map[string]interface{}{},
+		Postings: []*jsonposting{},
+	}
+	if trans.edate.IsZero() == false {
+		jtrans.Edate = trans.edate.Format("2006-01-02")
+	}
+	for _, note := range trans.notes {
+		note = strings.TrimSpace(strings.TrimLeft(note, ";"))
+		jtrans.Notes = append(jtrans.Notes, note)
+	}
+	for key, value := range trans.metadata {
+		if key != "payee" && key != "state" {
+			jtrans.Metadata[key] = value
+		}
+	}
+
+	for _, p := range trans.postings {
+		jp := &jsonposting{
+			Account:   p.account.name,
+			Virtual:   p.virtual,
+			Balanced:  p.balanced,
+			Amount:    jsonamountof(p.commodity),
+			Elided:    p.elided,
+			Lotprice:  jsonamountof(p.lotprice),
+			Costprice: jsonamountof(p.costprice),
+			Balance:   jsonamountof(p.balprice),
+			Note:      strings.TrimSpace(strings.TrimLeft(p.note, ";")),
+			Tags:      jsonstrings(p.tags),
+			Metadata:  map[string]interface{}{},
+		}
+		if jp.State, _ = p.metadata["state"].(string); jp.State == "" {
+			jp.State = jtrans.State
+		}
+		if p.lotdate.IsZero() == false {
+			jp.Lotdate = p.lotdate.Format("2006-01-02")
+		}
+		for key, value := range p.metadata {
+			if key != "state" {
+				jp.Metadata[key] = value
+			}
+		}
+		jtrans.Postings = append(jtrans.Postings, jp)
+	}
+	return jtrans
+}
+
+func jsonamountof(comm *Commodity) *jsonamount {
+	if comm == nil {
+		return nil
+	}
+	return &jsonamount{
+		Commodity: comm.name, Quantity: comm.amount, Text: ledgeramount(comm),
+	}
+}
+
+// jsonstrings return an empty list for nil, so that lists are never
+// exported as null.
+func jsonstrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
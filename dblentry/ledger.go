@@ -0,0 +1,410 @@
+package dblentry
+
+import "fmt"
+import "sort"
+import "time"
+import "regexp"
+import "strings"
+import "strconv"
+import "unicode/utf8"
+
+import "github.com/tn47/goledger/api"
+
+// commodity names other than these shall be quoted.
+var reledgercommodity = regexp.MustCompile(
+	`^[^\s\d"@=;{}()\[\],.+*/^&|<>!~-]+$`,
+)
+
+// Ledger export journals as a single normalised journal. Aliases, `apply
+// account` prefixes, payee rewrites, automated transactions and elided
+// amounts are resolved, while amounts are formatted as per commodity's
+// precision. Shall be called after secondpass.
+func (db *Datastore) Ledger() []string {
+	lines := []string{}
+	lines = append(lines, db.ledgerdefines()...)
+	lines = append(lines, db.ledgercommodities()...)
+	lines = append(lines, db.ledgeraccounts()...)
+	lines = append(lines, db.ledgerpayees()...)
+
+	prices := []string{}
+	entries := []api.TimeEntry{}
+	for _, entry := range db.pricedb.Range(nil, nil, "both", entries) {
+		price := entry.Value().(*Price)
+		if price.quoted == false {
+			continue // implied by posting's cost.
+		}
+		line := fmt.Sprintf(
+			"P %v %v %v", price.when.Format("2006/01/02"),
+			ledgercommname(price.this.name), ledgeramount(price.other),
+		)
+		prices = append(prices, line)
+	}
+	if len(prices) > 0 {
+		lines = append(lines, prices...)
+		lines = append(lines, "")
+	}
+
+	for _, pt := range db.periodics {
+		lines = append(lines, "~ "+pt.periodexpr)
+		lines = append(lines, ledgerpostings(pt.trans)...)
+		lines = append(lines, "")
+	}
+
+	// directives are placed after all transactions on or before its date.
+	checks := db.checkdb.Range(nil, nil, "both", []api.TimeEntry{})
+	flushchecks := func(till *time.Time) {
+		for len(checks) > 0 {
+			if till != nil && checks[0].Key().Before(*till) == false {
+				break
+			}
+			d := checks[0].Value().(*Directive)
+			line := fmt.Sprintf("%v %v", d.dtype, d.expr.text)
+			lines = append(lines, line, "")
+			checks = checks[1:]
+		}
+	}
+	entries = []api.TimeEntry{}
+	for _, entry := range db.transdb.Range(nil, nil, "both", entries) {
+		trans := entry.Value().(*Transaction)
+		if trans.forecast {
+			continue
+		} else if db.periodtill != nil && !trans.date.Before(*db.periodtill) {
+			continue
+		}
+		flushchecks(&trans.date)
+		lines = append(lines, ledgertransaction(trans)...)
+		lines = append(lines, "")
+	}
+	flushchecks(db.periodtill)
+	return lines
+}
+
+func (db *Datastore) ledgerdefines() []string {
+	names := []string{}
+	for name := range db.defines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		var value string
+		switch v := db.defines[name].(type) {
+		case api.Decimal:
+			value = v.String()
+		case string:
+			value = strconv.Quote(v)
+		case time.Time:
+			value = v.Format("[2006/01/02]")
+		default:
+			value = fmt.Sprintf("%v", v)
+		}
+		lines = append(lines, fmt.Sprintf("define %v = %v", name, value))
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// ledgercommodities declare every commodity, so that their precision
+// and default commodity are preserved.
+func (db *Datastore) ledgercommodities() []string {
+	lines := []string{}
+	for _, name := range db.Commoditynames() {
+		if name == "" {
+			continue
+		}
+		comm := db.commodities[name]
+		lines = append(lines, "commodity "+ledgercommname(name))
+		for _, note := range comm.notes {
+			lines = append(lines, "    note  "+note)
+		}
+		format := ledgeramount(comm.makeSimilar(api.NewDecimal(1000)))
+		lines = append(lines, "    format  "+format)
+		if comm.nomarket {
+			lines = append(lines, "    nomarket")
+		}
+		if name == db.getDefaultcomm() {
+			lines = append(lines, "    default")
+		}
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// ledgeraccounts declare accounts that were declared in the journal. Sub
+// directives like note, alias and payee take single value per directive,
+// hence an account is declared as many times as its longest list.
+func (db *Datastore) ledgeraccounts() []string {
+	names := []string{}
+	for _, name := range db.dclrdacc {
+		if api.HasString(names, name) == false {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		acc := db.accntdb[name]
+		exprtexts := func(exprs []*Expression) []string {
+			texts := []string{}
+			for _, expr := range exprs {
+				texts = append(texts, expr.text)
+			}
+			return texts
+		}
+		kinds := []string{"note", "alias", "payee", "check", "assert", "eval"}
+		lists := [][]string{
+			acc.notes, acc.aliases, acc.payees,
+			exprtexts(acc.checks), exprtexts(acc.asserts), exprtexts(acc.evals),
+		}
+		n := 1
+		for _, list := range lists {
+			if len(list) > n {
+				n = len(list)
+			}
+		}
+		for i := 0; i < n; i++ {
+			lines = append(lines, "account "+name)
+			for j, list := range lists {
+				if i < len(list) {
+					line := fmt.Sprintf("    %v  %v", kinds[j], list[i])
+					lines = append(lines, line)
+				}
+			}
+			if i == 0 && len(acc.types) > 0 {
+				types := strings.Join(acc.types, ",")
+				lines = append(lines, "    type  "+types)
+			}
+			if i == 0 && name == db.getBalancingaccount() {
+				lines = append(lines, "    default")
+			}
+			if i == 0 {
+				for _, comment := range acc.comments {
+					lines = append(lines, "    "+comment)
+				}
+			}
+			lines = append(lines, "")
+		}
+	}
+	return lines
+}
+
+func (db *Datastore) ledgerpayees() []string {
+	names := []string{}
+	for _, name := range db.dclrdpayee {
+		if api.HasString(names, name) == false {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		payee := db.dpayees[name]
+		lines = append(lines, "payee "+name)
+		for _, alias := range payee.aliases {
+			lines = append(lines, "    alias  "+alias)
+		}
+		for _, uuid := range payee.uuids {
+			lines = append(lines, "    uuid  "+uuid)
+		}
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+func ledgertransaction(trans *Transaction) []string {
+	header := trans.date.Format("2006/01/02")
+	if trans.edate.IsZero() == false {
+		header += "=" + trans.edate.Format("2006/01/02")
+	}
+	if prefix := ledgerprefix(trans.getState()); prefix != "" {
+		header += " " + prefix
+	}
+	if trans.code != "" {
+		header += " (" + trans.code + ")"
+	}
+	header += " " + strings.TrimSpace(trans.Payee())
+
+	metadata := map[string]interface{}{}
+	for key, value := range trans.metadata {
+		if key != "payee" && key != "state" {
+			metadata[key] = value
+		}
+	}
+	lines := []string{header}
+	lines = append(lines, ledgertags(trans.tags, metadata, "    ")...)
+	for _, note := range trans.notes {
+		note = strings.TrimSpace(strings.TrimLeft(note, ";"))
+		lines = append(lines, "    ; "+note)
+	}
+	return append(lines, ledgerpostings(trans)...)
+}
+
+// ledgerpostings format postings aligned by account name and amount.
+// Elided postings are left elided, once per account, so that they are
+// balanced again on the same account.
+func ledgerpostings(trans *Transaction) []string {
+	postings, elided := []*Posting{}, map[string]bool{}
+	for _, p := range trans.postings {
+		if p.elided && elided[p.account.name] {
+			continue // split of elided posting by commodity.
+		}
+		elided[p.account.name] = p.elided
+		postings = append(postings, p)
+	}
+
+	accnames, amounts := []string{}, []string{}
+	w0, w1 := 0, 0
+	for _, p := range postings {
+		accname := p.account.name
+		if p.virtual && p.balanced {
+			accname = "[" + accname + "]"
+		} else if p.virtual {
+			accname = "(" + accname + ")"
+		}
+		state, _ := p.metadata["state"].(string)
+		if state != "" && state != trans.getState() {
+			accname = ledgerprefix(state) + " " + accname
+		}
+		amount := ""
+		if p.elided == false && p.commodity != nil {
+			amount = ledgeramount(p.commodity)
+		}
+		accnames, amounts = append(accnames, accname), append(amounts, amount)
+		if n := utf8.RuneCountInString(accname); n > w0 {
+			w0 = n
+		}
+		if n := utf8.RuneCountInString(amount); n > w1 {
+			w1 = n
+		}
+	}
+
+	lines := []string{}
+	for i, p := range postings {
+		line := fmt.Sprintf("    %-*s    %*s", w0, accnames[i], w1, amounts[i])
+		if p.elided == false && p.commodity != nil {
+			line += ledgerprices(p)
+		}
+		if p.balprice != nil {
+			line += " = " + ledgeramount(p.balprice)
+		}
+		line = strings.TrimRight(line, " ")
+
+		// posting's comment can hold either a note, tags or a metadata,
+		// rest of them follow the posting.
+		metadata := map[string]interface{}{}
+		for key, value := range p.metadata {
+			if key != "state" {
+				metadata[key] = value
+			}
+		}
+		comments := ledgertags(p.tags, metadata, "")
+		if p.note != "" {
+			note := strings.TrimSpace(strings.TrimLeft(p.note, ";"))
+			comments = append(comments, "; "+note)
+		}
+		if len(comments) > 0 {
+			line, comments = line+"  "+comments[0], comments[1:]
+		}
+		lines = append(lines, line)
+		for _, comment := range comments {
+			lines = append(lines, "        "+comment)
+		}
+	}
+	return lines
+}
+
+// ledgerprices format lot price, lot date and cost price of posting. Unit
+// price that cannot be represented in commodity's precision is formatted
+// as total price.
+func ledgerprices(p *Posting) string {
+	s := ""
+	if p.lotprice != nil {
+		unit, total := ledgerunitprice(p.lotprice, p.commodity.amount)
+		if total {
+			s += " {{" + unit + "}}"
+		} else {
+			s += " {" + unit + "}"
+		}
+	}
+	if p.lotdate.IsZero() == false {
+		s += " [" + p.lotdate.Format("2006/01/02") + "]"
+	}
+	if p.costprice != nil {
+		unit, total := ledgerunitprice(p.costprice, p.commodity.amount)
+		if total {
+			s += " @@ " + unit
+		} else {
+			s += " @ " + unit
+		}
+	}
+	return s
+}
+
+func ledgerunitprice(
+	price *Commodity, quantity api.Decimal) (string, bool) {
+
+	unit := ledgeramount(price)
+	if price.precision < 0 {
+		return unit, false
+	}
+	if price.amount.Round(price.precision).Cmp(price.amount) == 0 {
+		return unit, false
+	}
+	total := price.makeSimilar(price.amount.Mul(quantity.Abs()))
+	return ledgeramount(total), true
+}
+
+// ledgertags format tags and metadata as comment lines.
+func ledgertags(
+	tags []string, metadata map[string]interface{}, indent string) []string {
+
+	lines := []string{}
+	for _, tag := range tags {
+		lines = append(lines, fmt.Sprintf("%v; :%v:", indent, tag))
+	}
+	keys := []string{}
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		line := fmt.Sprintf("%v; %v: %v", indent, key, metadata[key])
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// ledgeramount format amount like Commodity.String(), quoting commodity
+// names that are not plain symbols.
+func ledgeramount(comm *Commodity) string {
+	amount := comm.formatamount()
+	if comm.noname || comm.name == "" {
+		return amount
+	}
+	name := ledgercommname(comm.name)
+	if comm.currency {
+		return name + comm.wspace + amount
+	}
+	return amount + " " + name
+}
+
+func ledgercommname(name string) string {
+	if strings.HasPrefix(name, `"`) || reledgercommodity.MatchString(name) {
+		return name
+	}
+	return `"` + name + `"`
+}
+
+func ledgerprefix(state string) string {
+	for prefix, xstate := range prefix2state {
+		if xstate == state {
+			return string(prefix)
+		}
+	}
+	return ""
+}
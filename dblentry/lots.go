@@ -0,0 +1,370 @@
+package dblentry
+
+import "fmt"
+import "time"
+import "sort"
+
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+
+const (
+	// LotFifo consume the earliest acquired lot first.
+	LotFifo = "fifo"
+	// LotLifo consume the latest acquired lot first.
+	LotLifo = "lifo"
+)
+
+// Lot of commodity acquired by an account, at a per unit price on a date.
+type Lot struct {
+	account  string
+	date     time.Time
+	quantity *Commodity // quantity remaining in this lot.
+	price    *Commodity // per unit cost of acquisition.
+}
+
+// Sale of commodity, consuming quantity from an acquired lot.
+type Sale struct {
+	account  string
+	payee    string
+	date     time.Time
+	acquired time.Time
+	quantity *Commodity // quantity sold, always positive.
+	cost     *Commodity // per unit cost of acquisition.
+	proceeds *Commodity // per unit sale price.
+	trans    *Transaction
+	posting  *Posting // posting that sold the commodity.
+}
+
+// Lotledger track acquisition lots for every account and commodity, and
+// sales that consumed them.
+type Lotledger struct {
+	lots  map[string][]*Lot // "account/commodity" -> lots in acquired order.
+	sales []*Sale
+}
+
+// NewLotledger return an empty lot ledger.
+func NewLotledger() *Lotledger {
+	return &Lotledger{lots: map[string][]*Lot{}, sales: []*Sale{}}
+}
+
+//---- exported accessors
+
+// Account that sold the commodity.
+func (sale *Sale) Account() string {
+	return sale.account
+}
+
+// Payee for the sale transaction.
+func (sale *Sale) Payee() string {
+	return sale.payee
+}
+
+// Date of sale.
+func (sale *Sale) Date() time.Time {
+	return sale.date
+}
+
+// Transaction that sold the commodity.
+func (sale *Sale) Transaction() api.Transactor {
+	return sale.trans
+}
+
+// Posting that sold the commodity, to match filter predicates.
+func (sale *Sale) Posting() api.Poster {
+	return sale.posting
+}
+
+// Acquired date of the lot consumed by this sale, can be zero if the lot
+// was not tracked and the sale posting did not mention the lot date.
+func (sale *Sale) Acquired() time.Time {
+	return sale.acquired
+}
+
+// Quantity of commodity sold.
+func (sale *Sale) Quantity() api.Commoditiser {
+	return sale.quantity
+}
+
+// Cost of acquiring the sold quantity.
+func (sale *Sale) Cost() api.Commoditiser {
+	amount := sale.cost.amount.Mul(sale.quantity.amount)
+	return sale.cost.makeSimilar(amount)
+}
+
+// Proceeds from selling the quantity.
+func (sale *Sale) Proceeds() api.Commoditiser {
+	amount := sale.proceeds.amount.Mul(sale.quantity.amount)
+	return sale.proceeds.makeSimilar(amount)
+}
+
+// Gain realised from this sale, a negative value is a loss. Return nil
+// if proceeds and cost are not in the same commodity.
+func (sale *Sale) Gain() api.Commoditiser {
+	cost, proceeds := sale.Cost(), sale.Proceeds()
+	if proceeds.Name() != cost.Name() {
+		return nil
+	}
+	return proceeds.MakeSimilar(proceeds.Amount().Sub(cost.Amount()))
+}
+
+// Holdingdays return the number of days the lot was held, return -1 if
+// acquired date is not known.
+func (sale *Sale) Holdingdays() int {
+	if sale.acquired.IsZero() {
+		return -1
+	}
+	// count calendar days, hours between the dates can be off by one
+	// across daylight saving changes.
+	y1, m1, d1 := sale.acquired.Date()
+	y2, m2, d2 := sale.date.Date()
+	acquired := time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)
+	sold := time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC)
+	return int(sold.Sub(acquired).Hours() / 24)
+}
+
+// IsLongterm return true if lot was held for more than a year.
+func (sale *Sale) IsLongterm() bool {
+	if sale.acquired.IsZero() {
+		return false
+	}
+	return sale.date.After(sale.acquired.AddDate(1, 0, 0))
+}
+
+//---- engine
+
+// apply posting on the lot ledger, positive quantities with a lot price or
+// a cost price are tracked as lots, negative quantities consume them.
+// Quantities moved between accounts, without a cost price, move the lots.
+func (ll *Lotledger) apply(db *Datastore, trans *Transaction, p *Posting) error {
+	comm := p.commodity
+	if comm == nil || comm.currency || comm.amount.IsZero() {
+		return nil
+	}
+	key := ll.key(p.account.name, comm.name)
+
+	if comm.amount.Sign() < 0 {
+		if receivers := ll.transfers(trans, p); len(receivers) > 0 {
+			ll.transfer(key, p, receivers)
+			return nil
+		}
+		return ll.consume(db, trans, p, key)
+	} else if len(ll.transfers(trans, p)) > 0 {
+		return nil // lots are moved by the sending posting.
+	}
+
+	price := p.lotprice
+	if price == nil {
+		price = p.costprice
+	}
+	if price == nil {
+		return nil
+	}
+	lot := &Lot{
+		account:  p.account.name,
+		date:     trans.Date(),
+		quantity: comm.makeSimilar(comm.amount),
+		price:    price.makeSimilar(price.amount),
+	}
+	if p.lotdate.IsZero() == false {
+		lot.date = p.lotdate
+	}
+	ll.lots[key] = append(ll.lots[key], lot)
+	return nil
+}
+
+func (ll *Lotledger) consume(
+	db *Datastore, trans *Transaction, p *Posting, key string) error {
+
+	quantity := p.commodity.amount.Neg()
+	lots := ll.selectlots(key, p)
+	for _, lot := range lots {
+		if quantity.Sign() <= 0 {
+			break
+		}
+		n := quantity
+		if lot.quantity.amount.Cmp(n) < 0 {
+			n = lot.quantity.amount
+		}
+		lot.quantity.amount = lot.quantity.amount.Sub(n)
+		quantity = quantity.Sub(n)
+		sale := ll.newsale(db, trans, p, lot.date, lot.price, n)
+		ll.sales = append(ll.sales, sale)
+	}
+	ll.prunelots(key)
+
+	if quantity.Sign() > 0 && p.lotprice != nil {
+		// lot was not tracked, go by the lot mentioned in the posting.
+		sale := ll.newsale(db, trans, p, p.lotdate, p.lotprice, quantity)
+		ll.sales = append(ll.sales, sale)
+
+	} else if quantity.Sign() > 0 && (len(lots) > 0 || p.costprice != nil) {
+		// sale, or lots exhausted, without a lot to account the gains.
+		journalfile, lineno := trans.journalfile, p.Lineno()
+		if lineno == 0 {
+			journalfile, lineno = trans.Position()
+		}
+		fmsg := "In %q:%v : no lot for %v %v sold from %v, gain not accounted\n"
+		log.Warnf(fmsg, journalfile, lineno, quantity, p.commodity.name,
+			p.account.name)
+
+	} else if quantity.Sign() > 0 {
+		fmsg := "lots not tracked for %v, %v %v not accounted\n"
+		log.Debugf(fmsg, key, quantity, p.commodity.name)
+	}
+	return nil
+}
+
+// transfers return postings, in the same transaction, that move the
+// commodity of posting p between accounts. Postings with a cost price are
+// sales or purchases, and are not transfers.
+func (ll *Lotledger) transfers(trans *Transaction, p *Posting) []*Posting {
+	postings := []*Posting{}
+	if p.costprice != nil {
+		return postings
+	}
+	sign := p.commodity.amount.Sign()
+	for _, tp := range trans.postings {
+		if tp.commodity == nil || tp.costprice != nil {
+			continue
+		} else if tp.commodity.name != p.commodity.name {
+			continue
+		} else if tp.commodity.amount.Sign() != -sign {
+			continue
+		}
+		postings = append(postings, tp)
+	}
+	return postings
+}
+
+// transfer lots consumed by posting p to receiving postings, retaining
+// their acquisition date and price.
+func (ll *Lotledger) transfer(key string, p *Posting, receivers []*Posting) {
+	quantity, moved := p.commodity.amount.Neg(), []*Lot{}
+	for _, lot := range ll.selectlots(key, p) {
+		if quantity.Sign() <= 0 {
+			break
+		}
+		n := quantity
+		if lot.quantity.amount.Cmp(n) < 0 {
+			n = lot.quantity.amount
+		}
+		lot.quantity.amount = lot.quantity.amount.Sub(n)
+		quantity = quantity.Sub(n)
+		mlot := *lot
+		mlot.quantity = lot.quantity.makeSimilar(n)
+		moved = append(moved, &mlot)
+	}
+	ll.prunelots(key)
+
+	for _, rp := range receivers {
+		rkey := ll.key(rp.account.name, rp.commodity.name)
+		want := rp.commodity.amount
+		for len(moved) > 0 && want.Sign() > 0 {
+			lot, n := moved[0], want
+			if lot.quantity.amount.Cmp(n) < 0 {
+				n = lot.quantity.amount
+			}
+			rlot := *lot
+			rlot.account = rp.account.name
+			rlot.quantity = lot.quantity.makeSimilar(n)
+			ll.lots[rkey] = append(ll.lots[rkey], &rlot)
+			lot.quantity.amount = lot.quantity.amount.Sub(n)
+			if lot.quantity.amount.Sign() == 0 {
+				moved = moved[1:]
+			}
+			want = want.Sub(n)
+		}
+		lots := ll.lots[rkey]
+		sort.SliceStable(lots, func(i, j int) bool {
+			return lots[i].date.Before(lots[j].date)
+		})
+	}
+
+	if quantity.Sign() > 0 {
+		fmsg := "lots not tracked for %v, %v %v moved without lots\n"
+		log.Debugf(fmsg, key, quantity, p.commodity.name)
+	}
+}
+
+// selectlots return lots in the order of consumption. If posting mentions
+// a lot price, and optionally lot date, only those lots are selected.
+func (ll *Lotledger) selectlots(key string, p *Posting) []*Lot {
+	lots := []*Lot{}
+	if p.lotprice != nil {
+		for _, lot := range ll.lots[key] {
+			if lot.price.name != p.lotprice.name {
+				continue
+			} else if lot.price.amount.Cmp(p.lotprice.amount) != 0 {
+				continue
+			} else if !p.lotdate.IsZero() && !p.lotdate.Equal(lot.date) {
+				continue
+			}
+			lots = append(lots, lot)
+		}
+		return lots
+	}
+
+	lots = append(lots, ll.lots[key]...)
+	if api.Options.Lotpolicy == LotLifo {
+		for i, j := 0, len(lots)-1; i < j; i, j = i+1, j-1 {
+			lots[i], lots[j] = lots[j], lots[i]
+		}
+	}
+	return lots
+}
+
+func (ll *Lotledger) prunelots(key string) {
+	lots := []*Lot{}
+	for _, lot := range ll.lots[key] {
+		if lot.quantity.amount.Sign() > 0 {
+			lots = append(lots, lot)
+		}
+	}
+	ll.lots[key] = lots
+}
+
+// newsale for quantity consumed from a lot. Sale price is the posting's
+// cost price, if not available it is looked up in the price database,
+// failing which sale is assumed at cost.
+func (ll *Lotledger) newsale(
+	db *Datastore, trans *Transaction, p *Posting,
+	acquired time.Time, cost *Commodity, quantity api.Decimal) *Sale {
+
+	sale := &Sale{
+		account:  p.account.name,
+		payee:    trans.Payee(),
+		date:     trans.Date(),
+		acquired: acquired,
+		quantity: p.commodity.makeSimilar(quantity),
+		cost:     cost.makeSimilar(cost.amount),
+		proceeds: cost.makeSimilar(cost.amount),
+		trans:    trans,
+		posting:  p,
+	}
+	unit := db.GetPrice(p.commodity.name, cost.name, sale.date)
+	if p.costprice != nil {
+		sale.proceeds = p.costprice.makeSimilar(p.costprice.amount)
+	} else if unit != nil {
+		sale.proceeds = unit.(*Commodity)
+	}
+	if name := sale.proceeds.name; name != cost.name {
+		// sold in a different commodity, convert proceeds to cost's.
+		rate := db.GetPrice(name, cost.name, sale.date)
+		if rate == nil {
+			journalfile, lineno := trans.journalfile, p.Lineno()
+			if lineno == 0 {
+				journalfile, lineno = trans.Position()
+			}
+			fmsg := "In %q:%v : no price for %v in %v, gain not accounted\n"
+			log.Warnf(fmsg, journalfile, lineno, name, cost.name)
+			return sale
+		}
+		amount := sale.proceeds.amount.Mul(rate.Amount())
+		sale.proceeds = cost.makeSimilar(amount)
+	}
+	return sale
+}
+
+func (ll *Lotledger) key(accname, commname string) string {
+	return fmt.Sprintf("%v/%v", accname, commname)
+}
@@ -0,0 +1,23 @@
+package dblentry
+
+import "time"
+import "testing"
+
+func TestHoldingdays(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	// daylight saving starts on 2024/03/10.
+	sale := &Sale{
+		acquired: time.Date(2024, 3, 9, 0, 0, 0, 0, loc),
+		date:     time.Date(2024, 3, 11, 0, 0, 0, 0, loc),
+	}
+	if x := sale.Holdingdays(); x != 2 {
+		t.Errorf("expected %v, got %v", 2, x)
+	}
+	sale.acquired = time.Time{}
+	if x := sale.Holdingdays(); x != -1 {
+		t.Errorf("expected %v, got %v", -1, x)
+	}
+}
@@ -0,0 +1,166 @@
+package dblentry
+
+import "fmt"
+import "sort"
+import "strings"
+
+import "github.com/prataprc/goparsec"
+import "github.com/tn47/goledger/api"
+
+// Parsed is a journal entry as parsed, before its firstpass, along with
+// the commodities registered with datastore while parsing the entry, and
+// the registered commodities that were changed while parsing the entry.
+// Parsed entries can be replayed on a datastore in the same parse state,
+// refer Parsestate(), instead of parsing the entry again.
+type Parsed struct {
+	node        parsec.ParsecNode
+	commodities []*Commodity
+	changed     []*Commodity
+	defaultcomm string
+}
+
+// Parse an entry by calling parse, which shall return the parsed node.
+// Return the entry as parsed, for replay.
+func (db *Datastore) Parse(parse func() parsec.ParsecNode) *Parsed {
+	states := map[string]string{}
+	for name, comm := range db.commodities {
+		states[name] = comm.parsestate()
+	}
+	node := parse()
+	parsed := &Parsed{
+		node:        cloneparsed(node),
+		commodities: []*Commodity{},
+		changed:     []*Commodity{},
+		defaultcomm: db.getDefaultcomm(),
+	}
+	for name, comm := range db.commodities {
+		state, ok := states[name]
+		if ok == false {
+			ncomm := comm.Clone(db).(*Commodity)
+			parsed.commodities = append(parsed.commodities, ncomm)
+		} else if state != comm.parsestate() {
+			ncomm := comm.Clone(db).(*Commodity)
+			parsed.changed = append(parsed.changed, ncomm)
+		}
+	}
+	return parsed
+}
+
+// Replay parsed entry on datastore, registering its commodities and
+// applying its changes to registered commodities, as if it was parsed
+// again. Return a copy of the entry for firstpass.
+func (parsed *Parsed) Replay(db *Datastore) parsec.ParsecNode {
+	for _, comm := range parsed.commodities {
+		if _, ok := db.commodities[comm.name]; ok == false {
+			db.commodities[comm.name] = comm.Clone(db).(*Commodity)
+		}
+	}
+	for _, comm := range parsed.changed {
+		if registered, ok := db.commodities[comm.name]; ok {
+			*registered = *(comm.Clone(db).(*Commodity))
+		} else {
+			db.commodities[comm.name] = comm.Clone(db).(*Commodity)
+		}
+	}
+	db.setDefaultcomm(parsed.defaultcomm)
+	return cloneparsed(parsed.node)
+}
+
+// Parsestate return a hash of datastore's state that is read while
+// parsing journal entries, current date, default commodity, defined
+// values, fixed prices and commodities. Other state, like `apply account`,
+// `alias` and `bucket`, are applied in firstpass and don't affect parsing.
+func (db *Datastore) Parsestate() uint64 {
+	items := []string{
+		db.currentDate().Format("2006-01-02"), db.getDefaultcomm(),
+	}
+	for name, value := range db.defines {
+		items = append(items, fmt.Sprintf("define %v=%v", name, value))
+	}
+	for name, price := range db.fixedprices {
+		item := fmt.Sprintf("fixed %v %v", name, price.amount)
+		items = append(items, item+" "+price.parsestate())
+	}
+	for name, comm := range db.commodities {
+		items = append(items, "commodity "+name+" "+comm.parsestate())
+	}
+	sort.Strings(items[2:])
+	return api.Crc64([]byte(strings.Join(items, "\n")))
+}
+
+// parsestate of commodity, its attributes that are read while parsing.
+func (comm *Commodity) parsestate() string {
+	return fmt.Sprintf(
+		"%v %v %v %v %q %v %v %v %v %v", comm.name, comm.currency,
+		comm.noname, comm.precision, comm.wspace, comm.mark1k,
+		comm.deccomma, comm.fixprice, comm.total, comm.nomarket)
+}
+
+// cloneparsed return a copy of node that can be mutated by firstpass,
+// without affecting node.
+func cloneparsed(node parsec.ParsecNode) parsec.ParsecNode {
+	switch v := node.(type) {
+	case *Transaction:
+		return v.cloneparsed()
+
+	case *Periodic:
+		npt := *v
+		npt.trans = v.trans.cloneparsed()
+		return &npt
+
+	case *Directive:
+		nd := *v
+		if v.fixprice != nil {
+			nd.fixprice = v.fixprice.Clone(nil).(*Commodity)
+		}
+		return &nd
+
+	case *Price:
+		nprice := *v
+		nprice.this = v.this.Clone(nil).(*Commodity)
+		nprice.other = v.other.Clone(nil).(*Commodity)
+		return &nprice
+	}
+	// comments and automated transactions are not mutated by firstpass.
+	return node
+}
+
+func (trans *Transaction) cloneparsed() *Transaction {
+	ntrans := *trans
+	ntrans.tags = append([]string{}, trans.tags...)
+	ntrans.metadata = clonemetadata(trans.metadata)
+	ntrans.notes = append([]string{}, trans.notes...)
+	ntrans.lines = append([]string{}, trans.lines...)
+	ntrans.postings = []*Posting{}
+	for _, p := range trans.postings {
+		np := *p
+		np.trans = &ntrans
+		if p.account != nil {
+			nacc := *p.account
+			np.account = &nacc
+		}
+		np.commodity = clonecommodity(p.commodity)
+		np.lotprice = clonecommodity(p.lotprice)
+		np.costprice = clonecommodity(p.costprice)
+		np.balprice = clonecommodity(p.balprice)
+		np.tags = append([]string{}, p.tags...)
+		np.metadata = clonemetadata(p.metadata)
+		ntrans.postings = append(ntrans.postings, &np)
+	}
+	return &ntrans
+}
+
+func clonecommodity(comm *Commodity) *Commodity {
+	if comm == nil {
+		return nil
+	}
+	return comm.Clone(nil).(*Commodity)
+}
+
+func clonemetadata(metadata map[string]interface{}) map[string]interface{} {
+	nmetadata := map[string]interface{}{}
+	for key, value := range metadata {
+		nmetadata[key] = value
+	}
+	return nmetadata
+}
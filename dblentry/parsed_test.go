@@ -0,0 +1,86 @@
+package dblentry
+
+import "testing"
+
+import "github.com/prataprc/goparsec"
+import "github.com/tn47/goledger/api"
+
+func TestParsedReplay(t *testing.T) {
+	db := NewDatastore("testing", nil)
+	line := "P 2004/06/21 AAPL $32.91"
+	parsed := db.Parse(func() parsec.ParsecNode {
+		scanner := parsec.NewScanner([]byte(line))
+		node, _ := NewPrice().Yledger(db)(scanner)
+		return node
+	})
+	if _, ok := parsed.node.(*Price); ok == false {
+		t.Fatalf("unable to parse %q: %v", line, parsed.node)
+	} else if len(parsed.commodities) != 2 {
+		t.Fatalf("expected 2 commodities, got %v", parsed.commodities)
+	}
+
+	// replay shall leave the datastore in the same parse state.
+	ndb := NewDatastore("testing", nil)
+	if ndb.Parsestate() == db.Parsestate() {
+		t.Errorf("expected different parse state")
+	}
+	node := parsed.Replay(ndb)
+	if x, y := ndb.Parsestate(), db.Parsestate(); x != y {
+		t.Errorf("expected %v, got %v", y, x)
+	} else if x, y := ndb.getDefaultcomm(), db.getDefaultcomm(); x != y {
+		t.Errorf("expected %q, got %q", y, x)
+	}
+	price, ok := node.(*Price)
+	if ok == false {
+		t.Fatalf("unexpected %T", node)
+	} else if price == parsed.node.(*Price) {
+		t.Errorf("expected a copy of parsed price")
+	} else if err := ndb.Firstpass(price); err != nil {
+		t.Fatal(err)
+	}
+
+	// year is read while parsing, alias is applied in firstpass.
+	state := ndb.Parsestate()
+	ndb.addAlias("Food", "Expenses:Food")
+	if x := ndb.Parsestate(); x != state {
+		t.Errorf("expected %v, got %v", state, x)
+	}
+	ndb.setYear(2010)
+	if x := ndb.Parsestate(); x == state {
+		t.Errorf("expected different parse state")
+	}
+}
+
+func TestParsedChanged(t *testing.T) {
+	db := NewDatastore("testing", nil)
+	db.addCommodity("$", NewCommodity("$"))
+	parsed := db.Parse(func() parsec.ParsecNode {
+		// entries can change the format of registered commodities.
+		comm := db.commodities["$"]
+		comm.currency, comm.precision = true, 4
+		return nil
+	})
+	if len(parsed.commodities) != 0 {
+		t.Errorf("unexpected commodities %v", parsed.commodities)
+	} else if len(parsed.changed) != 1 {
+		t.Fatalf("expected 1 changed commodity, got %v", parsed.changed)
+	}
+
+	ndb := NewDatastore("testing", nil)
+	ndb.addCommodity("$", NewCommodity("$"))
+	parsed.Replay(ndb)
+	if x, y := ndb.Parsestate(), db.Parsestate(); x != y {
+		t.Errorf("expected %v, got %v", y, x)
+	} else if comm := ndb.commodities["$"]; comm.precision != 4 {
+		t.Errorf("expected precision 4, got %v", comm.precision)
+	}
+
+	// fixed prices are read while parsing.
+	state := ndb.Parsestate()
+	price := NewCommodity("$")
+	price.amount, price.currency = api.NewDecimal(10), true
+	ndb.setFixedprice("AAPL", price)
+	if x := ndb.Parsestate(); x == state {
+		t.Errorf("expected different parse state")
+	}
+}
@@ -0,0 +1,189 @@
+package dblentry
+
+import "fmt"
+import "time"
+import "strings"
+
+import "github.com/prataprc/goparsec"
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+
+// Intervals for periodic transactions.
+const (
+	// IntervalDay recurs every day.
+	IntervalDay = "day"
+	// IntervalWeek recurs every week.
+	IntervalWeek = "week"
+	// IntervalMonth recurs every month.
+	IntervalMonth = "month"
+	// IntervalQuarter recurs every quarter.
+	IntervalQuarter = "quarter"
+	// IntervalYear recurs every year.
+	IntervalYear = "year"
+)
+
+// Periodic transaction, budgeted postings that recur on an interval.
+//
+//	~ Monthly from 2011/01
+//	    Expenses:Food         $500.00
+//	    Assets:Checking
+//
+// Period expression is parsed by the application, refer SetPeriod().
+type Periodic struct {
+	periodexpr string
+	interval   string
+	every      int
+	begin      time.Time // zero, if recurring from report's begin.
+	end        time.Time // zero, if recurring forever, exclusive.
+	trans      *Transaction
+}
+
+// NewPeriodic create a new periodic transaction.
+func NewPeriodic(journalfile string) *Periodic {
+	return &Periodic{every: 1, trans: NewTransaction(journalfile)}
+}
+
+//---- exported accessors
+
+// Periodexpr return the period expression following `~`.
+func (pt *Periodic) Periodexpr() string {
+	return pt.periodexpr
+}
+
+// SetPeriod for this periodic transaction, interval shall be one of
+// IntervalDay, IntervalWeek, IntervalMonth, IntervalQuarter, IntervalYear.
+func (pt *Periodic) SetPeriod(
+	interval string, every int, begin, end time.Time) error {
+
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalQuarter:
+	case IntervalYear:
+	default:
+		return fmt.Errorf("invalid interval %q", interval)
+	}
+	if every < 1 {
+		return fmt.Errorf("invalid interval every %v %v", every, interval)
+	}
+	pt.interval, pt.every, pt.begin, pt.end = interval, every, begin, end
+	return nil
+}
+
+// Postings budgeted for every occurrence.
+func (pt *Periodic) Postings() []api.Poster {
+	return pt.trans.GetPostings()
+}
+
+// Occurrences of this periodic transaction, on or after `from` and
+// before `till`.
+func (pt *Periodic) Occurrences(from, till time.Time) []time.Time {
+	dates := []time.Time{}
+	date := pt.begin
+	if date.IsZero() {
+		date = pt.anchor(from)
+	}
+	for ; date.Before(till); date = pt.next(date) {
+		if pt.end.IsZero() == false && date.Before(pt.end) == false {
+			break
+		} else if date.Before(from) {
+			continue
+		}
+		dates = append(dates, date)
+	}
+	return dates
+}
+
+// Forecast transaction for occurrence on date, with budgeted postings.
+func (pt *Periodic) Forecast(date time.Time) api.Transactor {
+	return pt.forecast(date)
+}
+
+//---- ledger parser
+
+// Yledger return a parser-combinator that can parse the first line of a
+// periodic transaction.
+func (pt *Periodic) Yledger(db *Datastore) parsec.Parser {
+	y := parsec.And(
+		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
+			pt.periodexpr = strings.Trim(nodes[1].(*parsec.Terminal).Value, " \t")
+			log.Debugf("periodic.yledger period:%v\n", pt.periodexpr)
+			return pt
+		},
+		ytokTilde, ytokValue,
+	)
+	return y
+}
+
+// Yledgerblock parse budgeted postings within the periodic transaction.
+func (pt *Periodic) Yledgerblock(db *Datastore, block []string) (int, error) {
+	if len(block) == 0 {
+		return 0, fmt.Errorf("periodic transaction without postings")
+	}
+	return pt.trans.Yledgerblock(db, block)
+}
+
+//---- engine
+
+func (pt *Periodic) Firstpass(db *Datastore) error {
+	if pt.interval == "" {
+		return fmt.Errorf("period %q not set", pt.periodexpr)
+	}
+	trans := pt.trans
+	if len(trans.postings) > 1 {
+		if ok, err := trans.autobalance(db, nil); err != nil {
+			return err
+		} else if ok == false {
+			return fmt.Errorf("unbalanced periodic transaction")
+		}
+	}
+	for _, p := range trans.postings {
+		accname := db.applyroot(db.lookupAlias(p.account.name))
+		p.account = NewAccount(accname)
+	}
+	db.periodics = append(db.periodics, pt)
+	return nil
+}
+
+// forecast synthesise a transaction for occurrence on date.
+func (pt *Periodic) forecast(date time.Time) *Transaction {
+	trans := NewTransaction(pt.trans.journalfile)
+	trans.date, trans.forecast = date, true
+	payee := "~ " + pt.periodexpr
+	trans.setMetadata("payee", payee)
+	trans.Addlines(fmt.Sprintf("%v %v", date.Format("2006/01/02"), payee))
+	for _, p := range pt.trans.postings {
+		np := NewPosting(trans)
+		np.account = NewAccount(p.account.name)
+		np.virtual, np.balanced = p.virtual, p.balanced
+		np.commodity = p.commodity.makeSimilar(p.commodity.amount)
+		trans.postings = append(trans.postings, np)
+	}
+	return trans
+}
+
+// anchor return the beginning of interval in which date falls.
+func (pt *Periodic) anchor(date time.Time) time.Time {
+	year, month, day := date.Date()
+	switch pt.interval {
+	case IntervalMonth:
+		day = 1
+	case IntervalQuarter:
+		month, day = month-((month-1)%3), 1
+	case IntervalYear:
+		month, day = time.January, 1
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, date.Location())
+}
+
+func (pt *Periodic) next(date time.Time) time.Time {
+	switch pt.interval {
+	case IntervalDay:
+		return date.AddDate(0, 0, pt.every)
+	case IntervalWeek:
+		return date.AddDate(0, 0, 7*pt.every)
+	case IntervalMonth:
+		return date.AddDate(0, pt.every, 0)
+	case IntervalQuarter:
+		return date.AddDate(0, 3*pt.every, 0)
+	}
+	return date.AddDate(pt.every, 0, 0)
+}
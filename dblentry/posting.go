@@ -38,6 +38,8 @@ type Posting struct {
 	tags     []string
 	metadata map[string]interface{}
 	note     string
+	lineoff  int  // offset from transaction's first line, zero if implied.
+	elided   bool // amount computed while balancing the transaction.
 }
 
 // NewPosting create a new posting instance.
@@ -100,6 +102,40 @@ func (p *Posting) Payee() string {
 	return payee.(string)
 }
 
+// Transaction return the transaction containing this posting.
+func (p *Posting) Transaction() *Transaction {
+	return p.trans
+}
+
+// Lineno return the line number of this posting in its journal file, zero
+// if posting is implied, like postings injected by automated transaction.
+func (p *Posting) Lineno() int {
+	if p.lineoff == 0 {
+		return 0
+	}
+	return p.trans.lineno - len(p.trans.lines) + 1 + p.lineoff
+}
+
+func (p *Posting) IsVirtual() bool {
+	return p.virtual
+}
+
+func (p *Posting) State() string {
+	if state := p.getState(); state != "" {
+		return state
+	}
+	return PostUncleared
+}
+
+func (p *Posting) Tags() []string {
+	tags := append([]string{}, p.tags...)
+	return append(tags, p.trans.tags...)
+}
+
+func (p *Posting) Metadata(key string) interface{} {
+	return p.getMetadata(strings.ToLower(key))
+}
+
 func (p *Posting) IsCredit() bool {
 	if p.commodity == nil {
 		panic("impossible situation")
@@ -182,12 +218,16 @@ func (p *Posting) Yledger(db *Datastore) parsec.Parser {
 				if err != nil {
 					return err
 				}
+				// from here on lot price and cost price are per unit price.
+				p.lotprice = p.unitprice(p.lotprice)
+				p.costprice = p.unitprice(p.costprice)
 
 				if p.lotprice != nil && lotprice.currency == false {
 					return fmt.Errorf("lot price must be currency")
 				} else if p.costprice != nil && costprice.currency == false {
 					return fmt.Errorf("cost price must be currency")
 				}
+				p.fixedprice(db)
 				if x, y := p.balprice, p.commodity; x != nil && y != nil {
 					if x.name != y.name {
 						fmsg := "balance-commodity(%v) != posting-commodity(%v)"
@@ -247,7 +287,9 @@ func (p *Posting) fixaccount(
 func (p *Posting) fixcommodity(
 	db *Datastore, item interface{}) (*Commodity, error) {
 
-	if commodity, ok := item.(*Commodity); ok {
+	if err, ok := item.(error); ok {
+		return nil, err
+	} else if commodity, ok := item.(*Commodity); ok {
 		cname := commodity.name
 		c := db.getCommodity(cname, commodity).makeSimilar(commodity.amount)
 		return c, nil
@@ -294,22 +336,47 @@ func (p *Posting) fixbalprice(
 	return nil, nil
 }
 
+// unitprice convert total price, like {{...}} and @@, to per unit price.
+func (p *Posting) unitprice(price *Commodity) *Commodity {
+	if price == nil || price.isTotal() == false {
+		return price
+	} else if p.commodity == nil || p.commodity.amount.IsZero() {
+		return price
+	}
+	unit := price.makeSimilar(price.amount.Quo(p.commodity.amount.Abs()))
+	unit.total = false
+	return unit
+}
+
+// fixedprice apply price from `fixed` directive, if posting's commodity
+// is fixed, as its lot price and as its cost price.
+func (p *Posting) fixedprice(db *Datastore) {
+	if p.commodity == nil {
+		return
+	}
+	price := db.getFixedprice(p.commodity.name)
+	if price == nil {
+		return
+	}
+	if p.lotprice == nil {
+		p.lotprice = price.makeSimilar(price.amount)
+	}
+	if p.costprice == nil {
+		p.costprice = price.makeSimilar(price.amount)
+	}
+}
+
 func (p *Posting) getCostprice() *Commodity {
 	checkdebit := p.IsDebit() && p.commodity.currency == false
 	if checkdebit && p.costprice != nil {
-		if p.costprice.isTotal() { // first compute per unit price
-			p.costprice.amount /= p.commodity.amount
-		}
-		return p.costprice.makeSimilar(p.commodity.amount * p.costprice.amount)
-
+		amount := p.commodity.amount.Mul(p.costprice.amount)
+		return p.costprice.makeSimilar(amount)
 	}
 
 	checkcredit := p.IsCredit() && p.commodity.currency == false
 	if checkcredit && p.lotprice != nil {
-		if p.lotprice.isTotal() { // first compute per unit price
-			p.lotprice.amount /= p.commodity.amount
-		}
-		return p.lotprice.makeSimilar(p.commodity.amount * p.lotprice.amount)
+		amount := p.commodity.amount.Mul(p.lotprice.amount)
+		return p.lotprice.makeSimilar(amount)
 	}
 
 	return p.commodity.makeSimilar(p.commodity.amount)
@@ -357,23 +424,74 @@ func (p *Posting) Firstpass(db *Datastore, trans *Transaction) error {
 		return err
 	}
 
+	if price := p.marketprice(db, trans); price != nil {
+		db.addPrice(price)
+	}
+
 	db.reporter.Firstpass(db, trans, p)
 
 	return nil
 }
 
+// marketprice return the price implied by posting's cost price, or
+// lot price of an acquisition, for one unit of posting's commodity.
+func (p *Posting) marketprice(db *Datastore, trans *Transaction) *Price {
+	if p.commodity == nil || p.commodity.currency {
+		return nil
+	}
+	if comm, ok := db.commodities[p.commodity.name]; ok && comm.nomarket {
+		return nil
+	}
+
+	unit := p.commodity.makeSimilar(api.NewDecimal(1))
+	price := &Price{when: trans.Date(), this: unit}
+	if p.costprice != nil {
+		price.other = p.costprice.makeSimilar(p.costprice.amount)
+	} else if p.lotprice != nil && p.commodity.amount.Sign() > 0 {
+		// lot price on a sale is the cost of acquisition, not market.
+		price.other = p.lotprice.makeSimilar(p.lotprice.amount)
+		if p.lotdate.IsZero() == false {
+			price.when = p.lotdate
+		}
+	} else {
+		return nil
+	}
+	if price.Firstpass(db) != nil {
+		return nil
+	}
+	return price
+}
+
 func (p *Posting) Secondpass(db *Datastore, trans *Transaction) error {
-	db.addBalance(p.commodity)
-	p.account.setPosting()
+	checking, reporting := db.checking(), db.reporting(p)
 
-	if err := p.account.Secondpass(db, trans, p); err != nil {
-		return err
+	if checking || reporting {
+		db.addBalance(p.commodity)
+		p.account.setPosting()
+	}
+	if checking {
+		if err := p.account.Secondpass(db, trans, p); err != nil {
+			return err
+		}
+	} else if reporting {
+		if err := p.account.de.AddBalance(p.commodity); err != nil {
+			return err
+		}
 	}
 	if err := p.commodity.Secondpass(db, trans, p); err != nil {
 		return err
 	}
+	// lots are consumed by every posting, no matter the filter.
+	if db.verifying == false {
+		if err := db.lotledger.apply(db, trans, p); err != nil {
+			return err
+		}
+	}
 
-	return db.reporter.Posting(db, trans, p)
+	if reporting {
+		return db.reporter.Posting(db, trans, p)
+	}
+	return nil
 }
 
 func (p *Posting) Clone(ndb *Datastore, ntrans *Transaction) *Posting {
@@ -399,28 +517,28 @@ func (p *Posting) Clone(ndb *Datastore, ntrans *Transaction) *Posting {
 
 func (p *Posting) FmtBalances(
 	db api.Datastorer, trans api.Transactor, _ api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
 	panic("not supported")
 }
 
 func (p *Posting) FmtRegister(
 	db api.Datastorer, trans api.Transactor, _ api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
 	panic("not supported")
 }
 
 func (p *Posting) FmtEquity(
 	db api.Datastorer, trans api.Transactor, _ api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
 	panic("not supported")
 }
 
 func (p *Posting) FmtPassbook(
 	db api.Datastorer, trans api.Transactor, _ api.Poster,
-	_ api.Accounter) [][]string {
+	_ api.Accounter) [][]interface{} {
 
 	panic("not supported")
 }
@@ -1,14 +1,19 @@
 package dblentry
 
+import "fmt"
 import "time"
 
 import "github.com/prataprc/goparsec"
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
 
-// Price equivalence between commodities, TBD...
+// Price equivalence between commodities, as on a given date. One unit of
+// `this` commodity is worth `other` commodity.
 type Price struct {
-	when  time.Time
-	this  *Commodity
-	other *Commodity
+	when   time.Time
+	this   *Commodity
+	other  *Commodity
+	quoted bool // from price directive, not from posting's cost.
 }
 
 // NewPrice return a new Price instance.
@@ -17,6 +22,23 @@ func NewPrice() *Price {
 	return price
 }
 
+//---- exported accessors
+
+// Date on which the price was quoted.
+func (price *Price) Date() time.Time {
+	return price.when
+}
+
+// This return the commodity being priced, its amount is always 1.
+func (price *Price) This() api.Commoditiser {
+	return price.this
+}
+
+// Other return the per unit price of This() commodity.
+func (price *Price) Other() api.Commoditiser {
+	return price.other
+}
+
 //---- ledger parser
 
 // Yledger return a parser-combinator that can parse a price directive.
@@ -25,18 +47,34 @@ func (price *Price) Yledger(db *Datastore) parsec.Parser {
 
 	y := parsec.And(
 		func(nodes []parsec.ParsecNode) parsec.ParsecNode {
-			price.when = nodes[1].(time.Time)
-			// TODO: Validate date as well.
-			//price.this = NewCommodity("")
-			//price.this.name = string(nodes[2].(*parsec.Terminal).Value)
-			//price.this.amount = 1
-			//price.other = nodes[3].(*Commodity)
+			if err, ok := nodes[1].(error); ok {
+				return err
+			}
+			price.when, price.quoted = nodes[1].(time.Time), true
+
+			other := nodes[3].(*Commodity)
+			// price directive shall not pick the default commodity.
+			if other.name == "" {
+				price.other = db.getCommodity(other.name, other)
+			} else {
+				price.other = db.addCommodity(other.name, other)
+			}
+			price.other = price.other.makeSimilar(other.amount)
+			// quotes are as precise as they are declared.
+			price.other.precision = other.precision
+
+			name := nodes[2].(*parsec.Terminal).Value
+			price.this = db.addCommodity(name, NewCommodity(name))
+			price.this = price.this.makeSimilar(api.NewDecimal(1))
+
+			fmsg := "price.yledger date:%v %v %v\n"
+			log.Debugf(fmsg, price.when, price.this.name, price.other)
 			return price
 		},
 		ytokPrice,           // P
 		Ydate(db.getYear()), // DATE
-		ytokCommodity,       // SYMBOL
-		comm.Yledger(db),
+		comm.Yname(db),      // SYMBOL
+		comm.Yledger(db),    // AMOUNT
 	)
 	return y
 }
@@ -44,6 +82,13 @@ func (price *Price) Yledger(db *Datastore) parsec.Parser {
 //---- Engine
 
 func (price *Price) Firstpass(db *Datastore) error {
+	if price.this.name == price.other.name {
+		fmsg := "price of %q cannot be quoted in itself"
+		return fmt.Errorf(fmsg, price.this.name)
+	} else if price.other.amount.Sign() <= 0 {
+		fmsg := "price of %q should be positive, got %v"
+		return fmt.Errorf(fmsg, price.this.name, price.other)
+	}
 	return nil
 }
 
@@ -57,3 +102,19 @@ func (price *Price) Clone(ndb *Datastore) *Price {
 	nprice.other = price.other.Clone(ndb).(*Commodity)
 	return &nprice
 }
+
+// quote return the per unit price of commodity `this` in commodity `other`,
+// if this price entry can answer the same.
+func (price *Price) quote(this, other string) (*Commodity, bool) {
+	if price.this.name == this && price.other.name == other {
+		return price.other.makeSimilar(price.other.amount), true
+
+	} else if price.this.name == other && price.other.name == this {
+		// inverse quote, price of `other` in `this`.
+		unit := api.NewDecimal(1).Quo(price.other.amount)
+		comm := price.this.makeSimilar(unit)
+		comm.precision = price.other.precision
+		return comm, true
+	}
+	return nil, false
+}
@@ -0,0 +1,58 @@
+package dblentry
+
+import "fmt"
+import "time"
+import "testing"
+
+import "github.com/prataprc/goparsec"
+
+var _ = fmt.Sprintf("dummy")
+
+func TestPriceDB(t *testing.T) {
+	db := NewDatastore("testing", nil)
+	lines := []string{
+		"P 2004/06/21 02:18:02 AAPL $32.91",
+		"P 2004/06/21 02:18:02 EUR $1.26",
+		"P 2004/07/01 AAPL $33.50",
+		"P 2004/08/01 \"VANGUARD FUND\" 12.3456 EUR",
+	}
+	for _, line := range lines {
+		scanner := parsec.NewScanner([]byte(line))
+		node, _ := NewPrice().Yledger(db)(scanner)
+		price, ok := node.(*Price)
+		if ok == false {
+			t.Fatalf("unable to parse %q: %v", line, node)
+		}
+		if err := db.Firstpass(price); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// price directives shall not pick the default commodity.
+	if x := db.getDefaultcomm(); x != "" {
+		t.Errorf("unexpected default commodity %q", x)
+	}
+
+	date := func(y, m, d int) time.Time {
+		return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.Local)
+	}
+	testcases := [][]interface{}{
+		{"AAPL", "$", date(2004, 6, 20), ""},
+		{"AAPL", "$", date(2004, 6, 22), "$32.91"},
+		{"AAPL", "$", date(2004, 7, 1), "$33.50"},
+		{"AAPL", "$", date(2005, 1, 1), "$33.50"},
+		{"AAPL", "EUR", date(2005, 1, 1), ""},
+		{"$", "EUR", date(2005, 1, 1), "0.79 EUR"},
+		{"\"VANGUARD FUND\"", "EUR", date(2004, 8, 1), "12.3456 EUR"},
+	}
+	for _, tcase := range testcases {
+		this, other := tcase[0].(string), tcase[1].(string)
+		comm := db.GetPrice(this, other, tcase[2].(time.Time))
+		out := ""
+		if comm != nil {
+			out = comm.String()
+		}
+		if ref := tcase[3].(string); out != ref {
+			t.Errorf("%v in %v, expected %q, got %q", this, other, ref, out)
+		}
+	}
+}
@@ -5,11 +5,12 @@ import "github.com/prataprc/goparsec"
 var ytokHardSpace = parsec.TokenExact(` {2}|\t`, "HARDSPACE")
 var ytokWhitespace = parsec.TokenExact(`[ ]*`, "WHITESPACE")
 var ytokEqual = parsec.Atom("=", "EQUAL")
+var ytokTilde = parsec.Atom("~", "TILDE")
 var ytokCurrency = parsec.Token(`[^0-9 \t\r\n.,;:?!/@+*/^&|=<>(){}\[\]-]+`, "CURRENCY")
 var ytokAmount = parsec.Token(`[0-9,.-]+`, "AMOUNT")
 var ytokCommodity = parsec.Token(`[^0-9 \t\r\n.,;:?!/@+*/^&|=<>(){}\[\]-]+`, "COMMODITY")
-var ytokAssert = parsec.Atom("assert", "ASSERT")
-var ytokExpr = parsec.Token(`\{.*\}`, "EXPRESSION")
+var ytokAssert = parsec.Atom("assert", "DRTV_ACCOUNT_ASSERT")
+var ytokExpr = parsec.Token(`.+`, "EXPRESSION")
 var ytokYearval = parsec.Token(`[0-9]{4}`, "YEAR")
 var ytokCommentchar = parsec.Token(`[;|#*]`, "COMMENTCHAR")
 var ytokCommentline = parsec.Token(`.*`, "COMMENTLINE")
@@ -42,7 +43,7 @@ var ytokClosesqrt = parsec.Atom(`]`, "CLOSESQRT")
 
 //---- Price tokens
 
-var ytokPrice = parsec.Token(";[^;]+", "TRANSPRICE")
+var ytokPrice = parsec.Atom("P", "PRICE")
 
 //---- Directives
 var ytokAccount = parsec.Atom("account", "DRTV_ACCOUNT")
@@ -65,6 +66,8 @@ var ytokNomarket = parsec.Atom("nomarket", "DRTV_COMMODITY_NOMARKET")
 var ytokCommCurrency = parsec.Atom("currency", "DRTV_COMMODITY_CURRENCY")
 var ytokDirtDefine = parsec.Atom("define", "DRTV_DEFINE")
 var ytokDirtFixed = parsec.Atom("fixed", "DRTV_FIXED")
+var ytokDirtEndfixed = parsec.Atom("endfixed", "DRTV_ENDFIXED")
+var ytokDefname = parsec.Token(`[a-zA-Z_][a-zA-Z0-9_]*`, "DRTV_DEFNAME")
 var ytokDirtInclude = parsec.Atom("include", "DRTV_FIXED")
 var ytokPayeeAlias = parsec.Atom("alias", "DRTV_PAYEE_ALIAS")
 var ytokPayeeUuid = parsec.Atom("uuid", "DRTV_PAYEE_UUID")
@@ -77,7 +80,7 @@ var ytokDefault = parsec.Atom("default", "DRTV_DEFAULT")
 
 // tags
 var ytokColon = parsec.Atom(":", "COLON")
-var ytokTag = parsec.Token(":[^ \t\r\n]*", "TAG")
+var ytokTag = parsec.Token(":[^ \t\r\n:]+", "TAG")
 var ytokTagK = parsec.Token("[^ \t\r\n]+:[ \t]", "TAGKEY")
 var ytokTagV = parsec.Token(".+", "TAGVALUE")
 
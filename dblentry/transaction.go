@@ -16,11 +16,13 @@ type Transaction struct {
 	date        time.Time
 	edate       time.Time
 	code        string
+	desc        string
 	tags        []string
 	metadata    map[string]interface{}
 	notes       []string
 	lineno      int
 	lines       []string
+	forecast    bool // synthesised from periodic transaction.
 
 	postings []*Posting
 }
@@ -70,6 +72,26 @@ func (trans *Transaction) Lineno() int {
 	return trans.lineno
 }
 
+// IsForecast return true if transaction is synthesised from a periodic
+// transaction, refer Datastore.Forecast().
+func (trans *Transaction) IsForecast() bool {
+	return trans.forecast
+}
+
+// Position return the journal file and line number where this transaction
+// starts.
+func (trans *Transaction) Position() (string, int) {
+	return trans.journalfile, trans.lineno - len(trans.lines) + 1
+}
+
+// Uuid return transaction's `uuid` metadata, empty string if not tagged.
+func (trans *Transaction) Uuid() string {
+	if uuid, ok := trans.getMetadata("uuid").(string); ok {
+		return uuid
+	}
+	return ""
+}
+
 func (trans *Transaction) Addlines(lines ...string) {
 	trans.lines = append(trans.lines, lines...)
 }
@@ -78,6 +100,23 @@ func (trans *Transaction) Printlines() []string {
 	return trans.lines
 }
 
+// Reallines return Printlines without the lines of virtual postings.
+func (trans *Transaction) Reallines() []string {
+	virtuals := map[int]bool{}
+	for _, p := range trans.postings {
+		if p.virtual && p.lineoff > 0 {
+			virtuals[p.lineoff] = true
+		}
+	}
+	lines := []string{}
+	for i, line := range trans.lines {
+		if virtuals[i] == false {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
 //---- api.Transactor methods.
 
 func (trans *Transaction) Date() time.Time {
@@ -92,6 +131,14 @@ func (trans *Transaction) Payee() string {
 	return ""
 }
 
+func (trans *Transaction) Description() string {
+	return trans.desc
+}
+
+func (trans *Transaction) Code() string {
+	return trans.code
+}
+
 func (trans *Transaction) GetPostings() []api.Poster {
 	postings := []api.Poster{}
 	for _, p := range trans.postings {
@@ -112,6 +159,14 @@ func (trans *Transaction) Journalfile() string {
 	return trans.journalfile
 }
 
+func (trans *Transaction) Tags() []string {
+	return trans.tags
+}
+
+func (trans *Transaction) Metadata(key string) interface{} {
+	return trans.getMetadata(strings.ToLower(key))
+}
+
 //---- ledger parser
 
 // Yledger return a parser-combinator that can parse first line of a
@@ -149,6 +204,7 @@ func (trans *Transaction) Yledger(db *Datastore) parsec.Parser {
 			}
 
 			payee := string(nodes[4].(*parsec.Terminal).Value)
+			trans.desc = payee
 			trans.setMetadata("payee", payee)
 
 			if t, ok := nodes[5].(*parsec.Terminal); ok {
@@ -189,6 +245,7 @@ func (trans *Transaction) Yledgerblock(
 		node, scanner = posting.Yledger(db)(scanner)
 		switch val := node.(type) {
 		case *Posting:
+			val.lineoff = index + 1
 			trans.postings = append(trans.postings, val)
 
 		case *Tags:
@@ -221,16 +278,20 @@ func (trans *Transaction) Firstpass(db *Datastore) error {
 		trans.setMetadata("payee", payee)
 	}
 
-	if trans.shouldBalance() {
-		defaccount := db.GetAccount(db.getBalancingaccount()).(*Account)
-		if ok, err := trans.autobalance1(db, defaccount); err != nil {
+	for _, auto := range db.automated {
+		if err := auto.apply(db, trans); err != nil {
 			return err
-		} else if ok == false {
-			return fmt.Errorf("unbalanced transaction")
 		}
-		log.Debugf("transaction balanced\n")
 	}
 
+	defaccount := db.GetAccount(db.getBalancingaccount()).(*Account)
+	if ok, err := trans.autobalance(db, defaccount); err != nil {
+		return err
+	} else if ok == false {
+		return fmt.Errorf("unbalanced transaction")
+	}
+	log.Debugf("transaction balanced\n")
+
 	for _, posting := range trans.postings {
 		if err := posting.Firstpass(db, trans); err != nil {
 			return err
@@ -246,6 +307,9 @@ func (trans *Transaction) Secondpass(db *Datastore) error {
 			return fmt.Errorf("secondpass lineno %v: %v", trans.lineno, err)
 		}
 	}
+	if db.verifying {
+		return nil
+	}
 	return db.reporter.Transaction(db, trans)
 }
 
@@ -258,17 +322,56 @@ func (trans *Transaction) Clone(ndb *Datastore) *Transaction {
 	return &ntrans
 }
 
-func (trans *Transaction) shouldBalance() bool {
-	for _, posting := range trans.postings {
-		virtual := posting.isVirtual()
-		balanced := posting.isBalanced()
-		if virtual == true && balanced == false {
-			return false
-		} else if balanced == false {
-			return false
+// balancing return postings that shall balance along with posting, real
+// postings balance among themselves and so do virtual postings in
+// brackets. Virtual postings in parenthesis don't balance.
+func (trans *Transaction) balancing(posting *Posting) []*Posting {
+	if posting.virtual && posting.balanced == false {
+		return nil
+	}
+	postings := []*Posting{}
+	for _, p := range trans.postings {
+		if p.virtual == posting.virtual && p.balanced == posting.balanced {
+			postings = append(postings, p)
 		}
 	}
-	return true
+	return postings
+}
+
+// autobalance real postings and bracketed virtual postings, return false
+// if real postings don't balance.
+func (trans *Transaction) autobalance(
+	db *Datastore, defaccount *Account) (bool, error) {
+
+	if len(trans.postings) == 0 {
+		return false, fmt.Errorf("empty transaction")
+	}
+	var real, bracketed []*Posting
+	for _, p := range trans.postings {
+		if p.virtual == false && real == nil {
+			real = trans.balancing(p)
+		} else if p.virtual && p.balanced && bracketed == nil {
+			bracketed = trans.balancing(p)
+		} else if p.virtual && p.balanced == false && p.commodity == nil {
+			fmsg := "virtual posting %q without amount"
+			return false, fmt.Errorf(fmsg, p.account.name)
+		}
+	}
+	if real != nil {
+		if ok, err := trans.autobalance1(db, defaccount, real); err != nil {
+			return false, err
+		} else if ok == false {
+			return false, nil
+		}
+	}
+	if bracketed != nil {
+		if ok, err := trans.autobalance1(db, nil, bracketed); err != nil {
+			return false, fmt.Errorf("virtual postings: %v", err)
+		} else if ok == false {
+			return false, fmt.Errorf("unbalanced virtual postings")
+		}
+	}
+	return true, nil
 }
 
 func (trans *Transaction) defaultposting(
@@ -294,34 +397,35 @@ func (trans *Transaction) endposting(postings []*Posting) (*Posting, error) {
 }
 
 func (trans *Transaction) autobalance1(
-	db *Datastore, defaccount *Account) (bool, error) {
+	db *Datastore, defaccount *Account, postings []*Posting) (bool, error) {
 
-	if len(trans.postings) == 0 {
+	if len(postings) == 0 {
 		return false, fmt.Errorf("empty transaction")
 
-	} else if len(trans.postings) == 1 && defaccount != nil {
-		commodity := trans.postings[0].getCostprice()
+	} else if len(postings) == 1 && defaccount != nil {
+		commodity := postings[0].getCostprice()
 		posting := trans.defaultposting(db, defaccount, commodity)
 		posting.commodity.doInverse()
+		posting.elided = true
 		trans.postings = append(trans.postings, posting)
 		return true, nil
 
-	} else if len(trans.postings) == 1 {
+	} else if len(postings) == 1 {
 		return false, fmt.Errorf("unbalanced transaction")
 	}
 
-	tallypost, err := trans.endposting(trans.postings)
+	tallypost, err := trans.endposting(postings)
 	if err != nil {
 		return false, err
 	}
 
-	unbcs, _ := trans.doBalance()
+	unbcs, _ := trans.doBalance(postings)
 	if len(unbcs) == 0 && tallypost == nil {
 		return true, nil
 
 	} else if len(unbcs) == 0 && tallypost != nil {
-		comm := db.GetCommodity(db.getDefaultcomm()).MakeSimilar(0)
-		tallypost.commodity = comm.(*Commodity)
+		comm := db.GetCommodity(db.getDefaultcomm()).MakeSimilar(api.Decimal{})
+		tallypost.commodity, tallypost.elided = comm.(*Commodity), true
 		return true, nil
 	}
 
@@ -331,26 +435,40 @@ func (trans *Transaction) autobalance1(
 		return true, nil
 	}
 
-	tallypost.commodity = unbcs[0]
+	tallypost.commodity, tallypost.elided = unbcs[0], true
 	tallypost.commodity.doInverse()
 	if len(unbcs) > 1 {
 		account := tallypost.account
 		for _, unbc := range unbcs[1:] {
 			posting := trans.defaultposting(db, account, unbc)
 			posting.commodity.doInverse()
+			posting.elided = true
 			trans.postings = append(trans.postings, posting)
 		}
 	}
 	return true, nil
 }
 
-func (trans *Transaction) doBalance() ([]*Commodity, bool) {
+// doBalance return commodities that don't balance in postings, along with
+// their imbalance. Imbalance is exact, but is ignored if it rounds to zero
+// in commodity's precision, or in the precision of the amounts written for
+// the commodity, whichever is larger.
+func (trans *Transaction) doBalance(
+	postings []*Posting) ([]*Commodity, bool) {
+
 	unbalanced := map[string]*Commodity{}
-	for _, posting := range trans.postings {
+	precisions := map[string]int{}
+	for _, posting := range postings {
 		if posting.commodity == nil {
 			continue
 		}
 		commodity := posting.getCostprice()
+		precision := precisions[commodity.name]
+		precision = api.Maxints(precision, commodity.precision)
+		if commodity.name == posting.commodity.name {
+			precision = api.Maxints(precision, commodity.amount.Precision())
+		}
+		precisions[commodity.name] = precision
 		unbc, ok := unbalanced[commodity.name]
 		if ok {
 			unbc.doAdd(commodity)
@@ -368,9 +486,7 @@ func (trans *Transaction) doBalance() ([]*Commodity, bool) {
 	unbcs := []*Commodity{}
 	for _, name := range commnames {
 		unbc := unbalanced[name]
-		// it is supposed to be unbc.amount == 0.0
-		// issue #38
-		if unbc.amount > float64(0.009) || unbc.amount < -float64(0.009) {
+		if unbc.amount.Round(precisions[name]).IsZero() == false {
 			unbcs = append(unbcs, unbc)
 		}
 	}
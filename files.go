@@ -3,6 +3,7 @@ package main
 import "os"
 import "fmt"
 import "path"
+import "time"
 import "bufio"
 import "strings"
 import "io/ioutil"
@@ -95,3 +96,30 @@ func parentpaths(dirpath string, acc []string) (dirs []string) {
 	}
 	return acc
 }
+
+// modtimes return modification time of files, missing files are
+// mapped to zero time.
+func modtimes(files []string) map[string]time.Time {
+	mtimes := map[string]time.Time{}
+	for _, file := range files {
+		mtimes[file] = time.Time{}
+		if info, err := os.Stat(file); err == nil {
+			mtimes[file] = info.ModTime()
+		}
+	}
+	return mtimes
+}
+
+// modified return true if any of the files have changed since mtimes.
+func modified(mtimes map[string]time.Time) bool {
+	files := []string{}
+	for file := range mtimes {
+		files = append(files, file)
+	}
+	for file, mtime := range modtimes(files) {
+		if mtime.Equal(mtimes[file]) == false {
+			return true
+		}
+	}
+	return false
+}
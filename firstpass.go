@@ -28,8 +28,6 @@ func dofirstpass(
 	db.Addjournal(journalfile, data)
 
 	var lineno int
-	var block []string
-	var eof bool
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -42,22 +40,36 @@ func dofirstpass(
 	log.Debugf("firstpass %v\n", journalfile)
 	var node parsec.ParsecNode
 
-	lines, err := readlines(journalfile)
-	if err != nil {
-		return err
-	}
-
-	iterate := blockiterate(lines)
-	lineno, block, eof, err = iterate()
-	for len(block) > 0 {
-		lineno -= len(block)
-		if err != nil {
-			log.Errorf("iterate at %q:%v : %v\n", journalfile, lineno, err)
+	hash, state := api.Crc64(data), db.Parsestate()
+	cached := jcache.get(journalfile, hash, state)
+	if cached == nil {
+		if cached, err = readblocks(journalfile); err != nil {
 			return err
 		}
-		log.Debugf("parsing block: %v\n", block[0])
+		cached.hash, cached.state = hash, state
+	} else {
+		log.Debugf("replaying cached %v\n", journalfile)
+	}
 
-		node, lineno, err = parseentry(lineno, block, journalfile, db)
+	ncached := *cached
+	ncached.entries = []*cachedentry{}
+	reparse := false // parse state has changed after an include.
+	for _, entry := range cached.entries {
+		lineno = entry.begin
+		block := entry.block
+		if entry.parsed != nil && reparse == false {
+			node = entry.parsed.Replay(db)
+			entry = &cachedentry{
+				begin: entry.begin, end: entry.end, block: block,
+				parsed: entry.parsed, state: entry.state,
+			}
+		} else {
+			log.Debugf("parsing block: %v\n", block[0])
+			entry = &cachedentry{begin: lineno, block: block}
+			node, entry.end, entry.parsed, err =
+				parsecached(lineno, block, journalfile, db)
+		}
+		lineno = entry.end
 		if err != nil {
 			log.Errorf("parsec at %q:%v : %v\n", journalfile, lineno, err)
 			return err
@@ -69,17 +81,61 @@ func dofirstpass(
 			}
 		}
 
-		tryinclude(reporter, db, node, journalfile)
-		lineno, block, eof, err = iterate()
+		if tryinclude(reporter, db, node, journalfile) {
+			state := db.Parsestate()
+			reparse = reparse || entry.state != state
+			entry.state = state
+		}
+		ncached.entries = append(ncached.entries, entry)
 	}
-	if err != nil {
-		log.Errorf("%q : %v\n", journalfile, err)
-	} else if eof == false {
+	if cached.err != nil {
+		log.Errorf("%q : %v\n", journalfile, cached.err)
+	} else if cached.eof == false {
 		log.Errorf("%q : expected eof\n", journalfile)
 	}
+	jcache.put(journalfile, &ncached)
 	return nil
 }
 
+// readblocks from journal file, blocks are parsed by dofirstpass.
+func readblocks(journalfile string) (*cachedjournal, error) {
+	lines, err := readlines(journalfile)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := &cachedjournal{entries: []*cachedentry{}}
+	iterate := blockiterate(lines)
+	lineno, block, eof, err := iterate()
+	for len(block) > 0 {
+		entry := &cachedentry{begin: lineno - len(block), block: block}
+		cached.entries = append(cached.entries, entry)
+		lineno, block, eof, err = iterate()
+	}
+	cached.err, cached.eof = err, eof
+	return cached, nil
+}
+
+// parsecached parse block as journal entry, if journals are cached return
+// the entry as parsed for replay.
+func parsecached(
+	lineno int,
+	block []string,
+	journalfile string,
+	db *dblentry.Datastore) (
+	node parsec.ParsecNode, end int, parsed *dblentry.Parsed, err error) {
+
+	if jcache == nil {
+		node, end, err = parseentry(lineno, block, journalfile, db)
+		return node, end, nil, err
+	}
+	parsed = db.Parse(func() parsec.ParsecNode {
+		node, end, err = parseentry(lineno, block, journalfile, db)
+		return node
+	})
+	return node, end, parsed, err
+}
+
 func parseentry(
 	lineno int,
 	block []string,
@@ -92,11 +148,13 @@ func parseentry(
 	scanner := parsec.NewScanner([]byte(block[0]))
 	ytrans := dblentry.NewTransaction(journalfile).Yledger(db)
 	yprice := dblentry.NewPrice().Yledger(db)
+	yautomated := dblentry.NewAutomated().Yledger(db)
+	yperiodic := dblentry.NewPeriodic(journalfile).Yledger(db)
 	ydirective := dblentry.NewDirective().Yledger(db)
 	ycomment := dblentry.NewComment().Yledger(db)
 	y := parsec.OrdChoice(
 		dblentry.Vector2scalar,
-		ytrans, yprice, ydirective, ycomment,
+		ytrans, yprice, yautomated, yperiodic, ydirective, ycomment,
 	)
 	node, _ := y(scanner)
 	switch obj := node.(type) {
@@ -112,6 +170,19 @@ func parseentry(
 		obj.Addlines(block[1:]...)
 
 	case *dblentry.Directive:
+		obj.SetPosition(journalfile, lineno)
+		index, err = obj.Yledgerblock(db, block[1:])
+		lineno += 1 + index
+
+	case *dblentry.Automated:
+		obj.SetPosition(journalfile, lineno)
+		index, err = obj.Yledgerblock(db, block[1:])
+		lineno += 1 + index
+
+	case *dblentry.Periodic:
+		if err = setperiod(obj, db.CurrentYear()); err != nil {
+			break
+		}
 		index, err = obj.Yledgerblock(db, block[1:])
 		lineno += 1 + index
 
@@ -178,6 +249,14 @@ func blockiterate(lines []string) func() (int, []string, bool, error) {
 					return row + 1, nil, false, fmt.Errorf(fmsg, row+1)
 				}
 
+			} else if endline := blockend(line); endline != "" {
+				// skip comment and test blocks, till end of block.
+				for row++; row < len(lines); row++ {
+					if strings.Join(strings.Fields(lines[row]), " ") == endline {
+						break
+					}
+				}
+
 			} else { // begin block
 				row++
 				blocklines = append(blocklines, line)
@@ -188,3 +267,13 @@ func blockiterate(lines []string) func() (int, []string, bool, error) {
 		return row + 1, blocklines, true, nil
 	}
 }
+
+// blockend return the line ending a `comment` or `test` block, if line
+// begins such a block.
+func blockend(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) > 0 && (fields[0] == "comment" || fields[0] == "test") {
+		return "end " + fields[0]
+	}
+	return ""
+}
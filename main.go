@@ -2,6 +2,7 @@ package main
 
 import "os"
 import "fmt"
+import "time"
 
 import "github.com/bnclabs/golog"
 import "github.com/tn47/goledger/dblentry"
@@ -12,6 +13,10 @@ var _ = fmt.Sprintf("dummy")
 
 func main() {
 	args := phase1()
+	if api.Options.Watch {
+		watch(args, time.Second)
+		return
+	}
 	reporter, db := phase2(args)
 	nreporter, ndb := phase3(args, reporter, db)
 	nreporter.Render(args, ndb)
@@ -27,6 +32,11 @@ func trycommand(args []string, phase string) bool {
 		case "version", "ver":
 			log.Consolef("goledger version - goledger%v\n", api.LedgerVersion)
 			return true
+		case "serve":
+			if err := serve(args); err != nil {
+				os.Exit(1)
+			}
+			return true
 		}
 
 	case "phase2":
@@ -55,7 +65,8 @@ func phase1() (args []string) {
 // 1. create one or more reporter
 // 2. create a datastore.
 // 3. firstpass on all journal files on the datastore.
-// 4. firstpass completed
+// 4. forecast periodic transactions, if requested.
+// 5. firstpass completed
 func phase2(args []string) (api.Reporter, api.Datastorer) {
 	defer func() {
 		if trycommand(args, "phase2") {
@@ -67,6 +78,17 @@ func phase2(args []string) (api.Reporter, api.Datastorer) {
 	if err != nil {
 		os.Exit(1)
 	}
+	db, err := loadjournals(reporter)
+	if err != nil {
+		os.Exit(1)
+	}
+	return reporter, db
+}
+
+// loadjournals create a datastore and do firstpass on all journal files,
+// along with forecast of periodic transactions, if requested. On error,
+// datastore is returned with the journals processed so far.
+func loadjournals(reporter api.Reporter) (*dblentry.Datastore, error) {
 	db := dblentry.NewDatastore(api.Options.Dbname, reporter)
 
 	// apply command line arguments here.
@@ -78,12 +100,18 @@ func phase2(args []string) (api.Reporter, api.Datastorer) {
 		log.Debugf("processing journal %q\n", journal)
 		reporter.Startjournal(journal, false /*included*/)
 		if err := dofirstpass(reporter, db, journal); err != nil {
-			os.Exit(1)
+			return db, err
+		}
+	}
+	if api.Options.Forecast != nil {
+		if err := db.Forecast(*api.Options.Forecast); err != nil {
+			log.Errorf("forecast: %v\n", err)
+			return db, err
 		}
 	}
 	db.Firstpassok()
 	db.PrintAccounts() // for debug
-	return reporter, db
+	return db, nil
 }
 
 // 1. clone reporter and datastore for secondpass.
@@ -99,21 +127,35 @@ func phase3(
 		}
 	}()
 
+	nreporter, ndb, err := dosecondpass(args, reporter, db)
+	if err != nil {
+		os.Exit(2)
+	}
+	return nreporter, ndb
+}
+
+// dosecondpass clone reporter and datastore and do secondpass on the
+// cloned datastore, for commands that need a secondpass.
+func dosecondpass(
+	args []string,
+	reporter api.Reporter,
+	db api.Datastorer) (api.Reporter, api.Datastorer, error) {
+
 	if len(args) == 0 {
-		return reporter, nil
+		return reporter, nil, nil
 	}
 
 	switch args[0] {
 	case "list", "ls":
-		return reporter, db
+		return reporter, db, nil
 	}
 
 	nreporter := reporter.Clone()
 	//nreporter.secondpass()
 	ndb := db.Clone(nreporter)
 	if err := secondpass(ndb); err != nil {
-		os.Exit(2)
+		return nil, nil, err
 	}
 	ndb.Secondpassok()
-	return nreporter, ndb
+	return nreporter, ndb, nil
 }
@@ -0,0 +1,266 @@
+package reports
+
+import "os"
+import "fmt"
+import "time"
+import "bufio"
+import "strings"
+import "strconv"
+import "encoding/csv"
+
+import "github.com/prataprc/goparsec"
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+
+// csvrules map columns of a bank statement, in csv format, to fields of
+// a transaction. Rules file contain one rule per line, lines starting
+// with `#` or `;` are comments. Columns are numbered from 1.
+//
+//	skip       N          number of header rows to skip.
+//	separator  CHAR       column separator, `tab` for tabs, default `,`.
+//	date       COL [FMT]  date column and optional Go layout for date.
+//	payee      COL...     payee columns, joined with space.
+//	uuid       COL        transaction's uuid, also matched with payee's uuid.
+//	amount     COL        signed amount, credited to account.
+//	deposit    COL        amount credited to account.
+//	withdrawal COL        amount debited from account.
+//	commodity  AMOUNT     commodity, with format, like `$1,000.00`.
+type csvrules struct {
+	skip       int
+	separator  rune
+	datecol    int
+	datefmt    string
+	payeecols  []int
+	uuidcol    int
+	amountcol  int
+	depositcol int
+	withdrcol  int
+	commodity  string
+}
+
+func newcsvrules() *csvrules {
+	return &csvrules{
+		separator: ',', datecol: -1, payeecols: []int{}, uuidcol: -1,
+		amountcol: -1, depositcol: -1, withdrcol: -1,
+	}
+}
+
+// importcsv read bank statement in csv format, as entries.
+func importcsv(
+	db *dblentry.Datastore, filename, rulesfile string) ([]*importentry, error) {
+
+	rules, err := readcsvrules(rulesfile)
+	if err != nil {
+		return nil, err
+	}
+	var comm api.Commoditiser
+	if rules.commodity != "" {
+		if comm, err = db.ParseCommodity(rules.commodity); err != nil {
+			return nil, fmt.Errorf("%v: %v", rulesfile, err)
+		}
+	}
+
+	fd, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	reader := csv.NewReader(fd)
+	reader.Comma = rules.separator
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", filename, err)
+	}
+
+	entries, seen := []*importentry{}, map[string]int{}
+	for index, record := range records {
+		if index < rules.skip {
+			continue
+		}
+		entry, err := rules.toentry(db, comm, record)
+		if err != nil {
+			return nil, fmt.Errorf("%v: row %v: %v", filename, index+1, err)
+		}
+		entry.source = fmt.Sprintf("%v:%v", filename, index+1)
+		if entry.uuid == "" {
+			key := strings.Join(record, "\x00")
+			seen[key]++
+			entry.uuid = fingerprint(record, seen[key])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func readcsvrules(rulesfile string) (*csvrules, error) {
+	fd, err := os.Open(rulesfile)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	rules := newcsvrules()
+	scanner, lineno := bufio.NewScanner(fd), 0
+	for scanner.Scan() {
+		lineno++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.ContainsAny(fields[0][:1], "#;") {
+			continue
+		}
+		if err := rules.addrule(fields[0], fields[1:]); err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", rulesfile, lineno, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if rules.datecol < 0 {
+		return nil, fmt.Errorf("%v: missing date column", rulesfile)
+	} else if len(rules.payeecols) == 0 {
+		return nil, fmt.Errorf("%v: missing payee column", rulesfile)
+	}
+	noamount := rules.depositcol < 0 && rules.withdrcol < 0
+	if rules.amountcol < 0 && noamount {
+		return nil, fmt.Errorf("%v: missing amount column", rulesfile)
+	}
+	return rules, nil
+}
+
+func (rules *csvrules) addrule(name string, values []string) (err error) {
+	if len(values) == 0 {
+		return fmt.Errorf("rule %q expects a value", name)
+	}
+
+	switch name {
+	case "skip":
+		rules.skip, err = strconv.Atoi(values[0])
+	case "separator":
+		if values[0] == "tab" {
+			rules.separator = '\t'
+		} else {
+			rules.separator = []rune(values[0])[0]
+		}
+	case "date":
+		rules.datecol, err = csvcolumn(values[0])
+		rules.datefmt = strings.Join(values[1:], " ")
+	case "payee":
+		for _, value := range values {
+			col, err := csvcolumn(value)
+			if err != nil {
+				return err
+			}
+			rules.payeecols = append(rules.payeecols, col)
+		}
+	case "uuid":
+		rules.uuidcol, err = csvcolumn(values[0])
+	case "amount":
+		rules.amountcol, err = csvcolumn(values[0])
+	case "deposit":
+		rules.depositcol, err = csvcolumn(values[0])
+	case "withdrawal":
+		rules.withdrcol, err = csvcolumn(values[0])
+	case "commodity":
+		rules.commodity = strings.Join(values, " ")
+	default:
+		err = fmt.Errorf("invalid rule %q", name)
+	}
+	return err
+}
+
+func (rules *csvrules) toentry(
+	db *dblentry.Datastore, comm api.Commoditiser,
+	record []string) (*importentry, error) {
+
+	cell := func(col int) (string, error) {
+		if col >= len(record) {
+			return "", fmt.Errorf("missing column %v", col+1)
+		}
+		return strings.TrimSpace(record[col]), nil
+	}
+
+	entry := &importentry{}
+
+	text, err := cell(rules.datecol)
+	if err != nil {
+		return nil, err
+	} else if entry.date, err = rules.parsedate(text); err != nil {
+		return nil, err
+	}
+
+	payees := []string{}
+	for _, col := range rules.payeecols {
+		text, err := cell(col)
+		if err != nil {
+			return nil, err
+		} else if text != "" {
+			payees = append(payees, text)
+		}
+	}
+	entry.payee = strings.Join(payees, " ")
+	if entry.payee == "" {
+		return nil, fmt.Errorf("empty payee")
+	}
+
+	if rules.uuidcol >= 0 {
+		if entry.uuid, err = cell(rules.uuidcol); err != nil {
+			return nil, err
+		}
+	}
+
+	commname, amount := "", api.Decimal{}
+	cols := []int{rules.amountcol, rules.depositcol, rules.withdrcol}
+	for _, col := range cols {
+		if col < 0 {
+			continue
+		}
+		text, err := cell(col)
+		if err != nil {
+			return nil, err
+		} else if text == "" && col != rules.amountcol {
+			continue
+		}
+		name, value, err := parsestatement(text)
+		if err != nil {
+			return nil, err
+		} else if col == rules.withdrcol {
+			value = value.Neg()
+		}
+		if name != "" {
+			commname = name
+		}
+		amount = amount.Add(value)
+	}
+
+	if comm != nil {
+		entry.amount = comm.MakeSimilar(amount)
+	} else if commname != "" || db.DefaultCommodity() != "" {
+		entry.amount = db.GetCommodity(commname).MakeSimilar(amount)
+	} else {
+		return nil, fmt.Errorf("unknown commodity, add `commodity` rule")
+	}
+	return entry, nil
+}
+
+func (rules *csvrules) parsedate(text string) (time.Time, error) {
+	if rules.datefmt != "" {
+		return time.ParseInLocation(rules.datefmt, text, time.Local)
+	}
+	scanner := parsec.NewScanner([]byte(text))
+	node, _ := dblentry.Ydate(time.Now().Year())(scanner)
+	if tm, ok := node.(time.Time); ok {
+		return tm, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", text)
+}
+
+func csvcolumn(value string) (int, error) {
+	col, err := strconv.Atoi(value)
+	if err != nil || col < 1 {
+		return -1, fmt.Errorf("invalid column %q", value)
+	}
+	return col - 1, nil
+}
@@ -0,0 +1,134 @@
+package reports
+
+import "fmt"
+import "time"
+import "regexp"
+import "strings"
+import "io/ioutil"
+
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+
+// OFX v1 is SGML, where elements holding a value need not be closed, and
+// OFX v2 is XML. Both close aggregates like <STMTTRN> and <LEDGERBAL>, so
+// elements are picked as `<NAME>value` until the next tag.
+var reofxstmttrn = regexp.MustCompile(`(?is)<STMTTRN>(.*?)</STMTTRN>`)
+var reofxledgerbal = regexp.MustCompile(`(?is)<LEDGERBAL>(.*?)</LEDGERBAL>`)
+var reofxelement = regexp.MustCompile(`<([A-Za-z0-9.]+)>([^<]*)`)
+
+// importofx read bank statement in OFX or QFX format, as entries, along
+// with ledger balance of the account as on its date, if available.
+func importofx(
+	db *dblentry.Datastore,
+	filename string) ([]*importentry, *importentry, error) {
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	text := string(data)
+	if strings.Contains(strings.ToUpper(text), "<OFX>") == false {
+		return nil, nil, fmt.Errorf("%v: not an ofx statement", filename)
+	}
+	curdef := ofxelements(text)["CURDEF"]
+
+	entries := []*importentry{}
+	for _, match := range reofxstmttrn.FindAllStringSubmatchIndex(text, -1) {
+		lineno := strings.Count(text[:match[0]], "\n") + 1
+		elements := ofxelements(text[match[2]:match[3]])
+		entry, err := ofxentry(db, elements, curdef)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v:%v: %v", filename, lineno, err)
+		}
+		entry.source = fmt.Sprintf("%v:%v", filename, lineno)
+		entries = append(entries, entry)
+	}
+
+	var balance *importentry
+	if match := reofxledgerbal.FindStringSubmatchIndex(text); match != nil {
+		lineno := strings.Count(text[:match[0]], "\n") + 1
+		elements := ofxelements(text[match[2]:match[3]])
+		balance = &importentry{source: fmt.Sprintf("%v:%v", filename, lineno)}
+		balance.amount, err = ofxamount(db, elements["BALAMT"], curdef)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v:%v: %v", filename, lineno, err)
+		}
+		if asof, ok := elements["DTASOF"]; ok {
+			if balance.date, err = ofxdate(asof); err != nil {
+				return nil, nil, fmt.Errorf("%v:%v: %v", filename, lineno, err)
+			}
+		}
+	}
+	return entries, balance, nil
+}
+
+func ofxentry(
+	db *dblentry.Datastore,
+	elements map[string]string, curdef string) (*importentry, error) {
+
+	var err error
+
+	entry := &importentry{uuid: elements["FITID"]}
+	if entry.uuid == "" {
+		return nil, fmt.Errorf("missing <FITID>")
+	}
+	if entry.date, err = ofxdate(elements["DTPOSTED"]); err != nil {
+		return nil, err
+	}
+	if entry.payee = elements["NAME"]; entry.payee == "" {
+		entry.payee = elements["MEMO"]
+	}
+	if entry.payee == "" {
+		return nil, fmt.Errorf("missing <NAME>")
+	}
+	entry.amount, err = ofxamount(db, elements["TRNAMT"], curdef)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ofxelements return elements holding a value, first occurrence of an
+// element is picked if it is repeated.
+func ofxelements(text string) map[string]string {
+	elements := map[string]string{}
+	for _, match := range reofxelement.FindAllStringSubmatch(text, -1) {
+		name, value := strings.ToUpper(match[1]), strings.TrimSpace(match[2])
+		if _, ok := elements[name]; ok == false && value != "" {
+			elements[name] = value
+		}
+	}
+	return elements
+}
+
+// ofxdate parse date like `20110105`, `20110105120000.000[-5:EST]`,
+// ignoring time of the day.
+func ofxdate(text string) (time.Time, error) {
+	if len(text) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", text)
+	}
+	return time.ParseInLocation("20060102", text[:8], time.Local)
+}
+
+// ofxamount parse amount in the statement's currency, else in default
+// commodity of the journal.
+func ofxamount(
+	db *dblentry.Datastore, text, curdef string) (api.Commoditiser, error) {
+
+	text = strings.Replace(strings.TrimSpace(text), ",", ".", 1)
+	value, err := api.ParseDecimal(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q", text)
+	}
+	if curdef == "" && db.DefaultCommodity() != "" {
+		return db.GetCommodity("").MakeSimilar(value), nil
+	} else if curdef == "" {
+		return nil, fmt.Errorf("unknown commodity for amount %q", text)
+	}
+	text = fmt.Sprintf("%v %v", strings.TrimPrefix(text, "+"), curdef)
+	comm, err := db.ParseCommodity(text)
+	if err != nil {
+		return nil, err
+	}
+	return comm, nil
+}
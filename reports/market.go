@@ -0,0 +1,112 @@
+package reports
+
+import "time"
+import "reflect"
+
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+
+// marketvalue convert commodities into a target commodity, using the latest
+// known price as on the report's end date. Applicable for -market and
+// -exchange options.
+type marketvalue struct {
+	target string
+	date   time.Time
+}
+
+func newmarketvalue() *marketvalue {
+	market := &marketvalue{target: api.Options.Exchange, date: time.Now()}
+	if api.Options.Enddt != nil {
+		market.date = *api.Options.Enddt
+	}
+	return market
+}
+
+// gettarget commodity, if not supplied via -exchange, use the default
+// commodity of the datastore.
+func (market *marketvalue) gettarget(db api.Datastorer) string {
+	if market.target == "" {
+		market.target = db.(*dblentry.Datastore).DefaultCommodity()
+	}
+	return market.target
+}
+
+// value return the market value of `comm` in target commodity, if there
+// is no known price for `comm` return the same.
+func (market *marketvalue) value(
+	db api.Datastorer, comm api.Commoditiser) api.Commoditiser {
+
+	target := market.gettarget(db)
+	if comm.Name() == target || target == "" {
+		return comm
+	}
+	unit := db.GetPrice(comm.Name(), target, market.date)
+	if unit == nil {
+		return comm
+	}
+	return unit.MakeSimilar(comm.Amount().Mul(unit.Amount()))
+}
+
+// values return the market value for list of commodities, commodities
+// that can be valued in target are aggregated.
+func (market *marketvalue) values(
+	db api.Datastorer, comms []api.Commoditiser) *dblentry.DoubleEntry {
+
+	de := dblentry.NewDoubleEntry("marketvalue")
+	for _, comm := range comms {
+		de.AddBalance(market.value(db, comm))
+	}
+	return de
+}
+
+// costbasis return the value of posting in target commodity, as on the day
+// of the transaction. Lot price takes precedence over cost price, if
+// neither are in target commodity, price database is looked up.
+func (market *marketvalue) costbasis(
+	db api.Datastorer, trans api.Transactor, p api.Poster) api.Commoditiser {
+
+	target, comm := market.gettarget(db), p.Commodity()
+	if comm.Name() == target || target == "" {
+		return comm
+	}
+	for _, price := range []api.Commoditiser{p.Lotprice(), p.Costprice()} {
+		if reflect.ValueOf(price).IsNil() || price.Name() != target {
+			continue
+		}
+		return price.MakeSimilar(comm.Amount().Mul(price.Amount()))
+	}
+	unit := db.GetPrice(comm.Name(), target, trans.Date())
+	if unit == nil {
+		return comm
+	}
+	return unit.MakeSimilar(comm.Amount().Mul(unit.Amount()))
+}
+
+// gain return unrealised gain, as market value minus cost basis, in target
+// commodity, as a cell. Return empty string if either of them are not in
+// target, or if there is no gain.
+func (market *marketvalue) gain(
+	db api.Datastorer, values, costs *dblentry.DoubleEntry) interface{} {
+
+	if costs == nil {
+		return ""
+	}
+	target := market.gettarget(db)
+	var value, cost api.Commoditiser
+	for _, bal := range values.Balances() {
+		if bal.Name() == target {
+			value = bal
+		}
+	}
+	for _, bal := range costs.Balances() {
+		if bal.Name() == target {
+			cost = bal
+		}
+	}
+	if value == nil || cost == nil {
+		return ""
+	} else if amount := value.Amount().Sub(cost.Amount()); amount.Sign() != 0 {
+		return value.MakeSimilar(amount)
+	}
+	return ""
+}
@@ -0,0 +1,215 @@
+package reports
+
+import "io"
+import "fmt"
+import "html"
+import "bytes"
+import "strings"
+
+import "github.com/tn47/goledger/api"
+
+// rcdocumenter render RCformat's rows, as formatted for text, in a
+// document format that can be pasted into review packs.
+type rcdocumenter func(
+	w io.Writer, columns []*rccolumn, sections []*rcsection) error
+
+// rcdocumenters for -format option.
+var rcdocumenters = map[string]rcdocumenter{
+	"html":     renderhtml,
+	"markdown": rendermarkdown,
+}
+
+// rcsection is a table of rows, sections with title are collapsed under
+// the title.
+type rcsection struct {
+	title string
+	rows  []*rcdocrow
+}
+
+// rcdocrow is a row of cells as formatted for text.
+type rcdocrow struct {
+	cells   []string // account name is trimmed of its indentation.
+	classes []string // css class for each cell.
+	depth   int      // indentation of account name.
+	total   bool     // row is below the dashes.
+}
+
+// sections return rows after the header. With -collapse and indented
+// account names, each top-level account along with its sub-accounts is
+// a section, followed by a section for total.
+func (rcf *RCformat) sections(
+	db api.Datastorer, columns []*rccolumn) []*rcsection {
+
+	accx := -1
+	for x, col := range columns {
+		if col.title == "Account" {
+			accx = x
+		}
+	}
+
+	rows, total := []*rcdocrow{}, false
+	for y, cells := range rcf.rows[rcf.body:] {
+		if rcdecorative(cells) {
+			total = total || strings.Contains(strings.Join(cells, ""), "-")
+			continue
+		}
+		row := &rcdocrow{total: total}
+		for x, col := range columns {
+			cell, class := "", col.class()
+			var value interface{}
+			if x < len(cells) {
+				cell, value = cells[x], rcf.cells[rcf.body+y][x]
+			}
+			if x == accx {
+				name := strings.TrimLeft(cell, " ")
+				row.depth = (len(cell) - len(name)) / 2
+			}
+			amount, ok := value.(api.Commoditiser)
+			if ok && amount.Amount().Sign() < 0 {
+				class += " credit"
+			} else if ok && amount.Amount().Sign() > 0 {
+				class += " debit"
+			}
+			row.cells = append(row.cells, strings.TrimSpace(cell))
+			row.classes = append(row.classes, class)
+		}
+		rows = append(rows, row)
+	}
+
+	if api.Options.Collapse == false || rcf.indented == false || accx < 0 {
+		return []*rcsection{&rcsection{rows: rows}}
+	}
+
+	// account name is on the last row of the account.
+	depth := 0
+	for y := len(rows) - 1; y >= 0; y-- {
+		if rows[y].cells[accx] == "" {
+			rows[y].depth = depth
+		}
+		depth = rows[y].depth
+	}
+
+	sections, section := []*rcsection{}, (*rcsection)(nil)
+	for _, row := range rows {
+		fresh := section == nil
+		if row.total {
+			fresh = fresh || section.rows[0].total == false
+		} else if row.depth == 0 {
+			fresh = fresh || section.title != ""
+		}
+		if fresh {
+			section = &rcsection{}
+			sections = append(sections, section)
+		}
+		if row.total == false && row.depth == 0 && row.cells[accx] != "" {
+			section.title = row.cells[accx]
+		}
+		section.rows = append(section.rows, row)
+	}
+	return sections
+}
+
+//---- renderers
+
+// renderhtml render sections as tables, debit and credit amounts are
+// classed for css, account names are indented by padding.
+func renderhtml(w io.Writer, columns []*rccolumn, sections []*rcsection) error {
+	buf := &bytes.Buffer{}
+	for _, section := range sections {
+		if section.title != "" {
+			title := html.EscapeString(section.title)
+			fmt.Fprintf(buf, "<details>\n<summary>%v</summary>\n", title)
+		}
+		buf.WriteString("<table class=\"goledger\">\n<thead>\n<tr>")
+		for _, col := range columns {
+			title := html.EscapeString(col.title)
+			fmt.Fprintf(buf, "<th class=%q>%v</th>", col.class(), title)
+		}
+		buf.WriteString("</tr>\n</thead>\n<tbody>\n")
+		for _, row := range section.rows {
+			if row.total {
+				buf.WriteString("<tr class=\"total\">")
+			} else {
+				buf.WriteString("<tr>")
+			}
+			for x, cell := range row.cells {
+				style := ""
+				if columns[x].title == "Account" && row.depth > 0 {
+					fmsg := " style=\"padding-left: %vem\""
+					style = fmt.Sprintf(fmsg, row.depth)
+				}
+				cell = html.EscapeString(cell)
+				fmsg := "<td class=%q%v>%v</td>"
+				fmt.Fprintf(buf, fmsg, row.classes[x], style, cell)
+			}
+			buf.WriteString("</tr>\n")
+		}
+		buf.WriteString("</tbody>\n</table>\n")
+		if section.title != "" {
+			buf.WriteString("</details>\n")
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// rendermarkdown render sections as pipe tables, amounts are right
+// aligned, account names are indented by non-breaking spaces and totals
+// are in bold.
+func rendermarkdown(
+	w io.Writer, columns []*rccolumn, sections []*rcsection) error {
+
+	buf := &bytes.Buffer{}
+	for y, section := range sections {
+		if y > 0 {
+			buf.WriteString("\n")
+		}
+		if section.title != "" {
+			title := html.EscapeString(section.title)
+			fmt.Fprintf(buf, "<details>\n<summary>%v</summary>\n\n", title)
+		}
+		titles, aligns := []string{}, []string{}
+		for _, col := range columns {
+			titles = append(titles, mdescape(col.title))
+			if col.kind == "amount" {
+				aligns = append(aligns, "---:")
+			} else {
+				aligns = append(aligns, "---")
+			}
+		}
+		fmt.Fprintf(buf, "| %v |\n", strings.Join(titles, " | "))
+		fmt.Fprintf(buf, "| %v |\n", strings.Join(aligns, " | "))
+		for _, row := range section.rows {
+			cells := []string{}
+			for x, cell := range row.cells {
+				cell = mdescape(cell)
+				if columns[x].title == "Account" && cell != "" {
+					cell = api.Repeatstr("&nbsp;", 2*row.depth) + cell
+				}
+				if row.total && cell != "" {
+					cell = "**" + cell + "**"
+				}
+				cells = append(cells, cell)
+			}
+			fmt.Fprintf(buf, "| %v |\n", strings.Join(cells, " | "))
+		}
+		if section.title != "" {
+			buf.WriteString("\n</details>\n")
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// class of column's cells, amounts are further classed as debit or
+// credit.
+func (col *rccolumn) class() string {
+	if col.kind == "string" {
+		return col.field
+	}
+	return col.kind
+}
+
+func mdescape(cell string) string {
+	return strings.Replace(cell, "|", "\\|", -1)
+}
@@ -1,6 +1,8 @@
 package reports
 
 import "fmt"
+import "time"
+import "reflect"
 import "strings"
 
 import "github.com/prataprc/goparsec"
@@ -9,13 +11,18 @@ import "github.com/tn47/goledger/dblentry"
 
 // RCformat for {row, column} tabular formatting.
 type RCformat struct {
-	rows    [][]string
-	padding string
+	rows     [][]string
+	cells    [][]interface{} // typed cells of rows, for -format renderers.
+	header   []string        // column titles, for -format renderers.
+	body     int             // index of first row after the header.
+	indented bool            // account names are indented as per Indent().
+	padding  string
 }
 
 // NewRCformat creates a new table of rows and colums.
 func NewRCformat() *RCformat {
-	rcf := &RCformat{rows: [][]string{}, padding: " "}
+	rcf := &RCformat{rows: [][]string{}, cells: [][]interface{}{}}
+	rcf.padding = " "
 	return rcf
 }
 
@@ -24,7 +31,63 @@ func (rcf *RCformat) readsettings() *RCformat {
 }
 
 func (rcf *RCformat) addrow(row ...string) *RCformat {
-	rcf.rows = append(rcf.rows, row)
+	cells := []interface{}{}
+	for _, cell := range row {
+		cells = append(cells, cell)
+	}
+	rcf.rows, rcf.cells = append(rcf.rows, row), append(rcf.cells, cells)
+	return rcf
+}
+
+// addcells add a row of typed cells, string, time.Time, rcdate or
+// api.Commoditiser, text of the row is formatted from cells.
+func (rcf *RCformat) addcells(cells ...interface{}) *RCformat {
+	row := []string{}
+	for _, cell := range cells {
+		row = append(row, rctext(cell))
+	}
+	rcf.rows, rcf.cells = append(rcf.rows, row), append(rcf.cells, cells)
+	return rcf
+}
+
+// rcdate is a date cell formatted as per layout, renderers shall render
+// them as YYYY-MM-DD, or as YYYY-MM if layout has no day.
+type rcdate struct {
+	time.Time
+	layout string
+}
+
+func rctext(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format("2006/Jan/02")
+	case rcdate:
+		return v.Format(v.layout)
+	case api.Commoditiser:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", cell)
+}
+
+// rcamount cell for commodity, a copy as balances are updated in place,
+// empty if there is no commodity.
+func rcamount(comm api.Commoditiser) interface{} {
+	if comm == nil || reflect.ValueOf(comm).IsNil() {
+		return ""
+	}
+	return comm.MakeSimilar(comm.Amount())
+}
+
+// addheader add column titles as a row, rows added after this are
+// rendered by -format renderers.
+func (rcf *RCformat) addheader(titles ...string) *RCformat {
+	rcf.header = titles
+	rcf.addrow(append([]string{}, titles...)...)
+	rcf.body = len(rcf.rows)
 	return rcf
 }
 
@@ -94,7 +157,8 @@ func (rcf *RCformat) column(index int) []string {
 
 func (rcf *RCformat) Clone() *RCformat {
 	nrcf := *rcf
-	nrcf.rows = [][]string{}
+	nrcf.rows, nrcf.cells = [][]string{}, [][]interface{}{}
+	nrcf.header, nrcf.body, nrcf.indented = nil, 0, false
 	return &nrcf
 }
 
@@ -113,7 +177,7 @@ func CommodityColor(
 
 	scanner := parsec.NewScanner([]byte(text))
 	node, _ := comm.Yledger(db.(*dblentry.Datastore))(scanner)
-	if node.(api.Commoditiser).Amount() < 0 {
+	if node.(api.Commoditiser).Amount().Sign() < 0 {
 		return api.RedFn(text)
 	}
 	return text
@@ -0,0 +1,228 @@
+package reports
+
+import "io"
+import "fmt"
+import "time"
+import "bytes"
+import "strings"
+import "encoding/csv"
+import "encoding/json"
+
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+
+// rcrenderer render RCformat's rows, typed by their columns, in a machine
+// readable format. Cells are string, date as `YYYY-MM-DD`, or amount as
+// api.Commoditiser, empty cells are nil.
+type rcrenderer func(
+	w io.Writer, columns []*rccolumn, rows [][]interface{}) error
+
+// rcrenderers for -format option, `text` is rendered by the reports.
+var rcrenderers = map[string]rcrenderer{
+	"csv":  rendercsv,
+	"tsv":  rendertsv,
+	"json": renderjson,
+}
+
+// rccolumn describe a column of RCformat for renderers.
+type rccolumn struct {
+	title string // as in the header row.
+	field string // csv header and json key.
+	kind  string // "date", "amount" or "string".
+}
+
+// columns titled as one of these hold amounts.
+var rcamounts = []string{
+	"Amount", "Balance", "Debit", "Credit", "Value", "Gain",
+}
+
+func newrccolumn(title string) *rccolumn {
+	field := strings.ToLower(strings.TrimPrefix(title, "By-"))
+	col := &rccolumn{title: title, field: field, kind: "string"}
+	if title == "By-date" {
+		col.kind = "date"
+	} else if api.HasString(rcamounts, title) {
+		col.kind = "amount"
+	}
+	return col
+}
+
+// rcvalue of a typed cell, dates are formatted as YYYY-MM-DD, or YYYY-MM
+// for monthly dates, amounts are passed as is and empty cells are nil.
+func rcvalue(cell interface{}) interface{} {
+	switch v := cell.(type) {
+	case time.Time:
+		return v.Format("2006-01-02")
+	case rcdate:
+		if strings.Contains(v.layout, "02") {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01")
+	case api.Commoditiser:
+		return v
+	}
+	if text := strings.TrimSpace(rctext(cell)); text != "" {
+		return text
+	}
+	return nil
+}
+
+// formatted return true if -format selects a renderer other than text.
+func (rcf *RCformat) formatted() bool {
+	_, ok := rcdocumenters[api.Options.Outformat]
+	return ok || rcf.typed()
+}
+
+// typed return true if -format selects a renderer for typed rows, where
+// every row shall carry its date, account and payee.
+func (rcf *RCformat) typed() bool {
+	_, ok := rcrenderers[api.Options.Outformat]
+	return ok
+}
+
+// render rows after the header using -format renderer. Rows that are
+// empty, or only has dashes, are skipped. For typed renderers, empty cells
+// in filldown columns are copied from the previous row.
+func (rcf *RCformat) render(db api.Datastorer, filldown ...int) {
+	columns := []*rccolumn{}
+	for _, title := range rcf.header {
+		columns = append(columns, newrccolumn(title))
+	}
+
+	if documenter, ok := rcdocumenters[api.Options.Outformat]; ok {
+		sections := rcf.sections(db, columns)
+		if err := documenter(api.Options.Outfd, columns, sections); err != nil {
+			log.Errorf("%v\n", err)
+		}
+		return
+	}
+
+	rows, prev := [][]interface{}{}, []interface{}{}
+	for y, row := range rcf.rows[rcf.body:] {
+		if rcdecorative(row) {
+			continue
+		}
+		values := []interface{}{}
+		for x := range columns {
+			var value interface{}
+			if cells := rcf.cells[rcf.body+y]; x < len(cells) {
+				value = rcvalue(cells[x])
+			}
+			values = append(values, value)
+		}
+		for _, x := range filldown {
+			if values[x] == nil && len(prev) > x {
+				values[x] = prev[x]
+			}
+		}
+		prev = values
+		rows = append(rows, values)
+	}
+
+	renderer := rcrenderers[api.Options.Outformat]
+	if err := renderer(api.Options.Outfd, columns, rows); err != nil {
+		log.Errorf("%v\n", err)
+	}
+}
+
+func rcdecorative(row []string) bool {
+	for _, cell := range row {
+		if strings.Trim(cell, " -") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+//---- renderers
+
+func rendercsv(w io.Writer, columns []*rccolumn, rows [][]interface{}) error {
+	return renderdsv(w, ',', columns, rows)
+}
+
+func rendertsv(w io.Writer, columns []*rccolumn, rows [][]interface{}) error {
+	return renderdsv(w, '\t', columns, rows)
+}
+
+// renderdsv render rows as delimiter separated values, amounts are split
+// into a quantity column and a commodity column.
+func renderdsv(
+	w io.Writer, comma rune,
+	columns []*rccolumn, rows [][]interface{}) error {
+
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+
+	header := []string{}
+	for _, col := range columns {
+		header = append(header, col.field)
+		if col.kind == "amount" {
+			header = append(header, col.field+"_commodity")
+		}
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		record := []string{}
+		for x, value := range row {
+			quantity, commodity := "", ""
+			switch v := value.(type) {
+			case api.Commoditiser:
+				quantity = v.Amount().String()
+				commodity = v.Name()
+			case string:
+				quantity = v
+			}
+			record = append(record, quantity)
+			if columns[x].kind == "amount" {
+				record = append(record, commodity)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// renderjson render rows as a list of objects, keyed by column's field in
+// the same order as columns. Amounts are objects of commodity and
+// quantity.
+func renderjson(w io.Writer, columns []*rccolumn, rows [][]interface{}) error {
+	buf := bytes.NewBufferString("[")
+	for y, row := range rows {
+		if y > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("{")
+		for x, value := range row {
+			if x > 0 {
+				buf.WriteString(",")
+			}
+			if v, ok := value.(api.Commoditiser); ok {
+				value = map[string]interface{}{
+					"commodity": v.Name(), "quantity": v.Amount(),
+				}
+			}
+			key, _ := json.Marshal(columns[x].field)
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "%s:%s", key, data)
+		}
+		buf.WriteString("}")
+	}
+	buf.WriteString("]")
+
+	out := &bytes.Buffer{}
+	if err := json.Indent(out, buf.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	out.WriteString("\n")
+	_, err := w.Write(out.Bytes())
+	return err
+}
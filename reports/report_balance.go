@@ -7,7 +7,7 @@ import "fmt"
 
 import "github.com/prataprc/goparsec"
 
-//import "github.com/bnclabs/golog"
+import "github.com/bnclabs/golog"
 import "github.com/tn47/goledger/api"
 import "github.com/tn47/goledger/dblentry"
 
@@ -15,31 +15,47 @@ import "github.com/tn47/goledger/dblentry"
 type ReportBalance struct {
 	rcf       *RCformat
 	fe        *api.Filterexpr
-	balance   map[string][][]string
+	balance   map[string][][]interface{}
 	de        *dblentry.DoubleEntry
 	finaldate time.Time
 	postings  map[string]bool
 	bubbleacc map[string]bool
+	// -market
+	market *marketvalue
+	costs  map[string]*dblentry.DoubleEntry
+	costde *dblentry.DoubleEntry
+	// tag filter and -by-tag
+	tally map[string]*dblentry.Account
 }
 
 // NewReportBalance creates an instance for balance reporting
 func NewReportBalance(args []string) (*ReportBalance, error) {
 	report := &ReportBalance{
 		rcf:       NewRCformat(),
-		balance:   make(map[string][][]string),
+		balance:   make(map[string][][]interface{}),
 		postings:  map[string]bool{},
 		bubbleacc: map[string]bool{},
 		de:        dblentry.NewDoubleEntry("finaltally"),
+		market:    newmarketvalue(),
+		costs:     map[string]*dblentry.DoubleEntry{},
+		costde:    dblentry.NewDoubleEntry("finalcost"),
 	}
 	if len(args) > 1 {
 		filterarg := api.MakeFilterexpr(args[1:])
 		node, _ := api.YFilterExpr(parsec.NewScanner([]byte(filterarg)))
 		if err, ok := node.(error); ok {
+			fmsg := "filter %q expression failed: %v\n"
+			log.Errorf(fmsg, filterarg, err)
 			return nil, err
 		}
 		report.fe = node.(*api.Filterexpr)
 		//log.Consolef("filter expr: %v\n", report.fe)
 	}
+	if report.isfiltered() && report.fe.Predicates() {
+		report.tally = map[string]*dblentry.Account{}
+	} else if api.Options.Bytag != "" {
+		report.tally = map[string]*dblentry.Account{}
+	}
 	return report, nil
 }
 
@@ -62,8 +78,8 @@ func (report *ReportBalance) Posting(
 
 	acc := p.Account()
 
-	// filter account
-	if report.isfiltered() && report.fe.Match(acc.Name()) == false {
+	// filter account and predicates
+	if report.isfiltered() && report.fe.MatchPosting(trans, p) == false {
 		return nil
 	}
 	if api.FilterPeriod(trans.Date(), true /*nobegin*/) == false {
@@ -75,14 +91,31 @@ func (report *ReportBalance) Posting(
 	if api.Options.Onlypl && !(acc.IsIncome() || acc.IsExpense()) {
 		return nil
 	}
+	if report.tally != nil {
+		tally := report.tallyaccount(p)
+		if tally == nil {
+			return nil
+		} else if err := tally.Tally(p); err != nil {
+			return err
+		}
+		acc = tally
+	}
 
 	// final balance
 	report.de.AddBalance(p.Commodity().(*dblentry.Commodity))
 	report.finaldate = trans.Date()
 
+	if api.Options.Market {
+		cost := report.market.costbasis(db, trans, p)
+		report.costde.AddBalance(cost)
+		report.addcost(acc.Name(), cost)
+	}
+
 	// format account balance
-	var balances [][]string
-	if api.Options.Dcformat {
+	var balances [][]interface{}
+	if api.Options.Market {
+		balances = report.fmtmarket(db, trans, acc)
+	} else if api.Options.Dcformat {
 		balances = acc.FmtDCBalances(db, trans, p, acc)
 	} else {
 		balances = acc.FmtBalances(db, trans, p, acc)
@@ -102,15 +135,21 @@ func (report *ReportBalance) BubblePosting(
 	db api.Datastorer, trans api.Transactor,
 	p api.Poster, account api.Accounter) error {
 
-	if api.Options.Nosubtotal || report.isfiltered() {
+	if api.Options.Nosubtotal || report.isfiltered() || report.tally != nil {
 		return nil
 	} else if api.FilterPeriod(trans.Date(), true /*nobegin*/) == false {
 		return nil
 	}
 	bbname := account.Name()
 
+	if api.Options.Market {
+		report.addcost(bbname, report.market.costbasis(db, trans, p))
+	}
+
 	// format account balance
-	if api.Options.Dcformat {
+	if api.Options.Market {
+		report.balance[bbname] = report.fmtmarket(db, trans, account)
+	} else if api.Options.Dcformat {
 		report.balance[bbname] = account.FmtDCBalances(db, trans, p, account)
 	} else {
 		report.balance[bbname] = account.FmtBalances(db, trans, p, account)
@@ -131,11 +170,14 @@ func (report *ReportBalance) Render(args []string, db api.Datastorer) {
 	sort.Strings(keys)
 
 	fmtkeys := keys
-	if api.Options.Nosubtotal == false {
+	if api.Options.Nosubtotal == false && report.rcf.typed() == false {
 		fmtkeys = Indent(keys)
+		report.rcf.indented = true
 	}
 
-	if api.Options.Dcformat {
+	if api.Options.Market {
+		report.renderMarket(args, keys, fmtkeys, db)
+	} else if api.Options.Dcformat {
 		report.renderDCBalance(args, keys, fmtkeys, db)
 	} else {
 		report.renderBalance(args, keys, fmtkeys, db)
@@ -146,32 +188,27 @@ func (report *ReportBalance) renderBalance(
 	args, keys, fmtkeys []string, db api.Datastorer) {
 
 	rcf := report.rcf
-	rcf.addrow([]string{"By-date", "Account", "Balance"}...)
+	rcf.addheader("By-date", report.accountheader(), "Balance")
 	rcf.addrow([]string{"", "", ""}...) // empty line
 
-	for i, key := range keys {
-		rows := report.balance[key]
-		for j, cols := range rows {
-			cols[1] = ""
-			if j == len(rows)-1 {
-				cols[1] = fmtkeys[i]
-			}
-			rcf.addrow(cols...)
-		}
-	}
+	report.addbalances(keys, fmtkeys)
 
 	dashes := api.Repeatstr("-", rcf.maxwidth(rcf.column(2)))
 	rcf.addrow([]string{"", "", dashes}...)
 	balances := report.de.Balances()
 	for i, bal := range balances {
-		if i < (len(balances) - 1) {
-			rcf.addrow([]string{"", "", bal.String()}...)
+		if i < (len(balances)-1) && rcf.typed() == false {
+			rcf.addcells("", "", rcamount(bal))
 		} else {
-			date := report.finaldate.Format("2006/Jan/02")
-			rcf.addrow([]string{date, "", bal.String()}...)
+			rcf.addcells(report.finaldate, "", rcamount(bal))
 		}
 	}
 
+	if rcf.formatted() {
+		rcf.render(db)
+		return
+	}
+
 	w0 := rcf.maxwidth(rcf.column(0)) // Date
 	w1 := rcf.maxwidth(rcf.column(1)) // Account name
 	w2 := rcf.maxwidth(rcf.column(2)) // Balance (amount)
@@ -202,25 +239,80 @@ func (report *ReportBalance) renderBalance(
 	fmt.Fprintln(outfd)
 }
 
-func (report *ReportBalance) renderDCBalance(
+func (report *ReportBalance) renderMarket(
 	args, keys, fmtkeys []string, db api.Datastorer) {
 
 	rcf := report.rcf
+	rcf.addheader("By-date", report.accountheader(), "Value", "Gain")
+	rcf.addrow([]string{"", "", "", ""}...) // empty line
 
-	rcf.addrow([]string{"By-date", "Account", "Debit", "Credit", "Balance"}...)
-	rcf.addrow([]string{"", "", "", "", ""}...) // empty line
+	report.addbalances(keys, fmtkeys)
 
-	for i, key := range keys {
-		rows := report.balance[key]
-		for j, cols := range rows {
-			cols[1] = ""
-			if j == len(rows)-1 {
-				cols[1] = fmtkeys[i]
-			}
-			rcf.addrow(cols...)
+	valdashes := api.Repeatstr("-", rcf.maxwidth(rcf.column(2)))
+	gaindashes := api.Repeatstr("-", rcf.maxwidth(rcf.column(3)))
+	rcf.addrow([]string{"", "", valdashes, gaindashes}...)
+	values := report.market.values(db, report.de.Balances())
+	balances := values.Balances()
+	for i, bal := range balances {
+		cols := []interface{}{"", "", rcamount(bal), ""}
+		if i == (len(balances) - 1) {
+			cols[3] = report.market.gain(db, values, report.costde)
 		}
+		if i == (len(balances)-1) || rcf.typed() {
+			cols[0] = report.finaldate
+		}
+		rcf.addcells(cols...)
+	}
+
+	if rcf.formatted() {
+		rcf.render(db)
+		return
+	}
+
+	w0 := rcf.maxwidth(rcf.column(0)) // Date
+	w1 := rcf.maxwidth(rcf.column(1)) // Account name
+	w2 := rcf.maxwidth(rcf.column(2)) // Value (amount)
+	w3 := rcf.maxwidth(rcf.column(3)) // Gain (amount)
+	if (w0 + w1 + w2 + w3) > 100 {
+		_ /*w1*/ = rcf.FitAccountname(1, 100-w0-w2-w3)
 	}
 
+	rcf.paddcells()
+	fmsg := rcf.Fmsg(" %%-%vs%%-%vs%%%vs%%%vs\n")
+	comm1 := dblentry.NewCommodity("")
+	comm2 := dblentry.NewCommodity("")
+
+	// start printing
+	outfd := api.Options.Outfd
+	fmt.Fprintln(outfd)
+	for i, cols := range rcf.rows {
+		items := []interface{}{cols[0]}
+		if i < 2 {
+			items = append(items, cols[1], cols[2], cols[3])
+		} else {
+			items = append(
+				items,
+				api.YellowFn(cols[1]),
+				CommodityColor(db, comm1, cols[2]),
+				CommodityColor(db, comm2, cols[3]),
+			)
+		}
+		fmt.Fprintf(outfd, fmsg, items...)
+	}
+	fmt.Fprintln(outfd)
+}
+
+func (report *ReportBalance) renderDCBalance(
+	args, keys, fmtkeys []string, db api.Datastorer) {
+
+	rcf := report.rcf
+
+	header := report.accountheader()
+	rcf.addheader("By-date", header, "Debit", "Credit", "Balance")
+	rcf.addrow([]string{"", "", "", "", ""}...) // empty line
+
+	report.addbalances(keys, fmtkeys)
+
 	drdashes := api.Repeatstr("-", rcf.maxwidth(rcf.column(2)))
 	crdashes := api.Repeatstr("-", rcf.maxwidth(rcf.column(3)))
 	baldashes := api.Repeatstr("-", rcf.maxwidth(rcf.column(4)))
@@ -229,11 +321,16 @@ func (report *ReportBalance) renderDCBalance(
 	for i, bal := range balances {
 		name := bal.Name()
 		dr, cr := report.de.Debit(name), report.de.Credit(name)
-		cols := []string{"", "", dr.String(), cr.String(), bal.String()}
-		if i == (len(balances) - 1) {
-			cols[0] = report.finaldate.Format("2006/Jan/02")
+		cols := []interface{}{"", "", rcamount(dr), rcamount(cr), rcamount(bal)}
+		if i == (len(balances)-1) || rcf.typed() {
+			cols[0] = report.finaldate
 		}
-		rcf.addrow(cols...)
+		rcf.addcells(cols...)
+	}
+
+	if rcf.formatted() {
+		rcf.render(db)
+		return
 	}
 
 	w0 := rcf.maxwidth(rcf.column(0)) // Date
@@ -272,14 +369,40 @@ func (report *ReportBalance) renderDCBalance(
 	fmt.Fprintln(outfd)
 }
 
+// addbalances add balance rows for each account, account name is set on
+// the last row of the account. For typed -format renderers every row
+// carries the date and account name.
+func (report *ReportBalance) addbalances(keys, fmtkeys []string) {
+	rcf := report.rcf
+	for i, key := range keys {
+		rows := report.balance[key]
+		if len(rows) == 0 {
+			continue
+		}
+		date := rows[len(rows)-1][0]
+		for j, cols := range rows {
+			cols[1] = ""
+			if j == len(rows)-1 || rcf.typed() {
+				cols[0], cols[1] = date, fmtkeys[i]
+			}
+			rcf.addcells(cols...)
+		}
+	}
+}
+
 func (report *ReportBalance) Clone() api.Reporter {
 	nreport := *report
 	nreport.rcf = report.rcf.Clone()
 	nreport.fe = report.fe
-	nreport.balance = make(map[string][][]string)
+	nreport.balance = make(map[string][][]interface{})
 	nreport.de = report.de.Clone()
 	nreport.postings = map[string]bool{}
 	nreport.bubbleacc = map[string]bool{}
+	nreport.costs = map[string]*dblentry.DoubleEntry{}
+	nreport.costde = report.costde.Clone()
+	if report.tally != nil {
+		nreport.tally = map[string]*dblentry.Account{}
+	}
 	return &nreport
 }
 
@@ -306,6 +429,66 @@ func (report *ReportBalance) prunebubbled() {
 	}
 }
 
+// fmtmarket format account's balance in market value, with unrealised
+// gain in the last row. Date, Accountname, Value, Gain.
+func (report *ReportBalance) fmtmarket(
+	db api.Datastorer, trans api.Transactor,
+	acc api.Accounter) [][]interface{} {
+
+	values := report.market.values(db, acc.Balances())
+	rows := make([][]interface{}, 0)
+	for _, value := range values.Balances() {
+		if value.Amount().IsZero() == false || acc.HasPosting() == false {
+			rows = append(rows, []interface{}{"", "", rcamount(value), ""})
+		}
+	}
+	if len(rows) > 0 { // last row to include date, account name and gain.
+		lastrow := rows[len(rows)-1]
+		lastrow[0] = trans.Date()
+		lastrow[1] = acc.Name()
+		lastrow[3] = report.market.gain(db, values, report.costs[acc.Name()])
+	}
+	return rows
+}
+
+// tallyaccount return report's own account to tally postings that match
+// tag filter, named after the posting's account. For -by-tag, account is
+// named after posting's tag value and nil is returned if posting is not
+// tagged.
+func (report *ReportBalance) tallyaccount(p api.Poster) *dblentry.Account {
+	name := p.Account().Name()
+	if key := api.Options.Bytag; key != "" {
+		if value := p.Metadata(key); value != nil {
+			name = fmt.Sprintf("%v", value)
+		} else if api.HasString(p.Tags(), key) {
+			name = key
+		} else {
+			return nil
+		}
+	}
+	tally, ok := report.tally[name]
+	if ok == false {
+		tally = dblentry.NewAccount(name)
+		report.tally[name] = tally
+	}
+	return tally
+}
+
+// accountheader for account column, tag key for -by-tag.
+func (report *ReportBalance) accountheader() string {
+	if api.Options.Bytag != "" {
+		return api.Options.Bytag
+	}
+	return "Account"
+}
+
+func (report *ReportBalance) addcost(accname string, cost api.Commoditiser) {
+	if _, ok := report.costs[accname]; ok == false {
+		report.costs[accname] = dblentry.NewDoubleEntry(accname)
+	}
+	report.costs[accname].AddBalance(cost)
+}
+
 func (report *ReportBalance) isfiltered() bool {
 	return report.fe != nil
 }
@@ -0,0 +1,274 @@
+package reports
+
+import "fmt"
+import "time"
+import "sort"
+import "strings"
+
+//import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+
+// ReportBudget compare actual postings with the amounts budgeted by
+// periodic transactions, for every month or for every quarter.
+type ReportBudget struct {
+	rcf      *RCformat
+	register *ReportRegister // actual postings, bucketed by month/quarter
+}
+
+// NewReportBudget create an instance for budget reporting.
+func NewReportBudget(args []string) (*ReportBudget, error) {
+	register, err := NewReportRegister(args)
+	if err != nil {
+		return nil, err
+	}
+	report := &ReportBudget{rcf: NewRCformat(), register: register}
+	return report, nil
+}
+
+//---- api.Reporter methods
+
+func (report *ReportBudget) Firstpass(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportBudget) Transaction(
+	db api.Datastorer, trans api.Transactor) error {
+
+	if api.FilterPeriod(trans.Date(), false /*nobegin*/) == false {
+		return nil
+	} else if api.Options.Quarterly {
+		return report.register.mapreduce7(db, trans)
+	}
+	return report.register.mapreduce6(db, trans)
+}
+
+func (report *ReportBudget) Posting(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportBudget) BubblePosting(
+	db api.Datastorer, trans api.Transactor,
+	p api.Poster, account api.Accounter) error {
+
+	return nil
+}
+
+func (report *ReportBudget) Render(args []string, db api.Datastorer) {
+	rcf := report.rcf
+
+	cols := []string{"Period", "Account", "Actual", "Budget", "Remaining", "Used"}
+	rcf.addrow(cols...)
+	rcf.addrow([]string{"", "", "", "", "", ""}...)
+
+	periodics := db.(*dblentry.Datastore).Periodics()
+	spans := report.periods()
+	for _, span := range spans {
+		from, till := span[0], span[1]
+		budgets := report.budgets(periodics, spans[0][0], from, till)
+		actuals := report.actuals(from)
+		label := report.periodlabel(from)
+		accnames := []string{}
+		for accname := range budgets {
+			accnames = append(accnames, accname)
+		}
+		sort.Strings(accnames)
+		for _, accname := range accnames {
+			actual := report.actual(actuals, accname)
+			for _, budget := range budgets[accname].Balances() {
+				spent := budget.MakeSimilar(api.Decimal{})
+				for _, bal := range actual.Balances() {
+					if bal.Name() == budget.Name() {
+						spent = bal
+					}
+				}
+				remaining := budget.Amount().Sub(spent.Amount())
+				used := ""
+				if spent.Amount().IsZero() {
+					used = "0%"
+				} else if budget.Amount().IsZero() == false {
+					ratio := spent.Amount().Quo(budget.Amount())
+					used = ratio.Mul(api.NewDecimal(100)).Format(0) + "%"
+				}
+				cols := []string{
+					label, accname, spent.String(), budget.String(),
+					budget.MakeSimilar(remaining).String(), used,
+				}
+				rcf.addrow(cols...)
+				label, accname = "", ""
+			}
+		}
+	}
+
+	w0 := rcf.maxwidth(rcf.column(0)) // Period
+	w1 := rcf.maxwidth(rcf.column(1)) // Account name
+	rest := 0
+	for i := 2; i < 6; i++ {
+		rest += rcf.maxwidth(rcf.column(i))
+	}
+	if (w0 + w1 + rest) > 125 {
+		_ /*w1*/ = rcf.FitAccountname(1, 125-w0-rest)
+	}
+
+	rcf.paddcells()
+	fmsg := rcf.Fmsg(" %%-%vs%%-%vs%%%vs%%%vs%%%vs%%%vs\n")
+	comm1 := dblentry.NewCommodity("")
+	comm2 := dblentry.NewCommodity("")
+
+	// start printing
+	outfd := api.Options.Outfd
+	fmt.Fprintln(outfd)
+	for i, cols := range rcf.rows {
+		items := []interface{}{cols[0]}
+		if i < 2 {
+			for _, col := range cols[1:] {
+				items = append(items, col)
+			}
+		} else {
+			items = append(items, api.YellowFn(cols[1]), cols[2], cols[3])
+			items = append(items, CommodityColor(db, comm1, cols[4]))
+			items = append(items, CommodityColor(db, comm2, cols[5]))
+		}
+		fmt.Fprintf(outfd, fmsg, items...)
+	}
+	fmt.Fprintln(outfd)
+}
+
+func (report *ReportBudget) Clone() api.Reporter {
+	nreport := *report
+	nreport.rcf = report.rcf.Clone()
+	nreport.register = report.register.Clone().(*ReportRegister)
+	return &nreport
+}
+
+func (report *ReportBudget) Startjournal(fname string, included bool) {
+	panic("not implemented")
+}
+
+//---- local functions
+
+// periods return list of [from, till) for every month, or quarter, from
+// the begin date till the end date. If dates are not supplied, they are
+// computed from actual postings.
+func (report *ReportBudget) periods() [][2]time.Time {
+	var begin, end time.Time
+
+	months, step := []time.Time{}, 1
+	if api.Options.Quarterly {
+		step = 3
+		for year, quarters := range report.register.quarterly {
+			for quarter := range quarters {
+				months = append(months, report.monthof(year, quarter*3+1))
+			}
+		}
+	} else {
+		for year, monthly := range report.register.monthly {
+			for month := range monthly {
+				months = append(months, report.monthof(year, month))
+			}
+		}
+	}
+	for _, month := range months {
+		if begin.IsZero() || month.Before(begin) {
+			begin = month
+		}
+		if till := month.AddDate(0, step, 0); end.IsZero() || till.After(end) {
+			end = till
+		}
+	}
+	if dt := api.Options.Begindt; dt != nil {
+		begin = report.monthof(dt.Year(), int(dt.Month()))
+		if step == 3 {
+			begin = begin.AddDate(0, -((int(dt.Month()) - 1) % 3), 0)
+		}
+	}
+	if dt := api.Options.Enddt; dt != nil {
+		end = *dt
+	}
+
+	spans := [][2]time.Time{}
+	if begin.IsZero() || end.IsZero() {
+		return spans
+	}
+	for from := begin; from.Before(end); from = from.AddDate(0, step, 0) {
+		spans = append(spans, [2]time.Time{from, from.AddDate(0, step, 0)})
+	}
+	return spans
+}
+
+// budgets return budgeted amounts for each account from all periodic
+// transactions occurring between [from, till). Periodic transactions
+// without a begin date recur from the beginning of the report.
+func (report *ReportBudget) budgets(
+	periodics []*dblentry.Periodic,
+	begin, from, till time.Time) map[string]*dblentry.DoubleEntry {
+
+	budgets := map[string]*dblentry.DoubleEntry{}
+	for _, periodic := range periodics {
+		for _, date := range periodic.Occurrences(begin, till) {
+			if date.Before(from) {
+				continue
+			}
+			trans := periodic.Forecast(date)
+			for _, p := range trans.GetPostings() {
+				accname := p.Account().Name()
+				if report.register.isfilteracc() {
+					fe := report.register.fe
+					if fe.MatchPosting(trans, p) == false {
+						continue
+					}
+				}
+				de, ok := budgets[accname]
+				if ok == false {
+					de = dblentry.NewDoubleEntry(accname)
+					budgets[accname] = de
+				}
+				de.AddBalance(p.Commodity())
+			}
+		}
+	}
+	return budgets
+}
+
+// actuals return actual postings, for every account, in the period
+// beginning with `from`.
+func (report *ReportBudget) actuals(
+	from time.Time) map[string]*dblentry.DoubleEntry {
+
+	year, month := from.Year(), int(from.Month())
+	if api.Options.Quarterly {
+		return report.register.quarterly[year][(month-1)/3]
+	}
+	return report.register.monthly[year][month]
+}
+
+// actual return actual postings for account and its sub-accounts.
+func (report *ReportBudget) actual(
+	actuals map[string]*dblentry.DoubleEntry,
+	accname string) *dblentry.DoubleEntry {
+
+	de := dblentry.NewDoubleEntry(accname)
+	for name, accde := range actuals {
+		if name == accname || strings.HasPrefix(name, accname+":") {
+			for _, bal := range accde.Balances() {
+				de.AddBalance(bal)
+			}
+		}
+	}
+	return de
+}
+
+func (report *ReportBudget) periodlabel(from time.Time) string {
+	if api.Options.Quarterly {
+		return fmt.Sprintf("%v/Q%v", from.Year(), (int(from.Month())-1)/3+1)
+	}
+	return from.Format("2006/Jan")
+}
+
+func (report *ReportBudget) monthof(year, month int) time.Time {
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+}
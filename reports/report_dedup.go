@@ -0,0 +1,122 @@
+package reports
+
+import "fmt"
+import "strings"
+
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+
+// dedupindex remember the position of transactions by their uuid and
+// by their Crc64.
+type dedupindex struct {
+	uuids map[string]string // uuid -> file:line
+	crcs  map[uint64]string // crc64 -> file:line
+}
+
+func newdedupindex() *dedupindex {
+	return &dedupindex{uuids: map[string]string{}, crcs: map[uint64]string{}}
+}
+
+// add transaction's identity, unless it is already indexed.
+func (index *dedupindex) add(uuid string, crc uint64, position string) {
+	if _, ok := index.lookup(uuid, crc); ok {
+		return
+	}
+	if uuid != "" {
+		index.uuids[uuid] = position
+	}
+	index.crcs[crc] = position
+}
+
+// lookup return the position of transaction having the same uuid, or the
+// same crc64.
+func (index *dedupindex) lookup(uuid string, crc uint64) (string, bool) {
+	if position, ok := index.uuids[uuid]; ok && uuid != "" {
+		return position, true
+	}
+	position, ok := index.crcs[crc]
+	return position, ok
+}
+
+// ReportDedup list transactions that duplicate a previous transaction,
+// by their uuid or by their Crc64.
+type ReportDedup struct {
+	index *dedupindex
+	dups  [][]string // [position, position-of-original]
+}
+
+// NewReportDedup create an instance to list duplicate transactions.
+func NewReportDedup(args []string) *ReportDedup {
+	return &ReportDedup{index: newdedupindex(), dups: [][]string{}}
+}
+
+//---- api.Reporter methods
+
+func (report *ReportDedup) Firstpass(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportDedup) Transaction(
+	db api.Datastorer, trans api.Transactor) error {
+
+	dtrans := trans.(*dblentry.Transaction)
+	if dtrans.IsForecast() {
+		return nil
+	}
+	journalfile, lineno := dtrans.Position()
+	position := fmt.Sprintf("%v:%v", journalfile, lineno)
+	if orig, ok := report.index.lookup(dtrans.Uuid(), trans.Crc64()); ok {
+		report.dups = append(report.dups, []string{position, orig})
+		return nil
+	}
+	report.index.add(dtrans.Uuid(), trans.Crc64(), position)
+	return nil
+}
+
+func (report *ReportDedup) Posting(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportDedup) BubblePosting(
+	db api.Datastorer, trans api.Transactor,
+	p api.Poster, account api.Accounter) error {
+
+	return nil
+}
+
+func (report *ReportDedup) Render(args []string, db api.Datastorer) {
+	outfd := api.Options.Outfd
+	for _, dup := range report.dups {
+		fmt.Fprintf(outfd, "%v: duplicate of %v\n", dup[0], dup[1])
+	}
+	fmsg := "%v duplicate transactions\n"
+	fmt.Fprintf(outfd, fmsg, len(report.dups))
+}
+
+func (report *ReportDedup) Clone() api.Reporter {
+	nreport := *report
+	nreport.index = newdedupindex()
+	nreport.dups = [][]string{}
+	return &nreport
+}
+
+func (report *ReportDedup) Startjournal(fname string, included bool) {
+	panic("not implemented")
+}
+
+//---- local functions
+
+// fingerprint return a stable identity for a statement record. Repeated
+// occurrence of the same record, within a statement, is identified by
+// its count `n`.
+func fingerprint(record []string, n int) string {
+	data := strings.Join(record, "\x00")
+	if n > 1 {
+		data += fmt.Sprintf("\x00%v", n)
+	}
+	return fmt.Sprintf("%016x", api.Crc64([]byte(data)))
+}
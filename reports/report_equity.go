@@ -19,15 +19,16 @@ type ReportEquity struct {
 	rcf        *RCformat
 	fe         *api.Filterexpr
 	latestdate time.Time
-	equity     map[string][][]string
+	equity     map[string][][]interface{}
 	pandl      *dblentry.DoubleEntry // should balance out
+	tally      map[string]*dblentry.Account
 }
 
 // NewReportEquity create a new instance for equity reporting.
 func NewReportEquity(args []string) (*ReportEquity, error) {
 	report := &ReportEquity{
 		rcf:    NewRCformat(),
-		equity: make(map[string][][]string),
+		equity: make(map[string][][]interface{}),
 		pandl:  dblentry.NewDoubleEntry("pandl"),
 	}
 	api.Options.Nosubtotal = true
@@ -35,11 +36,16 @@ func NewReportEquity(args []string) (*ReportEquity, error) {
 		filterarg := api.MakeFilterexpr(args[1:])
 		node, _ := api.YFilterExpr(parsec.NewScanner([]byte(filterarg)))
 		if err, ok := node.(error); ok {
+			fmsg := "filter %q expression failed: %v\n"
+			log.Errorf(fmsg, filterarg, err)
 			return nil, err
 		}
 		report.fe = node.(*api.Filterexpr)
 		//log.Consolef("filter expr: %v\n", report.fe)
 	}
+	if report.isfiltered() && report.fe.Predicates() {
+		report.tally = map[string]*dblentry.Account{}
+	}
 	return report, nil
 }
 
@@ -62,13 +68,24 @@ func (report *ReportEquity) Posting(
 
 	acc := p.Account()
 
-	// filter account
-	if report.isfiltered() && report.fe.Match(acc.Name()) == false {
+	// filter account and predicates
+	if report.isfiltered() && report.fe.MatchPosting(trans, p) == false {
 		return nil
 	}
 	if api.FilterPeriod(trans.Date(), true /*nobegin*/) == false {
 		return nil
 	}
+	if report.tally != nil && (acc.IsIncome() || acc.IsExpense()) == false {
+		tally, ok := report.tally[acc.Name()]
+		if ok == false {
+			tally = dblentry.NewAccount(acc.Name())
+			report.tally[acc.Name()] = tally
+		}
+		if err := tally.Tally(p); err != nil {
+			return err
+		}
+		acc = tally
+	}
 	if acc.IsIncome() || acc.IsExpense() {
 		report.pandl.AddBalance(p.Commodity().(*dblentry.Commodity))
 
@@ -106,15 +123,28 @@ func (report *ReportEquity) Render(args []string, db api.Datastorer) {
 	}
 	sort.Strings(keys)
 
-	cols := []string{
-		report.latestdate.Format("2006/Jan/02"), PayeeOpeningBalance, "",
+	if rcf.formatted() {
+		rcf.addheader("By-date", "Account", "Balance")
+		var date interface{} = report.latestdate
+		for _, key := range keys {
+			for _, row := range report.equity[key] {
+				rcf.addcells(date, row[1], row[2])
+				if rcf.typed() == false {
+					date = ""
+				}
+			}
+		}
+		rcf.render(db)
+		return
 	}
-	rcf.addrow(cols...)
+
+	cols := []interface{}{report.latestdate, PayeeOpeningBalance, ""}
+	rcf.addcells(cols...)
 
 	for _, key := range keys {
 		rows := report.equity[key]
 		for _, row := range rows {
-			report.rcf.addrow(row...)
+			report.rcf.addcells(row...)
 		}
 	}
 
@@ -153,7 +183,10 @@ func (report *ReportEquity) Clone() api.Reporter {
 	nreport := *report
 	nreport.rcf = report.rcf.Clone()
 	nreport.fe = report.fe
-	nreport.equity = make(map[string][][]string)
+	nreport.equity = make(map[string][][]interface{})
+	if report.tally != nil {
+		nreport.tally = map[string]*dblentry.Account{}
+	}
 	return &nreport
 }
 
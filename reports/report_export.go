@@ -0,0 +1,90 @@
+package reports
+
+import "fmt"
+
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+
+// ReportExport journals in other formats.
+type ReportExport struct {
+	format string
+}
+
+// NewReportExport create an instance to export journals.
+func NewReportExport(args []string) (*ReportExport, error) {
+	if len(args) != 2 {
+		err := fmt.Errorf("export expects a format")
+		log.Errorf("%v\n", err)
+		return nil, err
+	}
+	switch args[1] {
+	case "beancount", "ledger", "json":
+	default:
+		err := fmt.Errorf("invalid export format %q", args[1])
+		log.Errorf("%v\n", err)
+		return nil, err
+	}
+	return &ReportExport{format: args[1]}, nil
+}
+
+//---- api.Reporter methods
+
+func (report *ReportExport) Firstpass(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportExport) Transaction(
+	db api.Datastorer, trans api.Transactor) error {
+
+	return nil
+}
+
+func (report *ReportExport) Posting(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportExport) BubblePosting(
+	db api.Datastorer, trans api.Transactor,
+	p api.Poster, account api.Accounter) error {
+
+	return nil
+}
+
+func (report *ReportExport) Render(args []string, db api.Datastorer) {
+	var lines []string
+	var err error
+	switch report.format {
+	case "beancount":
+		lines, err = db.(*dblentry.Datastore).Beancount()
+	case "ledger":
+		lines = db.(*dblentry.Datastore).Ledger()
+	case "json":
+		var data []byte
+		if data, err = db.(*dblentry.Datastore).JSON(); err == nil {
+			fmt.Fprint(api.Options.Outfd, string(data))
+			return
+		}
+	}
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return
+	}
+	outfd := api.Options.Outfd
+	for _, line := range lines {
+		fmt.Fprintln(outfd, line)
+	}
+}
+
+func (report *ReportExport) Clone() api.Reporter {
+	nreport := *report
+	return &nreport
+}
+
+func (report *ReportExport) Startjournal(fname string, included bool) {
+	panic("not implemented")
+}
@@ -0,0 +1,162 @@
+package reports
+
+import "fmt"
+import "strconv"
+
+import "github.com/prataprc/goparsec"
+
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+
+// ReportGains for realised capital gains reporting.
+type ReportGains struct {
+	rcf *RCformat
+	fe  *api.Filterexpr
+}
+
+// NewReportGains create an instance for realised gains reporting.
+func NewReportGains(args []string) (*ReportGains, error) {
+	report := &ReportGains{rcf: NewRCformat()}
+	if len(args) > 1 {
+		filterarg := api.MakeFilterexpr(args[1:])
+		node, _ := api.YFilterExpr(parsec.NewScanner([]byte(filterarg)))
+		if err, ok := node.(error); ok {
+			fmsg := "filter %q expression failed: %v\n"
+			log.Errorf(fmsg, filterarg, err)
+			return nil, err
+		}
+		report.fe = node.(*api.Filterexpr)
+		//log.Consolef("filter expr: %v\n", report.fe)
+	}
+	return report, nil
+}
+
+//---- api.Reporter methods
+
+func (report *ReportGains) Firstpass(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportGains) Transaction(
+	db api.Datastorer, trans api.Transactor) error {
+
+	return nil
+}
+
+func (report *ReportGains) Posting(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportGains) BubblePosting(
+	db api.Datastorer, trans api.Transactor,
+	p api.Poster, account api.Accounter) error {
+
+	return nil
+}
+
+func (report *ReportGains) Render(args []string, db api.Datastorer) {
+	rcf := report.rcf
+
+	cols := []string{
+		"Sold", "Account", "Quantity", "Acquired", "Days", "Term",
+		"Cost", "Proceeds", "Gain",
+	}
+	rcf.addrow(cols...)
+	rcf.addrow([]string{"", "", "", "", "", "", "", "", ""}...)
+
+	shortterm := dblentry.NewDoubleEntry("shortterm")
+	longterm := dblentry.NewDoubleEntry("longterm")
+	for _, sale := range db.(*dblentry.Datastore).Sales() {
+		trans, p := sale.Transaction(), sale.Posting()
+		if report.isfiltered() && report.fe.MatchPosting(trans, p) == false {
+			continue
+		} else if api.FilterPeriod(sale.Date(), false /*nobegin*/) == false {
+			continue
+		}
+
+		acquired, days, term := "", "", "short"
+		if n := sale.Holdingdays(); n >= 0 {
+			acquired = sale.Acquired().Format("2006/Jan/02")
+			days = strconv.Itoa(n)
+		}
+		gain, de := "", shortterm
+		if sale.IsLongterm() {
+			term, de = "long", longterm
+		}
+		if comm := sale.Gain(); comm != nil {
+			gain = comm.String()
+			de.AddBalance(comm)
+		}
+		cols := []string{
+			sale.Date().Format("2006/Jan/02"), sale.Account(),
+			sale.Quantity().String(), acquired, days, term,
+			sale.Cost().String(), sale.Proceeds().String(), gain,
+		}
+		rcf.addrow(cols...)
+	}
+
+	dashes := api.Repeatstr("-", rcf.maxwidth(rcf.column(8)))
+	rcf.addrow([]string{"", "", "", "", "", "", "", "", dashes}...)
+	for _, bal := range shortterm.Balances() {
+		rcf.addrow([]string{"", "", "", "", "", "short", "", "", bal.String()}...)
+	}
+	for _, bal := range longterm.Balances() {
+		rcf.addrow([]string{"", "", "", "", "", "long", "", "", bal.String()}...)
+	}
+
+	w0 := rcf.maxwidth(rcf.column(0)) // Sold
+	w1 := rcf.maxwidth(rcf.column(1)) // Account name
+	rest := 0
+	for i := 2; i < 9; i++ {
+		rest += rcf.maxwidth(rcf.column(i))
+	}
+	if (w0 + w1 + rest) > 125 {
+		_ /*w1*/ = rcf.FitAccountname(1, 125-w0-rest)
+	}
+
+	rcf.paddcells()
+	fmsg := rcf.Fmsg(
+		" %%-%vs%%-%vs%%%vs%%%vs%%%vs%%%vs%%%vs%%%vs%%%vs\n",
+	)
+	comm := dblentry.NewCommodity("")
+
+	// start printing
+	outfd := api.Options.Outfd
+	fmt.Fprintln(outfd)
+	for i, cols := range rcf.rows {
+		items := []interface{}{cols[0]}
+		if i < 2 {
+			for _, col := range cols[1:] {
+				items = append(items, col)
+			}
+		} else {
+			items = append(items, api.YellowFn(cols[1]))
+			for _, col := range cols[2:8] {
+				items = append(items, col)
+			}
+			items = append(items, CommodityColor(db, comm, cols[8]))
+		}
+		fmt.Fprintf(outfd, fmsg, items...)
+	}
+	fmt.Fprintln(outfd)
+}
+
+func (report *ReportGains) Clone() api.Reporter {
+	nreport := *report
+	nreport.rcf = report.rcf.Clone()
+	nreport.fe = report.fe
+	return &nreport
+}
+
+func (report *ReportGains) Startjournal(fname string, included bool) {
+	panic("not implemented")
+}
+
+func (report *ReportGains) isfiltered() bool {
+	return report.fe != nil
+}
@@ -0,0 +1,225 @@
+package reports
+
+import "fmt"
+import "sort"
+import "time"
+import "strings"
+
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+
+// counter accounts for statement entries that don't match any payee. Not
+// `Unknown`, which is rewritten while parsing the journal.
+const importExpense = "Expenses:Uncategorized"
+const importIncome = "Income:Uncategorized"
+
+// importentry is a single entry from bank statement.
+type importentry struct {
+	source string // statement file and its row/line.
+	date   time.Time
+	payee  string
+	uuid   string           // fingerprint of the entry.
+	amount api.Commoditiser // positive amount is credited to the account.
+}
+
+// ReportImport convert bank statements into journal transactions, using
+// payee and account directives to resolve the counter account. Entries
+// that are already in the journal, by uuid or by Crc64, are skipped.
+type ReportImport struct {
+	format   string
+	filename string
+	index    *dedupindex
+}
+
+// NewReportImport create an instance to import bank statement.
+func NewReportImport(args []string) (*ReportImport, error) {
+	if len(args) != 3 {
+		err := fmt.Errorf("import expects a format and statement file")
+		log.Errorf("%v\n", err)
+		return nil, err
+	}
+
+	switch args[1] {
+	case "csv":
+		if api.Options.Rules == "" {
+			err := fmt.Errorf("import csv expects a rules file")
+			log.Errorf("%v\n", err)
+			return nil, err
+		}
+	case "ofx", "qfx":
+	default:
+		err := fmt.Errorf("invalid import format %q", args[1])
+		log.Errorf("%v\n", err)
+		return nil, err
+	}
+	if api.Options.Importacc == "" {
+		err := fmt.Errorf("import expects an account")
+		log.Errorf("%v\n", err)
+		return nil, err
+	}
+	report := &ReportImport{
+		format: args[1], filename: args[2], index: newdedupindex(),
+	}
+	return report, nil
+}
+
+//---- api.Reporter methods
+
+func (report *ReportImport) Firstpass(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportImport) Transaction(
+	db api.Datastorer, trans api.Transactor) error {
+
+	dtrans := trans.(*dblentry.Transaction)
+	if dtrans.IsForecast() {
+		return nil
+	}
+	journalfile, lineno := dtrans.Position()
+	position := fmt.Sprintf("%v:%v", journalfile, lineno)
+	report.index.add(dtrans.Uuid(), trans.Crc64(), position)
+	return nil
+}
+
+func (report *ReportImport) Posting(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportImport) BubblePosting(
+	db api.Datastorer, trans api.Transactor,
+	p api.Poster, account api.Accounter) error {
+
+	return nil
+}
+
+func (report *ReportImport) Render(args []string, ndb api.Datastorer) {
+	db := ndb.(*dblentry.Datastore)
+
+	var entries []*importentry
+	var balance *importentry // ledger balance, as on its date.
+	var err error
+	switch report.format {
+	case "csv":
+		entries, err = importcsv(db, report.filename, api.Options.Rules)
+	case "ofx", "qfx":
+		entries, balance, err = importofx(db, report.filename)
+	}
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].date.Before(entries[j].date)
+	})
+
+	// skip duplicates.
+	origs := make([]string, len(entries))
+	for i, entry := range entries {
+		lines := report.translines(db, entry, nil)
+		crc := api.Crc64([]byte(strings.Join(lines, "")))
+		if orig, ok := report.index.lookup(entry.uuid, crc); ok {
+			origs[i] = orig
+			continue
+		}
+		report.index.add(entry.uuid, crc, entry.source)
+	}
+
+	// balance is asserted on the last entry by date, if it is imported and
+	// not dated after the balance.
+	last, assertat := len(entries)-1, -1
+	if balance != nil && last >= 0 && origs[last] == "" {
+		asof := balance.date
+		if asof.IsZero() || entries[last].date.After(asof) == false {
+			assertat = last
+		}
+	}
+
+	outfd := api.Options.Outfd
+	for i, entry := range entries {
+		if origs[i] != "" {
+			fmsg := "; skipped %v, duplicate of %v\n\n"
+			fmt.Fprintf(outfd, fmsg, entry.source, origs[i])
+			continue
+		}
+		var assert api.Commoditiser
+		if i == assertat {
+			assert = balance.amount
+		}
+		for _, line := range report.translines(db, entry, assert) {
+			fmt.Fprintln(outfd, line)
+		}
+		fmt.Fprintln(outfd)
+	}
+	if balance != nil && assertat < 0 {
+		fmsg := "; not asserted %v, ledger balance %v as on %v\n\n"
+		date := balance.date.Format("2006/01/02")
+		fmt.Fprintf(outfd, fmsg, balance.source, balance.amount, date)
+	}
+}
+
+func (report *ReportImport) Clone() api.Reporter {
+	nreport := *report
+	nreport.index = newdedupindex()
+	return &nreport
+}
+
+func (report *ReportImport) Startjournal(fname string, included bool) {
+	panic("not implemented")
+}
+
+//---- local functions
+
+// translines format statement entry as transaction, in the same layout
+// as `print` command. Counter account is resolved by payee's alias or uuid,
+// and account's `payee` directive, else it is left as uncategorized. If
+// balance is supplied, account's posting asserts the balance.
+func (report *ReportImport) translines(
+	db *dblentry.Datastore, entry *importentry,
+	balance api.Commoditiser) []string {
+
+	payee, ok := db.Matchpayee(entry.payee, entry.uuid)
+	if ok == false {
+		payee = entry.payee
+	}
+	accname := db.Lookupaccount(api.Options.Importacc)
+	counter, ok := db.Matchaccount(payee)
+	if ok == false && entry.amount.IsCredit() {
+		counter = importExpense
+	} else if ok == false {
+		counter = importIncome
+	}
+
+	names := []string{counter, accname}
+	amounts := []string{
+		entry.amount.MakeSimilar(entry.amount.Amount().Neg()).String(),
+		entry.amount.String(),
+	}
+	w0 := len(names[0])
+	if len(names[1]) > w0 {
+		w0 = len(names[1])
+	}
+	w1 := len(amounts[0])
+	if len(amounts[1]) > w1 {
+		w1 = len(amounts[1])
+	}
+
+	lines := []string{
+		entry.date.Format("2006/01/02") + " " + payee,
+		"    ; uuid: " + entry.uuid,
+	}
+	for i, name := range names {
+		line := fmt.Sprintf("    %-*s    %*s", w0, name, w1, amounts[i])
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	if balance != nil {
+		balance = entry.amount.MakeSimilar(balance.Amount())
+		lines[len(lines)-1] += " = " + balance.String()
+	}
+	return lines
+}
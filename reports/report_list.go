@@ -51,14 +51,14 @@ func (report *ReportList) Render(args []string, ndb api.Datastorer) {
 
 	switch args[1] {
 	case "accounts", "acc":
-		if api.Options.Verbose == false {
+		if api.Options.Verbose == false || report.rcf.formatted() {
 			report.listAccounts(args[2:], ndb)
 		} else {
 			report.listAccountsV(args[2:], ndb)
 		}
 
 	case "commodities", "commodity", "comm":
-		if api.Options.Verbose == false {
+		if api.Options.Verbose == false || report.rcf.formatted() {
 			report.listCommodities(args[2:], ndb)
 		} else {
 			report.listCommoditiesV(args[2:], ndb)
@@ -90,10 +90,17 @@ func (report *ReportList) listAccounts(args []string, ndb api.Datastorer) {
 			return
 		}
 		fe, _ = node.(*api.Filterexpr)
+		if fe != nil && fe.Predicates() {
+			log.Errorf("filter %q: predicates not allowed\n", filterarg)
+			return
+		}
 		log.Consolef("filter expr: %v\n", fe)
 	}
 
 	rcf := report.rcf
+	if rcf.formatted() {
+		rcf.addheader("Account", "Note")
+	}
 	for _, accname := range ndb.Accountnames() {
 		if fe != nil && fe.Match(accname) == false {
 			continue
@@ -113,6 +120,11 @@ func (report *ReportList) listAccounts(args []string, ndb api.Datastorer) {
 		}
 	}
 
+	if rcf.formatted() {
+		rcf.render(ndb, 0)
+		return
+	}
+
 	rcf.paddcells()
 	fmsg := rcf.Fmsg(" %%-%vs%%-%vs\n")
 
@@ -139,6 +151,10 @@ func (report *ReportList) listAccountsV(args []string, ndb api.Datastorer) {
 			return
 		}
 		fe, _ = node.(*api.Filterexpr)
+		if fe != nil && fe.Predicates() {
+			log.Errorf("filter %q: predicates not allowed\n", filterarg)
+			return
+		}
 		//log.Consolef("filter expr: %v\n", fe)
 	}
 
@@ -169,10 +185,17 @@ func (report *ReportList) listCommodities(args []string, ndb api.Datastorer) {
 			return
 		}
 		fe, _ = node.(*api.Filterexpr)
+		if fe != nil && fe.Predicates() {
+			log.Errorf("filter %q: predicates not allowed\n", filterarg)
+			return
+		}
 		//log.Consolef("filter expr: %v\n", fe)
 	}
 
 	rcf := report.rcf
+	if rcf.formatted() {
+		rcf.addheader("Commodity", "Note")
+	}
 	for _, commdname := range ndb.Commoditynames() {
 		if fe != nil && fe.Match(commdname) == false {
 			continue
@@ -192,6 +215,11 @@ func (report *ReportList) listCommodities(args []string, ndb api.Datastorer) {
 		}
 	}
 
+	if rcf.formatted() {
+		rcf.render(ndb, 0)
+		return
+	}
+
 	rcf.paddcells()
 	fmsg := rcf.Fmsg(" %%-%vs%%-%vs\n")
 
@@ -218,6 +246,10 @@ func (report *ReportList) listCommoditiesV(args []string, ndb api.Datastorer) {
 			return
 		}
 		fe, _ = node.(*api.Filterexpr)
+		if fe != nil && fe.Predicates() {
+			log.Errorf("filter %q: predicates not allowed\n", filterarg)
+			return
+		}
 		//log.Consolef("filter expr: %v\n", fe)
 	}
 
@@ -5,6 +5,7 @@ import "sort"
 import "time"
 import "strings"
 
+import "github.com/prataprc/goparsec"
 import "github.com/bnclabs/golog"
 import "github.com/tn47/goledger/api"
 import "github.com/tn47/goledger/dblentry"
@@ -12,8 +13,10 @@ import "github.com/tn47/goledger/dblentry"
 type ReportPassbook struct {
 	rcf     *RCformat
 	accname string
+	fe      *api.Filterexpr
+	tally   *dblentry.Account // balance of postings matching filter.
 	// common to all mapreduce
-	postings [][]string
+	postings [][]interface{}
 	// mapreduce-2
 	findates map[string]*time.Time            // payee -> time
 	payees   map[string]*dblentry.DoubleEntry // payee -> de
@@ -23,7 +26,7 @@ type ReportPassbook struct {
 func NewReportPassbook(args []string) (*ReportPassbook, error) {
 	report := &ReportPassbook{
 		rcf:      NewRCformat(),
-		postings: make([][]string, 0),
+		postings: make([][]interface{}, 0),
 		findates: make(map[string]*time.Time),
 		payees:   make(map[string]*dblentry.DoubleEntry),
 	}
@@ -34,6 +37,17 @@ func NewReportPassbook(args []string) (*ReportPassbook, error) {
 		return nil, err
 	}
 	report.accname = strings.Trim(args[1], " \t")
+	if len(args) > 2 {
+		filterarg := api.MakeFilterexpr(args[2:])
+		node, _ := api.YFilterExpr(parsec.NewScanner([]byte(filterarg)))
+		if err, ok := node.(error); ok {
+			fmsg := "filter %q expression failed: %v\n"
+			log.Errorf(fmsg, filterarg, err)
+			return nil, err
+		}
+		report.fe = node.(*api.Filterexpr)
+		report.tally = dblentry.NewAccount(report.accname)
+	}
 	return report, nil
 }
 
@@ -56,6 +70,8 @@ func (report *ReportPassbook) Posting(
 
 	if api.FilterPeriod(trans.Date(), false /*nobegin*/) == false {
 		return nil
+	} else if report.fe != nil && report.fe.MatchPosting(trans, p) == false {
+		return nil
 	}
 
 	if api.Options.Bypayee {
@@ -81,7 +97,10 @@ func (report *ReportPassbook) Render(args []string, db api.Datastorer) {
 func (report *ReportPassbook) Clone() api.Reporter {
 	nreport := *report
 	nreport.rcf = report.rcf.Clone()
-	nreport.postings = make([][]string, 0, len(report.postings))
+	if report.tally != nil {
+		nreport.tally = dblentry.NewAccount(report.accname)
+	}
+	nreport.postings = make([][]interface{}, 0, len(report.postings))
 	for _, posting := range report.postings {
 		nreport.postings = append(nreport.postings, posting)
 	}
@@ -97,6 +116,12 @@ func (report *ReportPassbook) mapreduce1(
 
 	acc := p.Account()
 	if acc.Name() == report.accname {
+		if report.tally != nil {
+			if err := report.tally.Tally(p); err != nil {
+				return err
+			}
+			acc = report.tally
+		}
 		rows := acc.FmtPassbook(db, trans, p, acc)
 		report.postings = append(report.postings, rows...)
 	}
@@ -132,17 +157,18 @@ func (report *ReportPassbook) prerender2(args []string, db api.Datastorer) {
 
 	report.de = dblentry.NewDoubleEntry("passbook")
 	for _, payee := range payees {
-		de, balrows, balnames := report.payees[payee], [][]string{}, []string{}
+		de, balnames := report.payees[payee], []string{}
+		balrows := [][]interface{}{}
 		for _, bal := range de.Balances() {
-			cols := []string{"", ""} // date, payee
+			cols := []interface{}{"", ""} // date, payee
 			if bal.IsDebit() {
-				cols = append(cols, bal.String(), "", "")
+				cols = append(cols, rcamount(bal), "", "")
 			} else if bal.IsCredit() {
-				amtstr := bal.MakeSimilar(-bal.Amount()).String()
-				cols = append(cols, "", amtstr, "")
+				amount := bal.MakeSimilar(bal.Amount().Neg())
+				cols = append(cols, "", amount, "")
 			}
 			report.de.AddBalance(bal)
-			cols[len(cols)-1] = report.de.Balance(bal.Name()).String()
+			cols[len(cols)-1] = rcamount(report.de.Balance(bal.Name()))
 			balnames = append(balnames, bal.Name())
 			balrows = append(balrows, cols)
 		}
@@ -150,10 +176,11 @@ func (report *ReportPassbook) prerender2(args []string, db api.Datastorer) {
 			if api.HasString(balnames, bal.Name()) {
 				continue
 			}
-			balrows = append(balrows, []string{"", "", "", "", bal.String()})
+			cols := []interface{}{"", "", "", "", rcamount(bal)}
+			balrows = append(balrows, cols)
 		}
 		if len(balrows) > 0 {
-			balrows[0][0] = report.findates[payee].Format("2006-Jan-02")
+			balrows[0][0] = rcdate{*report.findates[payee], "2006-Jan-02"}
 			balrows[0][1] = payee
 		}
 		report.postings = append(report.postings, balrows...)
@@ -164,11 +191,16 @@ func (report *ReportPassbook) render1(args []string, db api.Datastorer) {
 	rcf := report.rcf
 
 	cols := []string{"By-date", "Payee", "Debit", "Credit", "Balance"}
-	rcf.addrow(cols...)
+	rcf.addheader(cols...)
 	rcf.addrow([]string{"", "", "", "", ""}...)
 
 	for _, cols := range report.postings {
-		report.rcf.addrow(cols...)
+		report.rcf.addcells(cols...)
+	}
+
+	if rcf.formatted() {
+		rcf.render(db, 0, 1)
+		return
 	}
 
 	w0 := rcf.maxwidth(rcf.column(0)) // Date
@@ -27,6 +27,10 @@ func (report *ReportPrint) Firstpass(
 func (report *ReportPrint) Transaction(
 	_ api.Datastorer, trans api.Transactor) error {
 
+	if trans.(*dblentry.Transaction).IsForecast() {
+		return nil
+	}
+
 	date := trans.Date()
 	if dt := api.Options.Begindt; dt != nil && date.Before(*dt) {
 		return nil
@@ -55,7 +59,11 @@ func (report *ReportPrint) Render(args []string, ndb api.Datastorer) {
 	entries := []api.TimeEntry{}
 	for _, entry := range report.transdb.Range(nil, nil, "both", entries) {
 		trans := entry.Value().(api.Transactor)
-		for _, line := range trans.Printlines() {
+		lines := trans.Printlines()
+		if api.Options.Real {
+			lines = trans.(*dblentry.Transaction).Reallines()
+		}
+		for _, line := range lines {
 			fmt.Fprintln(outfd, line)
 		}
 		fmt.Fprintln(outfd)
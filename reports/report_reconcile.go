@@ -0,0 +1,362 @@
+package reports
+
+import "os"
+import "fmt"
+import "sort"
+import "bufio"
+import "regexp"
+import "strings"
+import "io/ioutil"
+
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+
+// maximum number of partial sums to explore while matching uncleared
+// postings with statement balance.
+const reconcileMaxsums = 1 << 20
+
+var restatement = regexp.MustCompile(
+	`^\s*([^0-9.,\s-]*)\s*(-?[0-9,]*\.?[0-9]+)\s*([^0-9.,\s-]*)\s*$`,
+)
+
+// ReportReconcile match uncleared postings of an account with the
+// statement balance, and mark the matching postings as cleared in the
+// journal.
+type ReportReconcile struct {
+	rcf       *RCformat
+	accname   string
+	commname  string // commodity of statement balance
+	stmtbal   api.Decimal
+	cleared   *dblentry.DoubleEntry
+	uncleared []*dblentry.Posting
+}
+
+// NewReportReconcile create an instance to reconcile account.
+func NewReportReconcile(args []string) (*ReportReconcile, error) {
+	if len(args) != 2 {
+		err := fmt.Errorf("reconcile expects an account name")
+		log.Errorf("%v\n", err)
+		return nil, err
+	}
+	commname, amount, err := parsestatement(api.Options.Stmtbal)
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return nil, err
+	}
+	report := &ReportReconcile{
+		rcf:       NewRCformat(),
+		accname:   args[1],
+		commname:  commname,
+		stmtbal:   amount,
+		cleared:   dblentry.NewDoubleEntry("cleared"),
+		uncleared: []*dblentry.Posting{},
+	}
+	return report, nil
+}
+
+//---- api.Reporter methods
+
+func (report *ReportReconcile) Firstpass(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportReconcile) Transaction(
+	db api.Datastorer, trans api.Transactor) error {
+
+	return nil
+}
+
+func (report *ReportReconcile) Posting(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	if p.Account().Name() != report.accname {
+		return nil
+	} else if trans.(*dblentry.Transaction).IsForecast() {
+		return nil
+	} else if dt := api.Options.Stmtdate; dt != nil && trans.Date().After(*dt) {
+		return nil
+	}
+
+	comm := p.Commodity()
+	if report.commname == "" {
+		report.commname = comm.Name()
+	} else if comm.Name() != report.commname {
+		return nil
+	}
+	if p.State() == dblentry.PostCleared {
+		report.cleared.AddBalance(comm)
+		return nil
+	}
+	// implied postings, like the ones injected by automated transactions
+	// or by autobalance, have no journal line to mark as cleared.
+	if posting := p.(*dblentry.Posting); posting.Lineno() > 0 {
+		report.uncleared = append(report.uncleared, posting)
+	}
+	return nil
+}
+
+func (report *ReportReconcile) BubblePosting(
+	db api.Datastorer, trans api.Transactor,
+	p api.Poster, account api.Accounter) error {
+
+	return nil
+}
+
+func (report *ReportReconcile) Render(args []string, db api.Datastorer) {
+	comm := db.GetCommodity(report.commname)
+	cleared := comm.MakeSimilar(api.Decimal{})
+	if bal := report.cleared.Balance(report.commname); bal != nil {
+		cleared = bal
+	}
+	stmtbal := comm.MakeSimilar(report.stmtbal)
+	diff := comm.MakeSimilar(stmtbal.Amount().Sub(cleared.Amount()))
+
+	// match amounts in units of commodity's precision, widened for
+	// amounts with more digits, so that nothing is rounded away.
+	precision := api.Maxints(0, comm.(*dblentry.Commodity).Precision())
+	precision = api.Maxints(precision, diff.Amount().Precision())
+	for _, p := range report.uncleared {
+		precision = api.Maxints(precision, p.Commodity().Amount().Precision())
+	}
+	goal, ok := diff.Amount().Scaled(precision)
+	amounts := []int64{}
+	for _, p := range report.uncleared {
+		amount, fits := p.Commodity().Amount().Scaled(precision)
+		amounts, ok = append(amounts, amount), ok && fits
+	}
+	if ok == false {
+		log.Errorf("amounts too large to reconcile\n")
+		return
+	}
+
+	matched, ok := subsetsum(amounts, goal)
+	marks := map[int]bool{}
+	for _, index := range matched {
+		marks[index] = true
+	}
+
+	report.render1(db, marks)
+
+	outfd := api.Options.Outfd
+	fmt.Fprintf(outfd, " Cleared balance    %v\n", cleared)
+	fmt.Fprintf(outfd, " Statement balance  %v\n", stmtbal)
+	fmt.Fprintf(outfd, " Difference         %v\n", diff)
+	fmt.Fprintln(outfd)
+
+	if ok == false {
+		fmsg := "no uncleared postings add upto the difference %v\n"
+		log.Errorf(fmsg, diff)
+		return
+	} else if len(matched) == 0 {
+		fmt.Fprintf(outfd, "nothing to reconcile\n")
+		return
+	}
+
+	fmsg := "mark %v postings as cleared ? [y/N] "
+	fmt.Fprintf(outfd, fmsg, len(matched))
+	if confirm() == false {
+		fmt.Fprintln(outfd)
+		return
+	}
+	postings := []*dblentry.Posting{}
+	for _, index := range matched {
+		postings = append(postings, report.uncleared[index])
+	}
+	if err := markcleared(postings); err != nil {
+		log.Errorf("%v\n", err)
+		return
+	}
+	fmt.Fprintf(outfd, "\nmarked %v postings as cleared\n", len(postings))
+}
+
+func (report *ReportReconcile) Clone() api.Reporter {
+	nreport := *report
+	nreport.rcf = report.rcf.Clone()
+	nreport.cleared = dblentry.NewDoubleEntry("cleared")
+	nreport.uncleared = []*dblentry.Posting{}
+	return &nreport
+}
+
+func (report *ReportReconcile) Startjournal(fname string, included bool) {
+	panic("not implemented")
+}
+
+//---- local functions
+
+func (report *ReportReconcile) render1(db api.Datastorer, marks map[int]bool) {
+	rcf := report.rcf
+
+	rcf.addrow([]string{"By-date", "Payee", "Amount", "Match"}...)
+	rcf.addrow([]string{"", "", "", ""}...)
+	for index, p := range report.uncleared {
+		mark := ""
+		if marks[index] {
+			mark = "*"
+		}
+		date := p.Transaction().Date().Format("2006/Jan/02")
+		rcf.addrow(date, p.Payee(), p.Commodity().String(), mark)
+	}
+
+	w0 := rcf.maxwidth(rcf.column(0)) // Date
+	w1 := rcf.maxwidth(rcf.column(1)) // Payee
+	w2 := rcf.maxwidth(rcf.column(2)) // Amount
+	w3 := rcf.maxwidth(rcf.column(3)) // Match
+	if (w0 + w1 + w2 + w3) > 125 {
+		_ /*w1*/ = rcf.FitPayee(1, 125-w0-w2-w3)
+	}
+
+	rcf.paddcells()
+	fmsg := rcf.Fmsg(" %%-%vs%%-%vs%%%vs%%%vs\n")
+	comm := dblentry.NewCommodity("")
+
+	// start printing
+	outfd := api.Options.Outfd
+	fmt.Fprintln(outfd)
+	for i, cols := range rcf.rows {
+		items := []interface{}{cols[0], cols[1]}
+		if i < 2 {
+			items = append(items, cols[2], cols[3])
+		} else {
+			items = append(items, CommodityColor(db, comm, cols[2]), cols[3])
+		}
+		fmt.Fprintf(outfd, fmsg, items...)
+	}
+	fmt.Fprintln(outfd)
+}
+
+// parsestatement parse statement balance like `$1,234.50` or `100 EUR`.
+func parsestatement(text string) (string, api.Decimal, error) {
+	matches := restatement.FindStringSubmatch(text)
+	if matches == nil {
+		err := fmt.Errorf("invalid statement balance %q", text)
+		return "", api.Decimal{}, err
+	}
+	amount := strings.Replace(matches[2], ",", "", -1)
+	value, err := api.ParseDecimal(amount)
+	if err != nil {
+		return "", api.Decimal{}, err
+	}
+	commname := matches[1]
+	if commname == "" {
+		commname = matches[3]
+	}
+	return commname, value, nil
+}
+
+type partialsum struct {
+	prev  int64
+	index int
+}
+
+// subsetsum return indices of amounts that add upto target, preferring
+// earlier amounts. Return false if no such subset is found.
+func subsetsum(amounts []int64, goal int64) ([]int, bool) {
+	sums := map[int64]partialsum{0: {prev: 0, index: -1}}
+	for index := 0; index < len(amounts) && !hassum(sums, goal); index++ {
+		keys := make([]int64, 0, len(sums))
+		for sum := range sums {
+			keys = append(keys, sum)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		amount := amounts[index]
+		for _, sum := range keys {
+			if _, ok := sums[sum+amount]; ok == false {
+				sums[sum+amount] = partialsum{prev: sum, index: index}
+			}
+		}
+		if len(sums) > reconcileMaxsums {
+			log.Warnf("too many uncleared postings to reconcile\n")
+			return nil, false
+		}
+	}
+	if hassum(sums, goal) == false {
+		return nil, false
+	}
+
+	indices := []int{}
+	for sum := goal; sums[sum].index >= 0; sum = sums[sum].prev {
+		indices = append(indices, sums[sum].index)
+	}
+	sort.Ints(indices)
+	return indices, true
+}
+
+func hassum(sums map[int64]partialsum, sum int64) bool {
+	_, ok := sums[sum]
+	return ok
+}
+
+func confirm() bool {
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// markcleared prefix posting lines with `*` in their journal files. Every
+// journal file is backed up, with `.bak` extension, before updating. No
+// journal file is updated unless all postings are located.
+func markcleared(postings []*dblentry.Posting) error {
+	byjournal := map[string][]*dblentry.Posting{}
+	journals := []string{}
+	for _, p := range postings {
+		journalfile := p.Transaction().Journalfile()
+		if _, ok := byjournal[journalfile]; ok == false {
+			journals = append(journals, journalfile)
+		}
+		byjournal[journalfile] = append(byjournal[journalfile], p)
+	}
+
+	contents := map[string][]byte{}
+	for _, journalfile := range journals {
+		data, err := ioutil.ReadFile(journalfile)
+		if err != nil {
+			return err
+		}
+		nlines := len(strings.Split(string(data), "\n"))
+		for _, p := range byjournal[journalfile] {
+			if lineno := p.Lineno(); lineno <= 0 || lineno > nlines {
+				fmsg := "posting %v %v is not in %q"
+				return fmt.Errorf(fmsg, p.Account(), p.Commodity(), journalfile)
+			}
+		}
+		contents[journalfile] = data
+	}
+
+	for _, journalfile := range journals {
+		data := contents[journalfile]
+		lines := strings.Split(string(data), "\n")
+		for _, p := range byjournal[journalfile] {
+			lineno := p.Lineno()
+			lines[lineno-1] = markline(lines[lineno-1])
+		}
+
+		info, err := os.Stat(journalfile)
+		if err != nil {
+			return err
+		}
+		backup := journalfile + ".bak"
+		if err := ioutil.WriteFile(backup, data, info.Mode()); err != nil {
+			return err
+		}
+		newdata := []byte(strings.Join(lines, "\n"))
+		if err := ioutil.WriteFile(journalfile, newdata, info.Mode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markline mark the posting line as cleared, replacing pending mark.
+func markline(line string) string {
+	posting := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(posting)]
+	if strings.HasPrefix(posting, "*") {
+		return line
+	} else if strings.HasPrefix(posting, "!") {
+		posting = strings.TrimLeft(posting[1:], " \t")
+	}
+	return indent + "* " + posting
+}
@@ -6,10 +6,15 @@ import "sort"
 
 import "github.com/prataprc/goparsec"
 
-//import "github.com/bnclabs/golog"
+import "github.com/bnclabs/golog"
 import "github.com/tn47/goledger/api"
 import "github.com/tn47/goledger/dblentry"
 
+var statemarks = map[string]string{
+	dblentry.PostCleared: "*",
+	dblentry.PostPending: "!",
+}
+
 // ReportRegister for register reporting.
 type ReportRegister struct {
 	rcf *RCformat
@@ -17,8 +22,11 @@ type ReportRegister struct {
 	pfe *api.Filterexpr
 	// common for all map-reduce
 	lastcomm api.Commoditiser
-	register [][]string
+	register [][]interface{}
+	states   []string // state of each row in register, for -detailed
 	de       *dblentry.DoubleEntry
+	market   *marketvalue
+	costde   *dblentry.DoubleEntry
 	// mapreduce-2
 	begindt, enddt *time.Time
 	accounts       map[string]*dblentry.DoubleEntry
@@ -26,7 +34,7 @@ type ReportRegister struct {
 	findates map[string]*time.Time                       // payee -> date string
 	payees   map[string]map[string]*dblentry.DoubleEntry // payee -> accounts
 	// mapreduce-4
-	dailytm []string                                    // array of datestr
+	dailytm []time.Time                                 // array of dates
 	daily   map[string]map[string]*dblentry.DoubleEntry // datestr -> accounts
 	// mapreduce-5 year->week->account->de
 	weeklytm map[int]map[int][2]*time.Time
@@ -45,8 +53,11 @@ type ReportRegister struct {
 func NewReportRegister(args []string) (*ReportRegister, error) {
 	report := &ReportRegister{
 		rcf:       NewRCformat(),
-		register:  make([][]string, 0),
+		register:  make([][]interface{}, 0),
+		states:    make([]string, 0),
 		de:        dblentry.NewDoubleEntry("regbalance"),
+		market:    newmarketvalue(),
+		costde:    dblentry.NewDoubleEntry("regcost"),
 		lastcomm:  dblentry.NewCommodity(""),
 		accounts:  make(map[string]*dblentry.DoubleEntry),
 		findates:  make(map[string]*time.Time),
@@ -66,6 +77,8 @@ func NewReportRegister(args []string) (*ReportRegister, error) {
 			filterarg := api.MakeFilterexpr(args[i+1:])
 			node, _ := api.YFilterExpr(parsec.NewScanner([]byte(filterarg)))
 			if err, ok := node.(error); ok {
+				fmsg := "filter %q expression failed: %v\n"
+				log.Errorf(fmsg, filterarg, err)
 				return nil, err
 			}
 			report.pfe = node.(*api.Filterexpr)
@@ -78,6 +91,8 @@ func NewReportRegister(args []string) (*ReportRegister, error) {
 		filterarg := api.MakeFilterexpr(filteraccounts)
 		node, _ := api.YFilterExpr(parsec.NewScanner([]byte(filterarg)))
 		if err, ok := node.(error); ok {
+			fmsg := "filter %q expression failed: %v\n"
+			log.Errorf(fmsg, filterarg, err)
 			return nil, err
 		}
 		report.fe = node.(*api.Filterexpr)
@@ -169,7 +184,10 @@ func (report *ReportRegister) Render(args []string, db api.Datastorer) {
 		return
 	}
 
-	if api.Options.Dcformat == false {
+	if api.Options.Dcformat == false && api.Options.Market {
+		report.render5(args, db)
+		return
+	} else if api.Options.Dcformat == false {
 		report.render1(args, db)
 		return
 	}
@@ -181,7 +199,8 @@ func (report *ReportRegister) Clone() api.Reporter {
 	nreport.rcf = report.rcf.Clone()
 	nreport.pfe = report.pfe
 	nreport.fe = report.fe
-	nreport.register = make([][]string, 0)
+	nreport.register = make([][]interface{}, 0)
+	nreport.states = make([]string, 0)
 	return &nreport
 }
 
@@ -193,81 +212,105 @@ func (report *ReportRegister) Startjournal(fname string, included bool) {
 func (report *ReportRegister) mapreduce1(
 	db api.Datastorer, trans api.Transactor) error {
 
-	date, transpayee := trans.Date().Format("2006-Jan-02"), trans.Payee()
+	var date interface{} = rcdate{trans.Date(), "2006-Jan-02"}
+	transpayee := trans.Payee()
 	filterfn := report.matchAccOrPayee(trans)
 	for _, p := range trans.GetPostings() {
 		if filterfn(p) == false {
 			continue
 		}
-		accname, comm := p.Account().Name(), p.Commodity()
-		cols := []string{date, transpayee, accname}
+		accname, comm := p.Account().Name(), report.postvalue(db, p)
+		cols := []interface{}{date, transpayee, accname}
 		if api.Options.Dcformat == false {
-			cols = append(cols, comm.String(), "")
+			cols = append(cols, rcamount(comm), "")
 		} else if comm.IsDebit() {
-			cols = append(cols, comm.String(), "", "")
+			cols = append(cols, rcamount(comm), "", "")
 		} else if comm.IsCredit() {
-			amount := -comm.Amount()
-			cols = append(cols, "", comm.MakeSimilar(amount).String(), "")
+			amount := comm.Amount().Neg()
+			cols = append(cols, "", comm.MakeSimilar(amount), "")
 		}
 		if p.Payee() != trans.Payee() {
 			cols[1] = p.Payee()
 		}
 		date, transpayee = "", ""
 		report.de.AddBalance(comm) // should come before fillbalances
-		var rows [][]string
+		var rows [][]interface{}
 		if api.Options.Dcformat {
 			rows = report.fillbalancesDc(cols)
+		} else if api.Options.Market {
+			report.costde.AddBalance(report.market.costbasis(db, trans, p))
+			rows = report.fillbalancesMarket(db, cols)
 		} else {
 			rows = report.fillbalances(cols)
 		}
 		report.register = append(report.register, rows...)
+		mark := statemarks[p.State()]
+		for range rows {
+			report.states = append(report.states, mark)
+			mark = ""
+		}
 	}
 	return nil
 }
 
-func (report *ReportRegister) fillbalances(cols []string) [][]string {
+func (report *ReportRegister) fillbalances(cols []interface{}) [][]interface{} {
 	balances := report.de.Balances()
 	if len(balances) == 0 {
-		return [][]string{cols}
+		return [][]interface{}{cols}
 	}
 
 	date, payee, accname, amount := cols[0], cols[1], cols[2], cols[3]
-	rows := [][]string{}
+	rows := [][]interface{}{}
 	for _, balance := range balances {
-		if balance.Amount() == 0 {
+		if balance.Amount().IsZero() {
 			continue
 		}
-		cols := []string{date, payee, accname, amount, balance.String()}
+		cols := []interface{}{date, payee, accname, amount, rcamount(balance)}
 		rows = append(rows, cols)
 		date, payee, accname, amount = "", "", "", ""
 		report.lastcomm = balance
 	}
 	if len(rows) == 0 {
-		cols := []string{date, payee, accname, amount, report.lastcomm.String()}
+		lastcomm := rcamount(report.lastcomm)
+		cols := []interface{}{date, payee, accname, amount, lastcomm}
 		rows = append(rows, cols)
 	}
 	return rows
 }
 
-func (report *ReportRegister) fillbalancesDc(cols []string) [][]string {
+func (report *ReportRegister) fillbalancesMarket(
+	db api.Datastorer, cols []interface{}) [][]interface{} {
+
+	rows := report.fillbalances(cols)
+	for i := range rows {
+		rows[i] = append(rows[i], "") // gain column
+	}
+	rows[len(rows)-1][5] = report.market.gain(db, report.de, report.costde)
+	return rows
+}
+
+func (report *ReportRegister) fillbalancesDc(
+	cols []interface{}) [][]interface{} {
+
 	balances := report.de.Balances()
 	if len(balances) == 0 {
-		return [][]string{cols}
+		return [][]interface{}{cols}
 	}
 
 	date, payee, accname, dr, cr := cols[0], cols[1], cols[2], cols[3], cols[4]
-	rows := [][]string{}
+	rows := [][]interface{}{}
 	for _, balance := range balances {
-		if balance.Amount() == 0 {
+		if balance.Amount().IsZero() {
 			continue
 		}
-		cols := []string{date, payee, accname, dr, cr, balance.String()}
+		cols := []interface{}{date, payee, accname, dr, cr, rcamount(balance)}
 		rows = append(rows, cols)
 		date, payee, accname, dr, cr = "", "", "", "", ""
 		report.lastcomm = balance
 	}
 	if len(rows) == 0 {
-		cols := []string{date, payee, accname, dr, cr, report.lastcomm.String()}
+		lastcomm := rcamount(report.lastcomm)
+		cols := []interface{}{date, payee, accname, dr, cr, lastcomm}
 		rows = append(rows, cols)
 	}
 	return rows
@@ -294,7 +337,7 @@ func (report *ReportRegister) mapreduce2(
 		if _, ok := report.accounts[accname]; ok == false {
 			report.accounts[accname] = dblentry.NewDoubleEntry(accname)
 		}
-		report.accounts[accname].AddBalance(p.Commodity())
+		report.accounts[accname].AddBalance(report.postvalue(db, p))
 	}
 
 	return nil
@@ -324,7 +367,7 @@ func (report *ReportRegister) mapreduce3(
 			accde = dblentry.NewDoubleEntry(payee + "/" + accname)
 			accounts[accname] = accde
 		}
-		accde.AddBalance(p.Commodity())
+		accde.AddBalance(report.postvalue(db, p))
 	}
 	return nil
 }
@@ -344,14 +387,14 @@ func (report *ReportRegister) mapreduce4(
 		if ok == false {
 			accounts = make(map[string]*dblentry.DoubleEntry)
 			report.daily[datestr] = accounts
-			report.dailytm = append(report.dailytm, datestr)
+			report.dailytm = append(report.dailytm, trans.Date())
 		}
 		accde, ok := accounts[accname]
 		if ok == false {
 			accde = dblentry.NewDoubleEntry(datestr + "/" + accname)
 			accounts[accname] = accde
 		}
-		accde.AddBalance(p.Commodity())
+		accde.AddBalance(report.postvalue(db, p))
 	}
 	return nil
 }
@@ -385,7 +428,7 @@ func (report *ReportRegister) mapreduce5(
 			accde = dblentry.NewDoubleEntry(fmt.Sprintf("%v/%v", year, week))
 			accounts[accname] = accde
 		}
-		accde.AddBalance(p.Commodity())
+		accde.AddBalance(report.postvalue(db, p))
 		be := report.weeklytm[year][week]
 		if be[0] == nil || be[0].After(date) {
 			be[0] = &date
@@ -425,7 +468,7 @@ func (report *ReportRegister) mapreduce6(
 			accde = dblentry.NewDoubleEntry(fmt.Sprintf("%v/%v", year, month))
 			accounts[accname] = accde
 		}
-		accde.AddBalance(p.Commodity())
+		accde.AddBalance(report.postvalue(db, p))
 	}
 	return nil
 }
@@ -457,7 +500,7 @@ func (report *ReportRegister) mapreduce7(
 			accde = dblentry.NewDoubleEntry(fmt.Sprintf("%v/%v", year, quarter))
 			accounts[accname] = accde
 		}
-		accde.AddBalance(p.Commodity())
+		accde.AddBalance(report.postvalue(db, p))
 	}
 	return nil
 }
@@ -483,7 +526,7 @@ func (report *ReportRegister) mapreduce8(
 			accde = dblentry.NewDoubleEntry(fmt.Sprintf("%v", year))
 			accounts[accname] = accde
 		}
-		accde.AddBalance(p.Commodity())
+		accde.AddBalance(report.postvalue(db, p))
 	}
 	return nil
 }
@@ -509,32 +552,66 @@ func (report *ReportRegister) mapreduce9(
 			accde = dblentry.NewDoubleEntry(fmt.Sprintf("%v", dow))
 			accounts[accname] = accde
 		}
-		accde.AddBalance(p.Commodity())
+		accde.AddBalance(report.postvalue(db, p))
 	}
 	return nil
 }
 
+// postvalue return posting's commodity, or its market value with -market
+// option.
+func (report *ReportRegister) postvalue(
+	db api.Datastorer, p api.Poster) api.Commoditiser {
+
+	if api.Options.Market {
+		return report.market.value(db, p.Commodity())
+	}
+	return p.Commodity()
+}
+
 func (report *ReportRegister) matchAccOrPayee(
 	trans api.Transactor) func(p api.Poster) bool {
 
 	matchtrans := false
 	for _, p := range trans.GetPostings() {
-		accname, payee := p.Account().Name(), p.Payee()
-		accok := report.isfilteracc() == false || report.fe.Match(accname)
+		if api.FilterState(p.State()) == false {
+			continue
+		} else if api.FilterReal(p.IsVirtual()) == false {
+			continue
+		}
+		payee := p.Payee()
+		accok := report.isfilteracc() == false ||
+			report.fe.MatchPosting(trans, p)
 		payeeok := report.isfilterpayee() == false || report.pfe.Match(payee)
 		matchtrans = matchtrans || (accok && payeeok)
 	}
 	return func(p api.Poster) bool {
-		if api.Options.Detailed && matchtrans {
+		if api.FilterState(p.State()) == false {
+			return false
+		} else if api.FilterReal(p.IsVirtual()) == false {
+			return false
+		} else if api.Options.Detailed && matchtrans {
 			return true
 		}
-		accname, payee := p.Account().Name(), p.Payee()
-		accok := report.isfilteracc() == false || report.fe.Match(accname)
+		payee := p.Payee()
+		accok := report.isfilteracc() == false ||
+			report.fe.MatchPosting(trans, p)
 		payeeok := report.isfilterpayee() == false || report.pfe.Match(payee)
 		return accok && payeeok
 	}
 }
 
+// stateprefix return the state column for row, with -detailed.
+func (report *ReportRegister) stateprefix(row int) string {
+	if api.Options.Detailed == false {
+		return ""
+	} else if row == 0 {
+		return " St"
+	} else if row < 2 || row-2 >= len(report.states) {
+		return "   "
+	}
+	return fmt.Sprintf(" %-2s", report.states[row-2])
+}
+
 func (report *ReportRegister) isfilteracc() bool {
 	return report.fe != nil
 }
@@ -547,11 +624,16 @@ func (report *ReportRegister) render1(args []string, db api.Datastorer) {
 	rcf := report.rcf
 
 	cols := []string{"By-date", "Payee", "Account", "Amount", "Balance"}
-	rcf.addrow(cols...)
+	rcf.addheader(cols...)
 	rcf.addrow([]string{"", "", "", "", ""}...)
 
 	for _, cols := range report.register {
-		report.rcf.addrow(cols...)
+		report.rcf.addcells(cols...)
+	}
+
+	if rcf.formatted() {
+		rcf.render(db, 0, 1)
+		return
 	}
 
 	w0 := rcf.maxwidth(rcf.column(0)) // Date
@@ -583,6 +665,61 @@ func (report *ReportRegister) render1(args []string, db api.Datastorer) {
 			y := CommodityColor(db, comm2, cols[4])
 			items = append(items, api.YellowFn(cols[2]), x, y)
 		}
+		fmt.Fprint(outfd, report.stateprefix(i))
+		fmt.Fprintf(outfd, fmsg, items...)
+	}
+	fmt.Fprintln(outfd)
+}
+
+func (report *ReportRegister) render5(args []string, db api.Datastorer) {
+	rcf := report.rcf
+
+	cols := []string{"By-date", "Payee", "Account", "Value", "Balance", "Gain"}
+	rcf.addheader(cols...)
+	rcf.addrow([]string{"", "", "", "", "", ""}...)
+
+	for _, cols := range report.register {
+		report.rcf.addcells(cols...)
+	}
+
+	if rcf.formatted() {
+		rcf.render(db, 0, 1)
+		return
+	}
+
+	w0 := rcf.maxwidth(rcf.column(0)) // Date
+	w1 := rcf.maxwidth(rcf.column(1)) // Payee
+	w2 := rcf.maxwidth(rcf.column(2)) // Account name
+	w3 := rcf.maxwidth(rcf.column(3)) // Value
+	w4 := rcf.maxwidth(rcf.column(4)) // Balance (amount)
+	w5 := rcf.maxwidth(rcf.column(5)) // Gain (amount)
+	if (w0 + w1 + w2 + w3 + w4 + w5) > 125 {
+		w1 = rcf.FitPayee(1, 125-w0-w2-w3-w4-w5)
+		if (w0 + w1 + w2 + w3 + w4 + w5) > 125 {
+			_ /*w2*/ = rcf.FitAccountname(1, 125-w0-w1-w3-w4-w5)
+		}
+	}
+
+	rcf.paddcells()
+	fmsg := rcf.Fmsg(" %%-%vs%%-%vs%%-%vs%%%vs%%%vs%%%vs\n")
+	comm1 := dblentry.NewCommodity("")
+	comm2 := dblentry.NewCommodity("")
+	comm3 := dblentry.NewCommodity("")
+
+	// start printing
+	outfd := api.Options.Outfd
+	fmt.Fprintln(outfd)
+	for i, cols := range report.rcf.rows {
+		items := []interface{}{cols[0], cols[1]}
+		if i < 2 {
+			items = append(items, cols[2], cols[3], cols[4], cols[5])
+		} else {
+			x := CommodityColor(db, comm1, cols[3])
+			y := CommodityColor(db, comm2, cols[4])
+			z := CommodityColor(db, comm3, cols[5])
+			items = append(items, api.YellowFn(cols[2]), x, y, z)
+		}
+		fmt.Fprint(outfd, report.stateprefix(i))
 		fmt.Fprintf(outfd, fmsg, items...)
 	}
 	fmt.Fprintln(outfd)
@@ -592,11 +729,16 @@ func (report *ReportRegister) render2(args []string, db api.Datastorer) {
 	rcf := report.rcf
 
 	cols := []string{"By-date", "Payee", "Account", "Debit", "Credit", "Balance"}
-	rcf.addrow(cols...)
+	rcf.addheader(cols...)
 	rcf.addrow([]string{"", "", "", "", "", ""}...)
 
 	for _, cols := range report.register {
-		report.rcf.addrow(cols...)
+		report.rcf.addcells(cols...)
+	}
+
+	if rcf.formatted() {
+		rcf.render(db, 0, 1)
+		return
 	}
 
 	w0 := rcf.maxwidth(rcf.column(0)) // Date
@@ -627,6 +769,7 @@ func (report *ReportRegister) render2(args []string, db api.Datastorer) {
 			x := CommodityColor(db, comm1, cols[5])
 			items = append(items, api.YellowFn(cols[2]), cols[3], cols[4], x)
 		}
+		fmt.Fprint(outfd, report.stateprefix(i))
 		fmt.Fprintf(outfd, fmsg, items...)
 	}
 	fmt.Fprintln(outfd)
@@ -637,11 +780,16 @@ func (report *ReportRegister) render3(args []string, db api.Datastorer) {
 	rcf := report.rcf
 
 	cols := []string{"By-date", "Account", "Amount", "Balance"}
-	rcf.addrow(cols...)
+	rcf.addheader(cols...)
 	rcf.addrow([]string{"", "", "", ""}...)
 
 	for _, cols := range report.register {
-		report.rcf.addrow(cols...)
+		report.rcf.addcells(cols...)
+	}
+
+	if rcf.formatted() {
+		rcf.render(db, 0)
+		return
 	}
 
 	w0 := rcf.maxwidth(rcf.column(0)) // Date
@@ -679,11 +827,16 @@ func (report *ReportRegister) render4(args []string, db api.Datastorer) {
 	rcf := report.rcf
 
 	cols := []string{"By-date", "Account", "Debit", "Credit", "Balance"}
-	rcf.addrow(cols...)
+	rcf.addheader(cols...)
 	rcf.addrow([]string{"", "", "", "", ""}...)
 
 	for _, cols := range report.register {
-		report.rcf.addrow(cols...)
+		report.rcf.addcells(cols...)
+	}
+
+	if rcf.formatted() {
+		rcf.render(db, 0)
+		return
 	}
 
 	w0 := rcf.maxwidth(rcf.column(0)) // Date
@@ -724,32 +877,33 @@ func (report *ReportRegister) prerender3(args []string, db api.Datastorer) {
 	sort.Strings(accnames)
 
 	report.de = dblentry.NewDoubleEntry("regbalance")
-	report.register = [][]string{}
+	report.register = [][]interface{}{}
 	for _, accname := range accnames {
-		de, rows, balnames := report.accounts[accname], [][]string{}, []string{}
+		de, balnames := report.accounts[accname], []string{}
+		rows := [][]interface{}{}
 		for _, abal := range de.Balances() {
-			cols := []string{"", ""} // date-range, accname
+			cols := []interface{}{"", ""} // date-range, accname
 			if api.Options.Dcformat == false {
-				cols = append(cols, abal.String(), "")
+				cols = append(cols, rcamount(abal), "")
 			} else if abal.IsDebit() {
-				cols = append(cols, abal.String(), "", "")
+				cols = append(cols, rcamount(abal), "", "")
 			} else if abal.IsCredit() {
-				abalstr := abal.MakeSimilar(-abal.Amount()).String()
-				cols = append(cols, "", abalstr, "")
+				credit := abal.MakeSimilar(abal.Amount().Neg())
+				cols = append(cols, "", credit, "")
 			}
 			report.de.AddBalance(abal)
-			cols[len(cols)-1] = report.de.Balance(abal.Name()).String()
+			cols[len(cols)-1] = rcamount(report.de.Balance(abal.Name()))
 			rows, balnames = append(rows, cols), append(balnames, abal.Name())
 		}
 		for _, bal := range report.de.Balances() {
 			if api.HasString(balnames, bal.Name()) {
 				continue
 			}
+			cols := []interface{}{"", "", "", rcamount(bal)}
 			if api.Options.Dcformat {
-				rows = append(rows, []string{"", "", "", "", bal.String()})
-			} else {
-				rows = append(rows, []string{"", "", "", bal.String()})
+				cols = append([]interface{}{""}, cols...)
 			}
+			rows = append(rows, cols)
 		}
 		rows[0][1] = accname
 		report.register = append(report.register, rows...)
@@ -779,25 +933,26 @@ func (report *ReportRegister) prerender4(args []string, db api.Datastorer) {
 	}
 
 	report.de = dblentry.NewDoubleEntry("regbalance")
-	report.register = [][]string{}
+	report.register = [][]interface{}{}
 	for _, payee := range payees {
-		payeerows, balrows, balnames := [][]string{}, [][]string{}, []string{}
+		payeerows, balrows := [][]interface{}{}, [][]interface{}{}
+		balnames := []string{}
 		accnames := sortaccount(report.payees[payee])
 		for _, accname := range accnames {
 			de := report.payees[payee][accname]
-			accrows := [][]string{}
+			accrows := [][]interface{}{}
 			for _, abal := range de.Balances() {
-				cols := []string{"", "", ""} // date-range, payee, accname
+				cols := []interface{}{"", "", ""} // date-range, payee, accname
 				if api.Options.Dcformat == false {
-					cols = append(cols, abal.String(), "")
+					cols = append(cols, rcamount(abal), "")
 				} else if abal.IsDebit() {
-					cols = append(cols, abal.String(), "", "")
+					cols = append(cols, rcamount(abal), "", "")
 				} else if abal.IsCredit() {
-					abalstr := abal.MakeSimilar(-abal.Amount()).String()
-					cols = append(cols, "", abalstr, "")
+					credit := abal.MakeSimilar(abal.Amount().Neg())
+					cols = append(cols, "", credit, "")
 				}
 				report.de.AddBalance(abal)
-				cols[len(cols)-1] = report.de.Balance(abal.Name()).String()
+				cols[len(cols)-1] = rcamount(report.de.Balance(abal.Name()))
 				accrows = append(accrows, cols)
 				balnames = append(balnames, abal.Name())
 			}
@@ -810,16 +965,16 @@ func (report *ReportRegister) prerender4(args []string, db api.Datastorer) {
 			if api.HasString(balnames, bal.Name()) {
 				continue
 			}
-			var cols []string
+			var cols []interface{}
 			if api.Options.Dcformat {
-				cols = []string{"", "", "", "", "", bal.String()}
+				cols = []interface{}{"", "", "", "", "", rcamount(bal)}
 			} else {
-				cols = []string{"", "", "", "", bal.String()}
+				cols = []interface{}{"", "", "", "", rcamount(bal)}
 			}
 			payeerows = append(payeerows, cols)
 		}
 
-		payeerows[0][0] = report.findates[payee].Format("2006-Jan-02")
+		payeerows[0][0] = rcdate{*report.findates[payee], "2006-Jan-02"}
 		payeerows[0][1] = payee
 		report.register = append(report.register, payeerows...)
 	}
@@ -837,25 +992,27 @@ func (report *ReportRegister) prerender5(args []string, db api.Datastorer) {
 	}
 
 	report.de = dblentry.NewDoubleEntry("regbalance")
-	report.register = [][]string{}
-	for _, datestr := range report.dailytm {
-		daterows, balrows, balnames := [][]string{}, [][]string{}, []string{}
+	report.register = [][]interface{}{}
+	for _, tm := range report.dailytm {
+		datestr := tm.Format("2006-Jan-02")
+		daterows, balrows := [][]interface{}{}, [][]interface{}{}
+		balnames := []string{}
 		accnames := sortaccount(report.daily[datestr])
 		for _, accname := range accnames {
 			de := report.daily[datestr][accname]
-			accrows := [][]string{}
+			accrows := [][]interface{}{}
 			for _, abal := range de.Balances() {
-				cols := []string{"", ""} // date-range, accname
+				cols := []interface{}{"", ""} // date-range, accname
 				if api.Options.Dcformat == false {
-					cols = append(cols, abal.String(), "")
+					cols = append(cols, rcamount(abal), "")
 				} else if abal.IsDebit() {
-					cols = append(cols, abal.String(), "", "")
+					cols = append(cols, rcamount(abal), "", "")
 				} else if abal.IsCredit() {
-					abalstr := abal.MakeSimilar(-abal.Amount()).String()
-					cols = append(cols, "", abalstr, "")
+					credit := abal.MakeSimilar(abal.Amount().Neg())
+					cols = append(cols, "", credit, "")
 				}
 				report.de.AddBalance(abal)
-				cols[len(cols)-1] = report.de.Balance(abal.Name()).String()
+				cols[len(cols)-1] = rcamount(report.de.Balance(abal.Name()))
 				accrows = append(accrows, cols)
 				balnames = append(balnames, abal.Name())
 			}
@@ -868,16 +1025,16 @@ func (report *ReportRegister) prerender5(args []string, db api.Datastorer) {
 			if api.HasString(balnames, bal.Name()) {
 				continue
 			}
-			var cols []string
+			var cols []interface{}
 			if api.Options.Dcformat {
-				cols = []string{"", "", "", "", bal.String()}
+				cols = []interface{}{"", "", "", "", rcamount(bal)}
 			} else {
-				cols = []string{"", "", "", bal.String()}
+				cols = []interface{}{"", "", "", rcamount(bal)}
 			}
 			daterows = append(daterows, cols)
 		}
 
-		daterows[0][0] = datestr
+		daterows[0][0] = rcdate{tm, "2006-Jan-02"}
 		report.register = append(report.register, daterows...)
 	}
 }
@@ -908,28 +1065,29 @@ func (report *ReportRegister) prerender6(args []string, db api.Datastorer) {
 	}
 
 	report.de = dblentry.NewDoubleEntry("regbalance")
-	report.register = [][]string{}
+	report.register = [][]interface{}{}
 	for _, year := range years {
 		weeks := report.weekly[year]
 		weekns := sortweeks(weeks)
 		for _, week := range weekns {
 			accounts := weeks[week]
-			daterows, balrows, balnames := [][]string{}, [][]string{}, []string{}
+			daterows, balrows := [][]interface{}{}, [][]interface{}{}
+			balnames := []string{}
 			accnames := sortaccount(accounts)
 			for _, accname := range accnames {
-				de, accrows := accounts[accname], [][]string{}
+				de, accrows := accounts[accname], [][]interface{}{}
 				for _, abal := range de.Balances() {
-					cols := []string{"", ""} // date-range, accname
+					cols := []interface{}{"", ""} // date-range, accname
 					if api.Options.Dcformat == false {
-						cols = append(cols, abal.String(), "")
+						cols = append(cols, rcamount(abal), "")
 					} else if abal.IsDebit() {
-						cols = append(cols, abal.String(), "", "")
+						cols = append(cols, rcamount(abal), "", "")
 					} else if abal.IsCredit() {
-						abalstr := abal.MakeSimilar(-abal.Amount()).String()
-						cols = append(cols, "", abalstr, "")
+						credit := abal.MakeSimilar(abal.Amount().Neg())
+						cols = append(cols, "", credit, "")
 					}
 					report.de.AddBalance(abal)
-					cols[len(cols)-1] = report.de.Balance(abal.Name()).String()
+					cols[len(cols)-1] = rcamount(report.de.Balance(abal.Name()))
 					accrows = append(accrows, cols)
 					balnames = append(balnames, abal.Name())
 				}
@@ -942,11 +1100,11 @@ func (report *ReportRegister) prerender6(args []string, db api.Datastorer) {
 				if api.HasString(balnames, bal.Name()) {
 					continue
 				}
-				var cols []string
+				var cols []interface{}
 				if api.Options.Dcformat {
-					cols = []string{"", "", "", "", bal.String()}
+					cols = []interface{}{"", "", "", "", rcamount(bal)}
 				} else {
-					cols = []string{"", "", "", bal.String()}
+					cols = []interface{}{"", "", "", rcamount(bal)}
 				}
 				daterows = append(daterows, cols)
 			}
@@ -985,28 +1143,29 @@ func (report *ReportRegister) prerender7(args []string, db api.Datastorer) {
 	}
 
 	report.de = dblentry.NewDoubleEntry("regbalance")
-	report.register = [][]string{}
+	report.register = [][]interface{}{}
 	for _, year := range years {
 		months := report.monthly[year]
 		monthns := sortmonths(months)
 		for _, month := range monthns {
 			accounts := months[month]
-			daterows, balrows, balnames := [][]string{}, [][]string{}, []string{}
+			daterows, balrows := [][]interface{}{}, [][]interface{}{}
+			balnames := []string{}
 			accnames := sortaccount(accounts)
 			for _, accname := range accnames {
-				de, accrows := accounts[accname], [][]string{}
+				de, accrows := accounts[accname], [][]interface{}{}
 				for _, abal := range de.Balances() {
-					cols := []string{"", ""} // date-range, accname
+					cols := []interface{}{"", ""} // date-range, accname
 					if api.Options.Dcformat == false {
-						cols = append(cols, abal.String(), "")
+						cols = append(cols, rcamount(abal), "")
 					} else if abal.IsDebit() {
-						cols = append(cols, abal.String(), "", "")
+						cols = append(cols, rcamount(abal), "", "")
 					} else if abal.IsCredit() {
-						abalstr := abal.MakeSimilar(-abal.Amount()).String()
-						cols = append(cols, "", abalstr, "")
+						credit := abal.MakeSimilar(abal.Amount().Neg())
+						cols = append(cols, "", credit, "")
 					}
 					report.de.AddBalance(abal)
-					cols[len(cols)-1] = report.de.Balance(abal.Name()).String()
+					cols[len(cols)-1] = rcamount(report.de.Balance(abal.Name()))
 					accrows = append(accrows, cols)
 					balnames = append(balnames, abal.Name())
 				}
@@ -1019,17 +1178,17 @@ func (report *ReportRegister) prerender7(args []string, db api.Datastorer) {
 				if api.HasString(balnames, bal.Name()) {
 					continue
 				}
-				var cols []string
+				var cols []interface{}
 				if api.Options.Dcformat {
-					cols = []string{"", "", "", "", bal.String()}
+					cols = []interface{}{"", "", "", "", rcamount(bal)}
 				} else {
-					cols = []string{"", "", "", bal.String()}
+					cols = []interface{}{"", "", "", rcamount(bal)}
 				}
 				daterows = append(daterows, cols)
 			}
 
 			tm := time.Date(year, time.Month(month), 0, 0, 0, 0, 0, time.Local)
-			daterows[0][0] = tm.Format("2006-Jan")
+			daterows[0][0] = rcdate{tm, "2006-Jan"}
 			report.register = append(report.register, daterows...)
 		}
 	}
@@ -1061,28 +1220,29 @@ func (report *ReportRegister) prerender8(args []string, db api.Datastorer) {
 	}
 
 	report.de = dblentry.NewDoubleEntry("regbalance")
-	report.register = [][]string{}
+	report.register = [][]interface{}{}
 	for _, year := range years {
 		quarters := report.quarterly[year]
 		qns := sortquarters(quarters)
 		for _, quarter := range qns {
 			accounts := quarters[quarter]
-			daterows, balrows, balnames := [][]string{}, [][]string{}, []string{}
+			daterows, balrows := [][]interface{}{}, [][]interface{}{}
+			balnames := []string{}
 			accnames := sortaccount(accounts)
 			for _, accname := range accnames {
-				de, accrows := accounts[accname], [][]string{}
+				de, accrows := accounts[accname], [][]interface{}{}
 				for _, abal := range de.Balances() {
-					cols := []string{"", ""} // date-range, accname
+					cols := []interface{}{"", ""} // date-range, accname
 					if api.Options.Dcformat == false {
-						cols = append(cols, abal.String(), "")
+						cols = append(cols, rcamount(abal), "")
 					} else if abal.IsDebit() {
-						cols = append(cols, abal.String(), "", "")
+						cols = append(cols, rcamount(abal), "", "")
 					} else if abal.IsCredit() {
-						abalstr := abal.MakeSimilar(-abal.Amount()).String()
-						cols = append(cols, "", abalstr, "")
+						credit := abal.MakeSimilar(abal.Amount().Neg())
+						cols = append(cols, "", credit, "")
 					}
 					report.de.AddBalance(abal)
-					cols[len(cols)-1] = report.de.Balance(abal.Name()).String()
+					cols[len(cols)-1] = rcamount(report.de.Balance(abal.Name()))
 					accrows = append(accrows, cols)
 					balnames = append(balnames, abal.Name())
 				}
@@ -1095,11 +1255,11 @@ func (report *ReportRegister) prerender8(args []string, db api.Datastorer) {
 				if api.HasString(balnames, bal.Name()) {
 					continue
 				}
-				var cols []string
+				var cols []interface{}
 				if api.Options.Dcformat {
-					cols = []string{"", "", "", "", bal.String()}
+					cols = []interface{}{"", "", "", "", rcamount(bal)}
 				} else {
-					cols = []string{"", "", "", bal.String()}
+					cols = []interface{}{"", "", "", rcamount(bal)}
 				}
 				daterows = append(daterows, cols)
 			}
@@ -1128,25 +1288,26 @@ func (report *ReportRegister) prerender9(args []string, db api.Datastorer) {
 	}
 
 	report.de = dblentry.NewDoubleEntry("regbalance")
-	report.register = [][]string{}
+	report.register = [][]interface{}{}
 	for _, year := range years {
 		accounts := report.yearly[year]
-		daterows, balrows, balnames := [][]string{}, [][]string{}, []string{}
+		daterows, balrows := [][]interface{}{}, [][]interface{}{}
+		balnames := []string{}
 		accnames := sortaccount(accounts)
 		for _, accname := range accnames {
-			de, accrows := accounts[accname], [][]string{}
+			de, accrows := accounts[accname], [][]interface{}{}
 			for _, abal := range de.Balances() {
-				cols := []string{"", ""} // date-range, accname
+				cols := []interface{}{"", ""} // date-range, accname
 				if api.Options.Dcformat == false {
-					cols = append(cols, abal.String(), "")
+					cols = append(cols, rcamount(abal), "")
 				} else if abal.IsDebit() {
-					cols = append(cols, abal.String(), "", "")
+					cols = append(cols, rcamount(abal), "", "")
 				} else if abal.IsCredit() {
-					abalstr := abal.MakeSimilar(-abal.Amount()).String()
-					cols = append(cols, "", abalstr, "")
+					credit := abal.MakeSimilar(abal.Amount().Neg())
+					cols = append(cols, "", credit, "")
 				}
 				report.de.AddBalance(abal)
-				cols[len(cols)-1] = report.de.Balance(abal.Name()).String()
+				cols[len(cols)-1] = rcamount(report.de.Balance(abal.Name()))
 				accrows = append(accrows, cols)
 				balnames = append(balnames, abal.Name())
 			}
@@ -1159,11 +1320,11 @@ func (report *ReportRegister) prerender9(args []string, db api.Datastorer) {
 			if api.HasString(balnames, bal.Name()) {
 				continue
 			}
-			var cols []string
+			var cols []interface{}
 			if api.Options.Dcformat {
-				cols = []string{"", "", "", "", bal.String()}
+				cols = []interface{}{"", "", "", "", rcamount(bal)}
 			} else {
-				cols = []string{"", "", "", bal.String()}
+				cols = []interface{}{"", "", "", rcamount(bal)}
 			}
 			daterows = append(daterows, cols)
 		}
@@ -1191,25 +1352,26 @@ func (report *ReportRegister) prerender10(args []string, db api.Datastorer) {
 	}
 
 	report.de = dblentry.NewDoubleEntry("regbalance")
-	report.register = [][]string{}
+	report.register = [][]interface{}{}
 	for _, dow := range dows {
 		accounts := report.dow[time.Weekday(dow)]
-		daterows, balrows, balnames := [][]string{}, [][]string{}, []string{}
+		daterows, balrows := [][]interface{}{}, [][]interface{}{}
+		balnames := []string{}
 		accnames := sortaccount(accounts)
 		for _, accname := range accnames {
-			de, accrows := accounts[accname], [][]string{}
+			de, accrows := accounts[accname], [][]interface{}{}
 			for _, abal := range de.Balances() {
-				cols := []string{"", ""} // date-range, accname
+				cols := []interface{}{"", ""} // date-range, accname
 				if api.Options.Dcformat == false {
-					cols = append(cols, abal.String(), "")
+					cols = append(cols, rcamount(abal), "")
 				} else if abal.IsDebit() {
-					cols = append(cols, abal.String(), "", "")
+					cols = append(cols, rcamount(abal), "", "")
 				} else if abal.IsCredit() {
-					abalstr := abal.MakeSimilar(-abal.Amount()).String()
-					cols = append(cols, "", abalstr, "")
+					credit := abal.MakeSimilar(abal.Amount().Neg())
+					cols = append(cols, "", credit, "")
 				}
 				report.de.AddBalance(abal)
-				cols[len(cols)-1] = report.de.Balance(abal.Name()).String()
+				cols[len(cols)-1] = rcamount(report.de.Balance(abal.Name()))
 				accrows = append(accrows, cols)
 				balnames = append(balnames, abal.Name())
 			}
@@ -1222,11 +1384,11 @@ func (report *ReportRegister) prerender10(args []string, db api.Datastorer) {
 			if api.HasString(balnames, bal.Name()) {
 				continue
 			}
-			var cols []string
+			var cols []interface{}
 			if api.Options.Dcformat {
-				cols = []string{"", "", "", "", bal.String()}
+				cols = []interface{}{"", "", "", "", rcamount(bal)}
 			} else {
-				cols = []string{"", "", "", bal.String()}
+				cols = []interface{}{"", "", "", rcamount(bal)}
 			}
 			daterows = append(daterows, cols)
 		}
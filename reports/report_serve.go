@@ -0,0 +1,301 @@
+package reports
+
+import "fmt"
+import "sort"
+import "time"
+import "strings"
+
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+
+// serveentry is a posting, as of secondpass, for querying.
+type serveentry struct {
+	date    time.Time
+	payee   string
+	account string
+	state   string
+	amount  api.Commoditiser
+	trans   api.Transactor
+	p       api.Poster
+}
+
+type serveamount struct {
+	Commodity string      `json:"commodity"`
+	Quantity  api.Decimal `json:"quantity"`
+	Text      string      `json:"text"` // formatted as per commodity.
+}
+
+type serveaccount struct {
+	Name     string         `json:"name"`
+	Declared bool           `json:"declared"`
+	Notes    []string       `json:"notes"`
+	Balances []*serveamount `json:"balances"`
+}
+
+type servebalance struct {
+	Account  string         `json:"account"`
+	Balances []*serveamount `json:"balances"`
+}
+
+type servebalances struct {
+	Accounts []*servebalance `json:"accounts"`
+	Total    []*serveamount  `json:"total"`
+}
+
+type serveposting struct {
+	Date    string         `json:"date"`
+	Payee   string         `json:"payee"`
+	Account string         `json:"account"`
+	State   string         `json:"state"`
+	Amount  *serveamount   `json:"amount"`
+	Balance []*serveamount `json:"balance"`
+}
+
+type servepayee struct {
+	Name     string `json:"name"`
+	Declared bool   `json:"declared"`
+	Postings int    `json:"postings"`
+}
+
+type servecommodity struct {
+	Name     string   `json:"name"`
+	Declared bool     `json:"declared"`
+	Currency bool     `json:"currency"`
+	Notes    []string `json:"notes"`
+}
+
+// ReportServe collect postings in secondpass, to be queried by `serve`
+// command. Dates are formatted as `YYYY-MM-DD`.
+type ReportServe struct {
+	entries []*serveentry
+}
+
+// NewReportServe create an instance for serving journals.
+func NewReportServe(args []string) *ReportServe {
+	return &ReportServe{entries: []*serveentry{}}
+}
+
+//---- api.Reporter methods
+
+func (report *ReportServe) Firstpass(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	return nil
+}
+
+func (report *ReportServe) Transaction(
+	db api.Datastorer, trans api.Transactor) error {
+
+	return nil
+}
+
+func (report *ReportServe) Posting(
+	db api.Datastorer, trans api.Transactor, p api.Poster) error {
+
+	entry := &serveentry{
+		date:    trans.Date(),
+		payee:   strings.TrimSpace(p.Payee()),
+		account: p.Account().Name(),
+		state:   p.State(),
+		amount:  p.Commodity(),
+		trans:   trans,
+		p:       p,
+	}
+	report.entries = append(report.entries, entry)
+	return nil
+}
+
+func (report *ReportServe) BubblePosting(
+	db api.Datastorer, trans api.Transactor,
+	p api.Poster, account api.Accounter) error {
+
+	return nil
+}
+
+// Render is a no-op, collected postings are queried by `serve` command.
+func (report *ReportServe) Render(args []string, db api.Datastorer) {
+}
+
+func (report *ReportServe) Clone() api.Reporter {
+	nreport := *report
+	nreport.entries = []*serveentry{}
+	return &nreport
+}
+
+func (report *ReportServe) Startjournal(fname string, included bool) {
+	panic("not implemented")
+}
+
+//---- queries
+
+// Accounts return accounts matching the filter, with their final
+// balance. Pass fe as nil to match all accounts. Filter predicates can
+// only be matched with postings and are not allowed.
+func (report *ReportServe) Accounts(
+	db api.Datastorer, fe *api.Filterexpr) ([]*serveaccount, error) {
+
+	if fe != nil && fe.Predicates() {
+		return nil, fmt.Errorf("predicates not allowed in accounts filter")
+	}
+	accounts := []*serveaccount{}
+	for _, name := range db.Accountnames() {
+		if fe != nil && fe.Match(name) == false {
+			continue
+		}
+		acc := db.GetAccount(name)
+		accounts = append(accounts, &serveaccount{
+			Name:     name,
+			Declared: db.IsAccountDeclared(name),
+			Notes:    servestrings(acc.Notes()),
+			Balances: serveamounts(acc.Balances()),
+		})
+	}
+	return accounts, nil
+}
+
+// Balances return balance of accounts from postings dated between begin,
+// inclusive, and end. Without filter, balances are aggregated into their
+// parent accounts, like the balance report.
+func (report *ReportServe) Balances(
+	begin, end *time.Time, fe *api.Filterexpr) *servebalances {
+
+	des := map[string]*dblentry.DoubleEntry{}
+	total := dblentry.NewDoubleEntry("")
+	addbalance := func(name string, amount api.Commoditiser) {
+		if _, ok := des[name]; ok == false {
+			des[name] = dblentry.NewDoubleEntry(name)
+		}
+		des[name].AddBalance(amount)
+	}
+	for _, entry := range report.entries {
+		if servedate(entry.date, begin, end) == false {
+			continue
+		} else if fe != nil && fe.MatchPosting(entry.trans, entry.p) == false {
+			continue
+		}
+		total.AddBalance(entry.amount)
+		addbalance(entry.account, entry.amount)
+		if fe != nil {
+			continue
+		}
+		parts := dblentry.SplitAccount(entry.account)
+		for i := 1; i < len(parts); i++ {
+			addbalance(strings.Join(parts[:i], ":"), entry.amount)
+		}
+	}
+
+	names := []string{}
+	for name := range des {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	balances := &servebalances{
+		Accounts: []*servebalance{}, Total: serveamounts(total.Balances()),
+	}
+	for _, name := range names {
+		balances.Accounts = append(balances.Accounts, &servebalance{
+			Account: name, Balances: serveamounts(des[name].Balances()),
+		})
+	}
+	return balances
+}
+
+// Register return postings dated between begin, inclusive, and end, that
+// match the filter, with running balance.
+func (report *ReportServe) Register(
+	begin, end *time.Time, fe *api.Filterexpr) []*serveposting {
+
+	postings, de := []*serveposting{}, dblentry.NewDoubleEntry("")
+	for _, entry := range report.entries {
+		if servedate(entry.date, begin, end) == false {
+			continue
+		} else if fe != nil && fe.MatchPosting(entry.trans, entry.p) == false {
+			continue
+		}
+		de.AddBalance(entry.amount)
+		postings = append(postings, &serveposting{
+			Date:    entry.date.Format("2006-01-02"),
+			Payee:   entry.payee,
+			Account: entry.account,
+			State:   entry.state,
+			Amount:  serveamountof(entry.amount),
+			Balance: serveamounts(de.Balances()),
+		})
+	}
+	return postings
+}
+
+// Payees return payees of postings, sorted by name.
+func (report *ReportServe) Payees(db api.Datastorer) []*servepayee {
+	counts := map[string]int{}
+	for _, entry := range report.entries {
+		counts[entry.payee]++
+	}
+	names := []string{}
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	payees := []*servepayee{}
+	for _, name := range names {
+		payees = append(payees, &servepayee{
+			Name:     name,
+			Declared: db.IsPayeeDeclared(name),
+			Postings: counts[name],
+		})
+	}
+	return payees
+}
+
+// Commodities return commodities used or declared in journals.
+func (report *ReportServe) Commodities(db api.Datastorer) []*servecommodity {
+	commodities := []*servecommodity{}
+	for _, name := range db.Commoditynames() {
+		if name == "" {
+			continue
+		}
+		comm := db.GetCommodity(name)
+		commodities = append(commodities, &servecommodity{
+			Name:     name,
+			Declared: db.IsCommodityDeclared(name),
+			Currency: comm.Currency(),
+			Notes:    servestrings(comm.Notes()),
+		})
+	}
+	return commodities
+}
+
+func servedate(date time.Time, begin, end *time.Time) bool {
+	if begin != nil && date.Before(*begin) {
+		return false
+	} else if end != nil && date.Before(*end) == false {
+		return false
+	}
+	return true
+}
+
+func serveamountof(amount api.Commoditiser) *serveamount {
+	return &serveamount{
+		Commodity: amount.Name(),
+		Quantity:  amount.Amount(),
+		Text:      amount.String(),
+	}
+}
+
+func serveamounts(amounts []api.Commoditiser) []*serveamount {
+	samounts := []*serveamount{}
+	for _, amount := range amounts {
+		samounts = append(samounts, serveamountof(amount))
+	}
+	return samounts
+}
+
+// servestrings return an empty list for nil, so that lists are never
+// served as null.
+func servestrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -37,6 +37,18 @@ func NewReporter(args []string) (reporter api.Reporter, err error) {
 		return reports, nil
 	}
 
+	if api.Options.Outformat != "text" {
+		switch args[0] {
+		case "balance", "bal", "b", "register", "reg", "r", "equity", "eq",
+			"list", "ls", "passbook", "pb", "pbook":
+		default:
+			fmsg := "-format %q not supported by %q"
+			err = fmt.Errorf(fmsg, api.Options.Outformat, args[0])
+			log.Errorf("%v\n", err)
+			return reports, err
+		}
+	}
+
 	switch args[0] {
 	case "balance", "bal", "b":
 		reporter, err = NewReportBalance(args)
@@ -54,12 +66,36 @@ func NewReporter(args []string) (reporter api.Reporter, err error) {
 	case "passbook", "pb", "pbook":
 		reporter, err = NewReportPassbook(args)
 		reports.reporters = append(reports.reporters, reporter)
+	case "gains":
+		reporter, err = NewReportGains(args)
+		reports.reporters = append(reports.reporters, reporter)
+	case "budget":
+		reporter, err = NewReportBudget(args)
+		reports.reporters = append(reports.reporters, reporter)
+	case "reconcile":
+		reporter, err = NewReportReconcile(args)
+		reports.reporters = append(reports.reporters, reporter)
+	case "dedup":
+		reports.reporters = append(reports.reporters, NewReportDedup(args))
+	case "export":
+		reporter, err = NewReportExport(args)
+		reports.reporters = append(reports.reporters, reporter)
+	case "import":
+		reporter, err = NewReportImport(args)
+		reports.reporters = append(reports.reporters, reporter)
+	case "serve":
+		reports.reporters = append(reports.reporters, NewReportServe(args))
 	default:
 		log.Errorf("invalid command %q\n", args[0])
 	}
 	return reports, err
 }
 
+// Reporters return the reporters created for the command.
+func (reports *Reports) Reporters() []api.Reporter {
+	return reports.reporters
+}
+
 //---- api.Reporter methods
 
 func (reports *Reports) Firstpass(
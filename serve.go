@@ -0,0 +1,233 @@
+package main
+
+import "fmt"
+import "sync"
+import "time"
+import "strings"
+import "net/http"
+import "encoding/json"
+
+import "github.com/bnclabs/golog"
+import "github.com/prataprc/goparsec"
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/dblentry"
+import "github.com/tn47/goledger/reports"
+
+// apiserver serve journals, loaded in memory, as read-only JSON API.
+// Journals are reloaded when any of the journal files change, parsing
+// only the modified journals.
+type apiserver struct {
+	mu     sync.RWMutex
+	args   []string
+	report *reports.ReportServe
+	db     api.Datastorer
+	mtimes map[string]time.Time
+}
+
+func serve(args []string) error {
+	jcache = newjournalcache()
+	server := &apiserver{args: args[:1]}
+	if err := server.load(); err != nil {
+		return err
+	}
+	go server.watch(time.Second)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/accounts", server.accounts)
+	mux.HandleFunc("/balances", server.balances)
+	mux.HandleFunc("/register", server.register)
+	mux.HandleFunc("/payees", server.payees)
+	mux.HandleFunc("/commodities", server.commodities)
+
+	log.Infof("serving journals on %q\n", api.Options.Addr)
+	if err := http.ListenAndServe(api.Options.Addr, mux); err != nil {
+		log.Errorf("%v\n", err)
+		return err
+	}
+	return nil
+}
+
+// load journals, in firstpass and secondpass, for serving. On error,
+// previously loaded journals shall continue to be served.
+func (server *apiserver) load() error {
+	reporter, err := reports.NewReporter(server.args)
+	if err != nil {
+		return err
+	}
+	db, err := loadjournals(reporter)
+	if err != nil {
+		return err
+	}
+	mtimes := modtimes(db.Journals())
+	nreporter, ndb, err := dosecondpass(server.args, reporter, db)
+	if err != nil {
+		return err
+	}
+	reporters := nreporter.(*reports.Reports).Reporters()
+	report := reporters[0].(*reports.ReportServe)
+
+	server.mu.Lock()
+	server.report, server.db, server.mtimes = report, ndb, mtimes
+	server.mu.Unlock()
+	return nil
+}
+
+// watch journal files for changes, every interval, and reload them.
+func (server *apiserver) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		server.mu.RLock()
+		mtimes := server.mtimes
+		server.mu.RUnlock()
+		if modified(mtimes) == false {
+			continue
+		}
+
+		log.Infof("journals modified, reloading ...\n")
+		if err := server.load(); err != nil {
+			// wait for the journals to be fixed.
+			files := []string{}
+			for file := range mtimes {
+				files = append(files, file)
+			}
+			server.mu.Lock()
+			server.mtimes = modtimes(files)
+			server.mu.Unlock()
+		}
+	}
+}
+
+//---- handlers
+
+// accounts handle `/accounts?filter=expr`.
+func (server *apiserver) accounts(w http.ResponseWriter, r *http.Request) {
+	fe, err := servefilter(r)
+	if err != nil {
+		serveerror(w, http.StatusBadRequest, err)
+		return
+	}
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	accounts, err := server.report.Accounts(server.db, fe)
+	if err != nil {
+		serveerror(w, http.StatusBadRequest, err)
+		return
+	}
+	servejson(w, accounts)
+}
+
+// balances handle `/balances?begin=date&end=date&date=date&filter=expr`.
+func (server *apiserver) balances(w http.ResponseWriter, r *http.Request) {
+	begin, end, err := serveperiod(r)
+	if err != nil {
+		serveerror(w, http.StatusBadRequest, err)
+		return
+	}
+	fe, err := servefilter(r)
+	if err != nil {
+		serveerror(w, http.StatusBadRequest, err)
+		return
+	}
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	servejson(w, server.report.Balances(begin, end, fe))
+}
+
+// register handle `/register?begin=date&end=date&date=date&filter=expr`.
+func (server *apiserver) register(w http.ResponseWriter, r *http.Request) {
+	begin, end, err := serveperiod(r)
+	if err != nil {
+		serveerror(w, http.StatusBadRequest, err)
+		return
+	}
+	fe, err := servefilter(r)
+	if err != nil {
+		serveerror(w, http.StatusBadRequest, err)
+		return
+	}
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	servejson(w, server.report.Register(begin, end, fe))
+}
+
+// payees handle `/payees`.
+func (server *apiserver) payees(w http.ResponseWriter, r *http.Request) {
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	servejson(w, server.report.Payees(server.db))
+}
+
+// commodities handle `/commodities`.
+func (server *apiserver) commodities(
+	w http.ResponseWriter, r *http.Request) {
+
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	servejson(w, server.report.Commodities(server.db))
+}
+
+//---- local functions
+
+// servefilter parse `filter` parameter as filter expression, returns nil
+// if parameter is not supplied.
+func servefilter(r *http.Request) (*api.Filterexpr, error) {
+	filter := strings.TrimSpace(r.URL.Query().Get("filter"))
+	if filter == "" {
+		return nil, nil
+	}
+	filterarg := api.MakeFilterexpr(strings.Fields(filter))
+	node, _ := api.YFilterExpr(parsec.NewScanner([]byte(filterarg)))
+	if err, ok := node.(error); ok {
+		return nil, err
+	}
+	fe, ok := node.(*api.Filterexpr)
+	if ok == false {
+		return nil, fmt.Errorf("invalid filter %q", filter)
+	}
+	return fe, nil
+}
+
+// serveperiod parse `begin`, `end` and `date` parameters. Like -begin and
+// -end, begin is inclusive and end is not, date is same as end of the
+// next day.
+func serveperiod(r *http.Request) (begin, end *time.Time, err error) {
+	query := r.URL.Query()
+	for _, param := range []string{"begin", "end", "date"} {
+		value := strings.TrimSpace(query.Get(param))
+		if value == "" {
+			continue
+		}
+		scanner := parsec.NewScanner([]byte(value))
+		node, scanner := dblentry.Ydate(time.Now().Year())(scanner)
+		tm, ok := node.(time.Time)
+		if ok == false || scanner.Endof() == false {
+			return nil, nil, fmt.Errorf("invalid %v %q", param, value)
+		}
+		switch param {
+		case "begin":
+			begin = &tm
+		case "end":
+			end = &tm
+		case "date":
+			tm = tm.AddDate(0, 0, 1)
+			end = &tm
+		}
+	}
+	return begin, end, nil
+}
+
+func servejson(w http.ResponseWriter, value interface{}) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		serveerror(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+func serveerror(w http.ResponseWriter, code int, err error) {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(append(data, '\n'))
+}
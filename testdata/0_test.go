@@ -2,11 +2,14 @@ package tests
 
 import "os"
 import "fmt"
+import "net"
+import "time"
 import "strings"
 import "testing"
 import "bytes"
 import "io/ioutil"
 import "os/exec"
+import "net/http"
 import "compress/gzip"
 import "path/filepath"
 
@@ -1517,6 +1520,1211 @@ func TestShare(t *testing.T) {
 	}
 }
 
+func TestMarket(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "market.ldg", "-market", "balance"},
+			"refdata/market.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "market.ldg", "-exchange", "EUR", "balance"},
+			"refdata/market.exchange.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "market.ldg", "-end", "2012/04/01", "-market", "balance"},
+			"refdata/market.end.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "market.ldg", "-market", "register"},
+			"refdata/market.register.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "market.ldg", "-market", "-dc", "register"},
+			"refdata/market.register.dc.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestGains(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "gains"},
+			"refdata/gains.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "-lots", "lifo", "gains"},
+			"refdata/gains.lifo.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "-begin", "2011/10/01", "gains"},
+			"refdata/gains.begin.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "gains", "Assets:Checking"},
+			"refdata/gains.filter.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "-lots", "xyz", "gains"},
+			"refdata/gains.lotserr.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gainswarn.ldg", "gains"},
+			"refdata/gainswarn.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gainstransfer.ldg", "gains"},
+			"refdata/gainstransfer.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gainscurrency.ldg", "gains"},
+			"refdata/gainscurrency.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestAssert(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "assert.ldg", "balance"},
+			"refdata/assert.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "assert.ldg", "-end", "2011/01/08", "balance"},
+			"refdata/assert.end.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "assertfail.ldg", "balance"},
+			"refdata/assertfail.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "assertacc.ldg", "balance"},
+			"refdata/assertacc.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestAutomated(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "automated.ldg", "balance"},
+			"refdata/automated.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "automated.ldg", "register"},
+			"refdata/automated.register.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "automatederr.ldg", "balance"},
+			"refdata/automatederr.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "automatederr2.ldg", "balance"},
+			"refdata/automatederr2.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "automated2.ldg", "balance"},
+			"refdata/automated2.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "automatederr3.ldg", "balance"},
+			"refdata/automatederr3.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "budget.ldg", "budget"},
+			"refdata/budget.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "budget.ldg", "budget", "Expenses:Food"},
+			"refdata/budget.filter.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "budget.ldg", "-quarterly", "budget"},
+			"refdata/budget.quarterly.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "budget.ldg", "-begin", "2011/02/01", "-end", "2011/05/01",
+				"budget",
+			},
+			"refdata/budget.period.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "budgetyear.ldg", "budget"},
+			"refdata/budgetyear.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "budgeterr.ldg", "budget"},
+			"refdata/budgeterr.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "state.ldg", "-cleared", "balance"},
+			"refdata/state.cleared.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "state.ldg", "-cleared", "-pending", "balance"},
+			"refdata/state.clearedpending.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "state.ldg", "-uncleared", "register"},
+			"refdata/state.uncleared.register.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "state.ldg", "-detailed", "register", "Assets:Checking"},
+			"refdata/state.detailed.register.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "state.ldg", "-cleared", "passbook", "Assets:Checking"},
+			"refdata/state.cleared.passbook.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "state.ldg", "-uncleared", "equity"},
+			"refdata/state.uncleared.equity.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "statebal.ldg", "-cleared", "balance"},
+			"refdata/statebal.cleared.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "statebal.ldg", "-uncleared", "gains"},
+			"refdata/statebal.gains.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "statebal.ldg", "-uncleared", "register"},
+			"refdata/statebal.uncleared.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "reconcile.ldg", "-statement-balance", "$1,230.00",
+				"-date", "2011/01/31", "reconcile", "Assets:Checking"},
+			"refdata/reconcile.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "reconcile.ldg", "-statement-balance", "$1,231.00",
+				"-date", "2011/01/31", "reconcile", "Assets:Checking"},
+			"refdata/reconcile.nomatch.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "reconcile.ldg", "-statement-balance", "$1,230.00",
+				"reconcile"},
+			"refdata/reconcile.noaccount.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "reconcilebig.ldg", "-statement-balance",
+				"$29999999999999.98", "reconcile", "Assets:Reserve"},
+			"refdata/reconcilebig.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "reconcile2.ldg", "-statement-balance", "$995.00",
+				"reconcile", "Assets:Checking"},
+			"refdata/reconcile2.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		cmd.Stdin = strings.NewReader("n\n")
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+
+	// mark matching postings as cleared in a copy of the journal.
+	tempdir, err := ioutil.TempDir("", "goledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	journal := testdataFile("reconcile.ldg")
+	journalfile := filepath.Join(tempdir, "reconcile.ldg")
+	if err := ioutil.WriteFile(journalfile, journal, 0660); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-f", journalfile, "-statement-balance", "$1,230.00",
+		"-date", "2011/01/31", "reconcile", "Assets:Checking"}
+	cmd := exec.Command(LEDGEREXEC, args...)
+	cmd.Stdin = strings.NewReader("y\n")
+	cmd.CombinedOutput()
+
+	ref := testdataFile("refdata/reconcile.cleared.ref")
+	data, err := ioutil.ReadFile(journalfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updateref {
+		ioutil.WriteFile("refdata/reconcile.cleared.ref", data, 0660)
+	}
+	if bytes.Compare(data, ref) != 0 {
+		t.Logf(strings.Join(args, " "))
+		t.Logf("expected %s", ref)
+		t.Errorf("got %s", data)
+	}
+	if backup, err := ioutil.ReadFile(journalfile + ".bak"); err != nil {
+		t.Error(err)
+	} else if bytes.Compare(backup, journal) != 0 {
+		t.Errorf("expected backup %s, got %s", journal, backup)
+	}
+}
+
+func TestImport(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "importcsv.ldg", "-account", "checking",
+				"-rules", "importcsv.rules", "import", "csv", "importcsv.csv"},
+			"refdata/importcsv.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "importcsv.ldg", "-account", "Assets:Bank:Checking",
+				"-rules", "importcsv2.rules", "import", "csv", "importcsv2.csv"},
+			"refdata/importcsv2.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "importcsv.ldg", "-account", "checking",
+				"-rules", "importcsverr.rules", "import", "csv",
+				"importcsv.csv"},
+			"refdata/importcsverr.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "importcsv.ldg", "-rules", "importcsv.rules",
+				"import", "csv", "importcsv.csv"},
+			"refdata/importcsv.noaccount.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "importcsv.ldg", "-account", "checking",
+				"import", "ofx", "importofx.ofx"},
+			"refdata/importofx.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "importcsv.ldg", "-account", "checking",
+				"import", "qfx", "importofx.qfx"},
+			"refdata/importqfx.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "importcsv.ldg", "-account", "checking",
+				"import", "ofx", "importofx2.ofx"},
+			"refdata/importofx2.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "importcsv.ldg", "-account", "checking",
+				"import", "ofx", "importcsv.csv"},
+			"refdata/importofxerr.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "dedup.ldg", "dedup"},
+			"refdata/dedup.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dedup.ldg", "-account", "checking",
+				"-rules", "importcsv.rules", "import", "csv", "importcsv.csv"},
+			"refdata/dedup.importcsv.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dedup.ldg", "-account", "checking",
+				"import", "ofx", "importofx.ofx"},
+			"refdata/dedup.importofx.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "beancount.ldg", "export", "beancount"},
+			"refdata/beancount.export.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "-lots", "lifo", "export", "beancount"},
+			"refdata/gains.beancount.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "balassert.ldg", "export", "beancount"},
+			"refdata/balassert.beancount.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "budget.ldg", "export", "beancount"},
+			"refdata/budget.beancount.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "auxdate.ldg", "export", "beancount"},
+			"refdata/auxdate.beancount.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtaccount1.ldg", "export", "beancount"},
+			"refdata/dirtaccount1.beancount.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "beancount.ldg", "export", "xml"},
+			"refdata/export.invalid.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "beancount.ldg", "export", "ledger"},
+			"refdata/beancount.ledger.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "export", "ledger"},
+			"refdata/drewr3.ledger.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtaccount2.ldg", "export", "ledger"},
+			"refdata/dirtaccount2.ledger.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtdefine.ldg", "export", "ledger"},
+			"refdata/dirtdefine.ledger.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "budget.ldg", "export", "ledger"},
+			"refdata/budget.ledger.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "commname.ldg", "export", "ledger"},
+			"refdata/commname.ledger.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "importcsv.ldg", "export", "ledger"},
+			"refdata/importcsv.ledger.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "beancount.ldg", "export", "json"},
+			"refdata/beancount.json.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+
+	// exported journal shall report the same as the original journal.
+	dir, err := ioutil.TempDir("", "goledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	journals := []string{
+		"drewr3.ldg", "gains.ldg", "dirtdefine.ldg", "totalcost.ldg",
+		"virtual.ldg", "budget.ldg", "virtualbal.ldg",
+	}
+	for _, journal := range journals {
+		args := []string{"-f", journal, "export", "ledger"}
+		out, err := exec.Command(LEDGEREXEC, args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v: %v", journal, err)
+		}
+		exported := filepath.Join(dir, journal)
+		if err := ioutil.WriteFile(exported, out, 0660); err != nil {
+			t.Fatal(err)
+		}
+		for _, report := range []string{"balance", "register"} {
+			args := []string{"-f", journal, report}
+			ref, _ := exec.Command(LEDGEREXEC, args...).CombinedOutput()
+			args = []string{"-f", exported, report}
+			out, _ := exec.Command(LEDGEREXEC, args...).CombinedOutput()
+			if bytes.Compare(out, ref) != 0 {
+				t.Logf(strings.Join(args, " "))
+				t.Logf("expected %s", ref)
+				t.Errorf("got %s", out)
+			}
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "csv", "balance"},
+			"refdata/drewr3.balance.csv.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "tsv", "balance"},
+			"refdata/drewr3.balance.tsv.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "-format", "json", "balance"},
+			"refdata/gains.balance.json.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "-format", "csv", "-dc", "balance"},
+			"refdata/gains.dcbalance.csv.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "json", "register"},
+			"refdata/drewr3.register.json.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "csv", "-monthly",
+				"register"},
+			"refdata/drewr3.monthly.csv.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "-format", "csv", "passbook",
+				"Assets:Brokerage"},
+			"refdata/gains.passbook.csv.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "json", "equity"},
+			"refdata/drewr3.equity.json.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtaccount1.ldg", "-format", "csv", "list",
+				"accounts"},
+			"refdata/dirtaccount1.list.csv.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "html", "balance"},
+			"refdata/drewr3.balance.html.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "html", "-collapse",
+				"balance"},
+			"refdata/drewr3.collapse.html.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "-format", "markdown", "balance"},
+			"refdata/gains.balance.md.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "-format", "markdown", "-collapse",
+				"balance"},
+			"refdata/gains.collapse.md.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "markdown", "register"},
+			"refdata/drewr3.register.md.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "html", "equity"},
+			"refdata/drewr3.equity.html.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "formatprecision.ldg", "-format", "csv", "balance"},
+			"refdata/formatprecision.balance.csv.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "formatprecision.ldg", "-format", "json", "register"},
+			"refdata/formatprecision.register.json.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "json", "print"},
+			"refdata/format.unsupported.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "drewr3.ldg", "-format", "xml", "balance"},
+			"refdata/format.invalid.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "drewr3.ldg")
+	err = ioutil.WriteFile(journal, testdataFile("drewr3.ldg"), 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	args := []string{"-f", journal, "-addr", addr, "serve"}
+	cmd := exec.Command(LEDGEREXEC, args...)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	get := func(path string) []byte {
+		for i := 0; i < 50; i++ {
+			resp, err := http.Get("http://" + addr + path)
+			if err != nil {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			defer resp.Body.Close()
+			data, _ := ioutil.ReadAll(resp.Body)
+			return data
+		}
+		t.Fatalf("unable to get %v", path)
+		return nil
+	}
+
+	testcases := [][]interface{}{
+		[]interface{}{"/accounts?filter=Income", "refdata/serve.accounts.ref"},
+		[]interface{}{
+			"/balances?filter=Assets&date=2010/12/31",
+			"refdata/serve.balances.ref",
+		},
+		[]interface{}{
+			"/balances?begin=2011/01/01&end=2011/02/01",
+			"refdata/serve.period.ref",
+		},
+		[]interface{}{
+			"/register?filter=Checking&begin=2011/01/01",
+			"refdata/serve.register.ref",
+		},
+		[]interface{}{"/payees", "refdata/serve.payees.ref"},
+		[]interface{}{"/commodities", "refdata/serve.commodities.ref"},
+		[]interface{}{"/balances?date=xyz", "refdata/serve.invalid.ref"},
+		[]interface{}{
+			"/balances?filter=Expenses+and+payee:Grocery",
+			"refdata/serve.predicate.ref",
+		},
+		[]interface{}{
+			"/accounts?filter=payee:Grocery",
+			"refdata/serve.accountserr.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		out := get(testcase[0].(string))
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(testcase[0].(string))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+
+	// journals shall be reloaded when modified.
+	data := "\n2012/01/01 Reload\n" +
+		"    Expenses:Misc  $5.00\n" +
+		"    Assets:Checking\n"
+	fd, err := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.WriteString(data)
+	fd.Close()
+	for i := 0; i < 50; i++ {
+		if bytes.Contains(get("/payees"), []byte(`"Reload"`)) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Errorf("journal not reloaded")
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "drewr3.ldg")
+	data := append(testdataFile("drewr3.ldg"), "include inc.ldg\n"...)
+	if err := ioutil.WriteFile(journal, data, 0660); err != nil {
+		t.Fatal(err)
+	}
+	included := filepath.Join(dir, "inc.ldg")
+	if err := ioutil.WriteFile(included, []byte{}, 0660); err != nil {
+		t.Fatal(err)
+	}
+	outfd, err := os.Create(filepath.Join(dir, "watch.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outfd.Close()
+
+	args := []string{"-f", journal, "-watch", "balance", "Expenses"}
+	cmd := exec.Command(LEDGEREXEC, args...)
+	cmd.Stdout, cmd.Stderr = outfd, outfd
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	waitfor := func(text string) {
+		for i := 0; i < 50; i++ {
+			out, _ := ioutil.ReadFile(outfd.Name())
+			if bytes.Contains(out, []byte(text)) {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		out, _ := ioutil.ReadFile(outfd.Name())
+		t.Fatalf("expected %q in %s", text, out)
+	}
+	appendto := func(text string) {
+		time.Sleep(10 * time.Millisecond) // for modification time.
+		fd, err := os.OpenFile(included, os.O_APPEND|os.O_WRONLY, 0660)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd.WriteString(text)
+		fd.Close()
+	}
+
+	waitfor("$6654.00")
+	// included journal is watched, parse errors are reported inline.
+	appendto("2012/02/02 Unbalanced\n    Expenses:Misc  $1\n" +
+		"    Assets:Checking  $2\n\n")
+	waitfor("unbalanced transaction")
+	ioutil.WriteFile(included, []byte("2012/02/02 Balanced\n"+
+		"    Expenses:Misc  $1\n    Assets:Checking\n\n"), 0660)
+	waitfor("$6655.00")
+}
+
+func TestWatchError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "drewr3.ldg")
+	if err := ioutil.WriteFile(journal, testdataFile("drewr3.ldg"), 0660); err != nil {
+		t.Fatal(err)
+	}
+	outfd, err := os.Create(filepath.Join(dir, "watch.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outfd.Close()
+
+	// report errors are reported, and journals are watched.
+	args := []string{"-f", journal, "-format", "csv", "-watch", "gains"}
+	cmd := exec.Command(LEDGEREXEC, args...)
+	cmd.Stdout, cmd.Stderr = outfd, outfd
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	text := []byte("unable to create report")
+	waitfor := func(count int) {
+		for i := 0; i < 50; i++ {
+			out, _ := ioutil.ReadFile(outfd.Name())
+			if bytes.Count(out, text) >= count {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		out, _ := ioutil.ReadFile(outfd.Name())
+		t.Fatalf("expected %v x %q in %s", count, text, out)
+	}
+
+	waitfor(1)
+	time.Sleep(10 * time.Millisecond) // for modification time.
+	data := append(testdataFile("drewr3.ldg"), "\n"...)
+	if err := ioutil.WriteFile(journal, data, 0660); err != nil {
+		t.Fatal(err)
+	}
+	waitfor(2)
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	journals := map[string]string{
+		"main.ldg": "include y2015.ldg\ninclude y2016.ldg\n\n" +
+			"03/01 Bonus\n    Income:Bonus  $(-rent)\n\n",
+		"y2015.ldg": "year 2015\n\napply account Personal\n" +
+			"alias Food=Expenses:Food\nbucket Assets:Checking\n" +
+			"define rent=500\n\n" +
+			"01/05 Grocery\n    Expenses:Misc  $10.00\n\n",
+		"y2016.ldg": "02/01 Rent\n    Expenses:Rent  $(rent)\n\n" +
+			"02/02 Lunch\n    Food  $12.00\n\n",
+	}
+	writeto := func(name, text string) {
+		time.Sleep(10 * time.Millisecond) // for modification time.
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0660)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, text := range journals {
+		writeto(name, text)
+	}
+	outfd, err := os.Create(filepath.Join(dir, "cache.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outfd.Close()
+
+	journal := filepath.Join(dir, "main.ldg")
+	cmd := exec.Command(LEDGEREXEC, "-f", journal, "-watch", "register")
+	cmd.Stdout, cmd.Stderr = outfd, outfd
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	// re-run shall render the same as journals loaded without cache.
+	waitrerun := func(text string) {
+		cmd := exec.Command(LEDGEREXEC, "-f", journal, "register")
+		ref, _ := cmd.CombinedOutput()
+		if bytes.Contains(ref, []byte(text)) == false {
+			t.Fatalf("expected %q in %s", text, ref)
+		}
+		var out []byte
+		for i := 0; i < 50; i++ {
+			out, _ = ioutil.ReadFile(outfd.Name())
+			renders := bytes.Split(out, []byte("\x1b[H\x1b[2J"))
+			if bytes.Equal(renders[len(renders)-1], ref) {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("expected %s\ngot %s", ref, out)
+	}
+
+	waitrerun("Personal:Expenses:Food")
+	// unchanged y2015.ldg is replayed from cache.
+	writeto("y2016.ldg", journals["y2016.ldg"]+
+		"02/03 Movie\n    Expenses:Fun  $8.00\n\n")
+	waitrerun("Movie")
+	// alias and bucket, from y2015.ldg, apply to replayed y2016.ldg.
+	y2015 := strings.Replace(
+		journals["y2015.ldg"], "Expenses:Food", "Expenses:Dining", 1)
+	y2015 = strings.Replace(y2015, "Assets:Checking", "Assets:Cash", 1)
+	writeto("y2015.ldg", y2015)
+	waitrerun("Personal:Expenses:Dining")
+	// year and define, from y2015.ldg, reparse y2016.ldg and entries
+	// following the include in main.ldg.
+	y2015 = strings.Replace(y2015, "year 2015", "year 2014", 1)
+	y2015 = strings.Replace(y2015, "rent=500", "rent=600", 1)
+	writeto("y2015.ldg", y2015)
+	waitrerun("2014-Mar-01")
+}
+
+func TestDecimal(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "decimal.ldg", "balance"},
+			"refdata/decimal.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "decimal.ldg", "register"},
+			"refdata/decimal.register.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "decimal.ldg", "-format", "csv", "balance"},
+			"refdata/decimal.balance.csv.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "decimalerr1.ldg", "balance"},
+			"refdata/decimalerr1.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "decimalerr2.ldg", "balance"},
+			"refdata/decimalerr2.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestCommodityFormat(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "commformat.ldg", "balance"},
+			"refdata/commformat.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "commformat.ldg", "register"},
+			"refdata/commformat.register.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "commformat.ldg", "-format-from-first-use", "register",
+			},
+			"refdata/commformat.firstuse.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "commformaterr1.ldg", "balance"},
+			"refdata/commformaterr1.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "tags.ldg", "balance", "tag:reimbursable"},
+			"refdata/tags.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "tags.ldg", "balance", "%project=beta"},
+			"refdata/tags.balance.meta.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "tags.ldg", "register", "Expenses", "and",
+				"%project=alpha",
+			},
+			"refdata/tags.register.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "tags.ldg", "register", "not", "tag:meal"},
+			"refdata/tags.register.not.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "tags.ldg", "passbook", "Assets:Cash", "tag:reimbursable",
+			},
+			"refdata/tags.passbook.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "tags.ldg", "-by-tag", "project", "balance", "Expenses",
+			},
+			"refdata/tags.bytag.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "query.ldg", "register", "amt:>100"},
+			"refdata/query.register.amt.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "query.ldg", "register", "date:2024-01..2024-03", "and",
+				"status:*",
+			},
+			"refdata/query.register.date.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "query.ldg", "balance", "desc:Whole", "or", "code:102",
+			},
+			"refdata/query.balance.desc.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "query.ldg", "balance", "cur:EUR", "and", "real:"},
+			"refdata/query.balance.cur.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "query.ldg", "register", "virtual:"},
+			"refdata/query.register.virtual.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "query.ldg", "passbook", "Assets:Checking", "payee:Air",
+			},
+			"refdata/query.passbook.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "query.ldg", "equity", "Assets", "and", "amt:>100",
+			},
+			"refdata/query.equity.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "gains.ldg", "gains", "amt:>10"},
+			"refdata/query.gains.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "budget.ldg", "budget", "amt:>400"},
+			"refdata/query.budget.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "query.ldg", "register", "amt:>x"},
+			"refdata/queryerr1.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "query.ldg", "list", "accounts", "amt:>1"},
+			"refdata/queryerr2.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestVirtual(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "virtualbal.ldg", "balance"},
+			"refdata/virtualbal.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "virtualbal.ldg", "-real", "balance"},
+			"refdata/virtualbal.balance.real.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "virtualbal.ldg", "register"},
+			"refdata/virtualbal.register.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "virtualbal.ldg", "-real", "register"},
+			"refdata/virtualbal.register.real.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "virtualbal.ldg", "print"},
+			"refdata/virtualbal.print.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "virtualbal.ldg", "-real", "print"},
+			"refdata/virtualbal.print.real.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "statebal.ldg", "-real", "balance"},
+			"refdata/statebal.real.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "virtualerr1.ldg", "balance"},
+			"refdata/virtualerr1.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "virtualerr2.ldg", "balance"},
+			"refdata/virtualerr2.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
 func TestDirtAccount(t *testing.T) {
 	testcases := [][]interface{}{
 		[]interface{}{
@@ -1716,6 +2924,103 @@ func TestDirtCommodity(t *testing.T) {
 	}
 }
 
+func TestDirtDefine(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "dirtdefine.ldg", "register"},
+			"refdata/dirtdefine.register.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtdefine.ldg", "balance"},
+			"refdata/dirtdefine.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtdefineerr.ldg", "balance"},
+			"refdata/dirtdefineerr.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtdefineerr2.ldg", "balance"},
+			"refdata/dirtdefineerr2.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtdefineerr3.ldg", "balance"},
+			"refdata/dirtdefineerr3.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtdefine2.ldg", "balance"},
+			"refdata/dirtdefine2.balance.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestDirtFixed(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "dirtfixed.ldg", "register"},
+			"refdata/dirtfixed.register.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtfixed.ldg", "balance"},
+			"refdata/dirtfixed.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "dirtfixederr.ldg", "balance"},
+			"refdata/dirtfixederr.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
+func TestDirtComment(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "dirtcomment.ldg", "register"},
+			"refdata/dirtcomment.register.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
+
 func TestDirtAlias(t *testing.T) {
 	testcases := [][]interface{}{
 		[]interface{}{
@@ -1901,3 +3206,46 @@ func testdataFile(filename string) []byte {
 	}
 	return data
 }
+
+func TestForecast(t *testing.T) {
+	testcases := [][]interface{}{
+		[]interface{}{
+			[]string{"-f", "budget.ldg", "-forecast", "2011/06/01", "register"},
+			"refdata/forecast.register.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "budget.ldg", "-forecast", "2011/06/01", "balance"},
+			"refdata/forecast.balance.ref",
+		},
+		[]interface{}{
+			[]string{"-f", "budget.ldg", "-forecast", "2011/06/01", "print"},
+			"refdata/forecast.print.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "forecastbucket.ldg", "-forecast", "2011/04/01", "register",
+			},
+			"refdata/forecastbucket.ref",
+		},
+		[]interface{}{
+			[]string{
+				"-f", "forecasterr1.ldg", "-forecast", "2011/04/01", "register",
+			},
+			"refdata/forecasterr1.ref",
+		},
+	}
+	for _, testcase := range testcases {
+		ref := testdataFile(testcase[1].(string))
+		args := testcase[0].([]string)
+		cmd := exec.Command(LEDGEREXEC, args...)
+		out, _ := cmd.CombinedOutput()
+		if updateref {
+			ioutil.WriteFile(testcase[1].(string), out, 0660)
+		}
+		if bytes.Compare(out, ref) != 0 {
+			t.Logf(strings.Join(args, " "))
+			t.Logf("expected %s", ref)
+			t.Errorf("got %s", out)
+		}
+	}
+}
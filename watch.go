@@ -0,0 +1,58 @@
+package main
+
+import "os"
+import "fmt"
+import "time"
+
+import "github.com/bnclabs/golog"
+import "github.com/tn47/goledger/api"
+import "github.com/tn47/goledger/reports"
+
+// watch run the command and re-run it whenever any of the journals,
+// including the included journals, are modified. Parse errors are
+// reported and the journals are watched for a fix. Only the modified
+// journals are parsed again.
+func watch(args []string, interval time.Duration) {
+	jcache = newjournalcache()
+	journals := api.Options.Journals
+	for {
+		mtimes := modtimes(journals)
+		if api.Options.Outfd == os.Stdout {
+			fmt.Fprint(api.Options.Outfd, "\x1b[H\x1b[2J") // clear screen
+		}
+		for _, journal := range rerun(args) {
+			if _, ok := mtimes[journal]; ok == false {
+				mtimes[journal] = modtimes([]string{journal})[journal]
+				journals = append(journals, journal)
+			}
+		}
+		for modified(mtimes) == false {
+			time.Sleep(interval)
+		}
+		log.Infof("journals modified, re-running ...\n")
+	}
+}
+
+// rerun do firstpass and secondpass on journals, and render the report.
+// Return the journals processed, including the included journals. Errors
+// are reported, and the journals known so far are watched for a fix.
+func rerun(args []string) []string {
+	reporter, err := reports.NewReporter(args)
+	if err != nil {
+		log.Errorf("unable to create report: %v\n", err)
+		return nil
+	}
+	db, err := loadjournals(reporter)
+	if err != nil {
+		// firstpass errors are already logged, watch the journals parsed
+		// so far, along with the journals already watched.
+		return db.Journals()
+	}
+	journals := db.Journals()
+	nreporter, ndb, err := dosecondpass(args, reporter, db)
+	if err != nil {
+		return journals
+	}
+	nreporter.Render(args, ndb)
+	return journals
+}